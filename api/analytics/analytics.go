@@ -0,0 +1,340 @@
+package analytics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+)
+
+type Granularity string
+const (
+	Day		Granularity = "day"
+	Week	Granularity = "week"
+	Month	Granularity = "month"
+)
+
+var (
+	maxPeriods = 400
+	defaultRange = time.Hour * 24 * 365
+)
+
+// DateRange is a half open [From, To) range split into periods of Granularity
+type DateRange struct {
+	From 			time.Time
+	To 				time.Time
+	Granularity 	Granularity
+}
+
+// ParseDateRange reads from/to as YYYY-MM-DD days in loc, defaulting to the last year by month
+func ParseDateRange(
+	from string, 
+	to string, 
+	granularity string, 
+	loc *time.Location,
+) (*DateRange, *models.RequestError) {
+	now := time.Now().In(loc)
+	r := DateRange{
+		From: now.Add(-defaultRange),
+		To: now,
+		Granularity: Month,
+	}
+
+	var err error
+	if from != "" {
+		r.From, err = time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+
+	if to != "" {
+		r.To, err = time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		// include the whole of the last day
+		r.To = r.To.AddDate(0, 0, 1)
+	}
+
+	if granularity != "" {
+		r.Granularity = Granularity(granularity)
+	}
+
+	if r.Granularity != Day && r.Granularity != Week && r.Granularity != Month {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("invalid granularity %s", granularity),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if !r.From.Before(r.To) {
+		return nil, &models.RequestError{
+			Err: errors.New("from must be before to"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if len(r.periodStarts()) > maxPeriods {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("range covers more than %d periods", maxPeriods),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return &r, nil
+}
+
+func truncate(t time.Time, granularity Granularity) (time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch granularity {
+	case Week:
+		// weeks start on monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+func next(t time.Time, granularity Granularity) (time.Time) {
+	switch granularity {
+	case Week:
+		return t.AddDate(0, 0, 7)
+	case Month:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func (r *DateRange) periodStarts() ([]time.Time) {
+	starts := []time.Time{}
+	for t := truncate(r.From, r.Granularity); t.Before(r.To); t = next(t, r.Granularity) {
+		starts = append(starts, t)
+		if len(starts) > maxPeriods {
+			break
+		}
+	}
+	return starts
+}
+
+// paidSubs leaves out gift subscriptions, which bring in no recurring revenue
+func paidSubs(subs []bus_models.AnalyticsSub) ([]bus_models.AnalyticsSub) {
+	paid := []bus_models.AnalyticsSub{}
+	for _, sub := range subs {
+		if !sub.Gift {
+			paid = append(paid, sub)
+		}
+	}
+	return paid
+}
+
+func activeAt(sub bus_models.AnalyticsSub, t time.Time) (bool) {
+	return !sub.Start.After(t) && (sub.End == nil || sub.End.After(t))
+}
+
+// mrrAt sums the monthly value of subscriptions live at t, per customer
+func mrrAt(subs []bus_models.AnalyticsSub, t time.Time) (map[int]int) {
+	byCus := map[int]int{}
+	for _, sub := range subs {
+		if activeAt(sub, t) {
+			byCus[sub.CustomerID] += sub.MonthlyAmount
+		}
+	}
+	return byCus
+}
+
+func sumValues(m map[int]int) (int) {
+	total := 0
+	for _, v := range m {
+		total += v
+	}
+	return total
+}
+
+func GetRevenueAnalytics(
+	sqlDB *sql.DB,
+	businessId int,
+	r *DateRange,
+) (map[string]interface{}, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	allSubs, err := b.GetAnalyticsSubs(businessId, r.To)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	subs := paidSubs(allSubs)
+
+	starts := r.periodStarts()
+	invoices, err := b.GetAnalyticsInvoices(businessId, starts[0], r.To)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 1. series, each point is measured at the end of its period
+	series := []bus_models.RevenuePoint{}
+	for _, start := range starts {
+		end := next(start, r.Granularity)
+		if end.After(r.To) {
+			end = r.To
+		}
+
+		point := bus_models.RevenuePoint{PeriodStart: start}
+		mrr := mrrAt(subs, end.Add(-time.Nanosecond))
+		point.MRR = sumValues(mrr)
+		point.ARR = point.MRR * 12
+		point.ActiveSubscribers = len(mrr)
+		if point.ActiveSubscribers > 0 {
+			point.ARPU = point.MRR / point.ActiveSubscribers
+		}
+
+		for _, sub := range subs {
+			if !sub.Start.Before(start) && sub.Start.Before(end) {
+				point.NewSubscribers++
+			}
+			if sub.End != nil && !sub.End.Before(start) && sub.End.Before(end) {
+				point.ChurnedSubscribers++
+			}
+		}
+
+		for _, invoice := range invoices {
+			if !invoice.Created.Before(start) && invoice.Created.Before(end) {
+				point.Revenue += invoice.Total
+			}
+		}
+
+		series = append(series, point)
+	}
+
+	// 2. summary over the whole range
+	startMRR := mrrAt(subs, starts[0])
+	endMRR := mrrAt(subs, r.To.Add(-time.Nanosecond))
+	summary := bus_models.RevenueSummary{
+		MRR: sumValues(endMRR),
+		ActiveSubscribers: len(endMRR),
+	}
+	summary.ARR = summary.MRR * 12
+	if summary.ActiveSubscribers > 0 {
+		summary.ARPU = summary.MRR / summary.ActiveSubscribers
+	}
+
+	for _, point := range series {
+		summary.NewSubscribers += point.NewSubscribers
+		summary.ChurnedSubscribers += point.ChurnedSubscribers
+		summary.Revenue += point.Revenue
+	}
+
+	startingSubs := 0
+	for _, sub := range subs {
+		if activeAt(sub, starts[0]) {
+			startingSubs++
+		}
+	}
+	if startingSubs > 0 {
+		summary.ChurnRate = float64(summary.ChurnedSubscribers) / float64(startingSubs)
+	}
+
+	// net revenue retention only follows customers who were paying at the start
+	if startTotal := sumValues(startMRR); startTotal > 0 {
+		retained := 0
+		for cusId := range startMRR {
+			retained += endMRR[cusId]
+		}
+		nrr := float64(retained) / float64(startTotal)
+		summary.NetRevenueRetention = &nrr
+	}
+
+	return map[string]interface{}{
+		"from": starts[0],
+		"to": r.To,
+		"granularity": r.Granularity,
+		"series": series,
+		"summary": summary,
+	}, nil
+}
+
+// GetCohortRetention groups customers by the month they first subscribed and tracks
+// what fraction still has a live subscription at the end of each following month
+func GetCohortRetention(
+	sqlDB *sql.DB,
+	businessId int,
+	r *DateRange,
+) ([]bus_models.Cohort, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	allSubs, err := b.GetAnalyticsSubs(businessId, r.To)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	subs := paidSubs(allSubs)
+
+	firstStart := map[int]time.Time{}
+	subsByCus := map[int][]bus_models.AnalyticsSub{}
+	for _, sub := range subs {
+		if first, ok := firstStart[sub.CustomerID]; !ok || sub.Start.Before(first) {
+			firstStart[sub.CustomerID] = sub.Start
+		}
+		subsByCus[sub.CustomerID] = append(subsByCus[sub.CustomerID], sub)
+	}
+
+	now := time.Now()
+	cohorts := []bus_models.Cohort{}
+	for month := truncate(r.From, Month); month.Before(r.To); month = month.AddDate(0, 1, 0) {
+		members := []int{}
+		for cusId, first := range firstStart {
+			if truncate(first, Month).Equal(month) {
+				members = append(members, cusId)
+			}
+		}
+
+		cohort := bus_models.Cohort{
+			Month: month,
+			Size: len(members),
+			Retention: []float64{},
+		}
+
+		for end := month.AddDate(0, 1, 0); !end.After(r.To) && !end.After(now); end = end.AddDate(0, 1, 0) {
+			if cohort.Size == 0 {
+				cohort.Retention = append(cohort.Retention, 0)
+				continue
+			}
+
+			retained := 0
+			for _, cusId := range members {
+				for _, sub := range subsByCus[cusId] {
+					if activeAt(sub, end.Add(-time.Nanosecond)) {
+						retained++
+						break
+					}
+				}
+			}
+			cohort.Retention = append(cohort.Retention, float64(retained) / float64(cohort.Size))
+		}
+
+		cohorts = append(cohorts, cohort)
+	}
+
+	return cohorts, nil
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func Routes(analyticsRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	analyticsRouter.GET("", getRevenueAnalyticsHandler(sqlDB))
+	analyticsRouter.GET("/cohorts", getCohortRetentionHandler(sqlDB))
+	analyticsRouter.GET("/usage/heatmap", getUsageHeatmapHandler(sqlDB))
+	analyticsRouter.GET("/usage/utilisation", getUsageUtilisationHandler(sqlDB))
+	analyticsRouter.GET("/usage/subscribers", getSubscriberActivityHandler(sqlDB))
+}
+
+// businessDateRange authenticates the business and reads the range from the query in their time zone
+func businessDateRange(c *gin.Context, sqlDB *sql.DB) (*int, *DateRange, bool) {
+	businessId, err := middleware.AuthenticateBId(c, sqlDB)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, err)
+		return nil, nil, false
+	}
+
+	loc, err := BusinessLocation(sqlDB, *businessId)
+	if err != nil {
+		log.Println("Failed to get business location:", err)
+		c.JSON(http.StatusBadGateway, err)
+		return nil, nil, false
+	}
+
+	r, reqErr := ParseDateRange(c.Query("from"), c.Query("to"), c.Query("granularity"), loc)
+	if reqErr != nil {
+		c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+		return nil, nil, false
+	}
+
+	return businessId, r, true
+}
+
+func getRevenueAnalyticsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, r, ok := businessDateRange(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		res, reqErr := GetRevenueAnalytics(sqlDB, *businessId, r)
+		if reqErr != nil {
+			log.Println("Failed to get revenue analytics:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func getCohortRetentionHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, r, ok := businessDateRange(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		res, reqErr := GetCohortRetention(sqlDB, *businessId, r)
+		if reqErr != nil {
+			log.Println("Failed to get cohort retention:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+
+func getUsageHeatmapHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, r, ok := businessDateRange(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		res, reqErr := GetUsageHeatmap(sqlDB, *businessId, r)
+		if reqErr != nil {
+			log.Println("Failed to get usage heatmap:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func getUsageUtilisationHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, r, ok := businessDateRange(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		res, reqErr := GetUsageUtilisation(sqlDB, *businessId, r)
+		if reqErr != nil {
+			log.Println("Failed to get usage utilisation:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func getSubscriberActivityHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, r, ok := businessDateRange(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		dormantDays, err := strconv.Atoi(c.DefaultQuery("dormant_days", "30"))
+		if err != nil || dormantDays < 1 {
+			c.JSON(http.StatusBadRequest, "invalid dormant_days")
+			return
+		}
+
+		res, reqErr := GetSubscriberActivity(sqlDB, *businessId, r, limit, dormantDays)
+		if reqErr != nil {
+			log.Println("Failed to get subscriber activity:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
\ No newline at end of file
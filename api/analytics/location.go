@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"database/sql"
+	"time"
+
+	// bundle the zone database so businesses get local times wherever we're deployed
+	_ "time/tzdata"
+
+	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+)
+
+// fallback zones for businesses that haven't set one, by country code
+var countryTimeZones = map[string]string{
+	"GB": "Europe/London",
+	"IE": "Europe/Dublin",
+	"SG": "Asia/Singapore",
+	"MY": "Asia/Kuala_Lumpur",
+	"AU": "Australia/Sydney",
+	"US": "America/New_York",
+}
+
+// BusinessLocation is the business's chosen time zone, else their country's, else UTC
+func BusinessLocation(sqlDB *sql.DB, businessId int) (*time.Location, error) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	timeZone, country, err := b.GetBusinessTimeZone(businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	name := timeZone.String
+	if !timeZone.Valid {
+		name = countryTimeZones[*country]
+	}
+
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
@@ -0,0 +1,175 @@
+package analytics
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"time"
+
+	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+)
+
+var (
+	usageIntervalLength = map[string]time.Duration{
+		"day": time.Hour * 24,
+		"week": time.Hour * 24 * 7,
+		"month": time.Hour * 24 * 30,
+		"year": time.Hour * 24 * 365,
+	}
+	maxSubscriberLimit = 100
+)
+
+// GetUsageHeatmap counts redemptions by hour of the week in the range's time zone
+func GetUsageHeatmap(
+	sqlDB *sql.DB,
+	businessId int,
+	r *DateRange,
+) (map[string]interface{}, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	times, err := b.GetUsageTimes(businessId, r.From, r.To)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	loc := r.From.Location()
+	heatmap := bus_models.UsageHeatmap{}
+	for _, t := range times {
+		local := t.In(loc)
+		heatmap[(int(local.Weekday()) + 6) % 7][local.Hour()]++
+	}
+
+	return map[string]interface{}{
+		"time_zone": loc.String(),
+		"total": len(times),
+		"heatmap": heatmap,
+	}, nil
+}
+
+// GetUsageUtilisation compares redemptions against what subscribers were entitled to over
+// the range. Breakage is the share of limited entitlements that were paid for but never used
+func GetUsageUtilisation(
+	sqlDB *sql.DB,
+	businessId int,
+	r *DateRange,
+) (map[string]interface{}, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	subs, err := b.GetAnalyticsSubs(businessId, r.To)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	usages, planIds, err := b.GetSubUsageRedemptions(businessId, r.From, r.To)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 1. time each plan's subscriptions were live within the range
+	activeByPlan := map[int]time.Duration{}
+	for _, sub := range subs {
+		start := sub.Start
+		if start.Before(r.From) {
+			start = r.From
+		}
+
+		end := r.To
+		if sub.End != nil && sub.End.Before(end) {
+			end = *sub.End
+		}
+
+		if end.After(start) {
+			activeByPlan[sub.PlanID] += end.Sub(start)
+		}
+	}
+
+	// 2. entitlement allowance over that time
+	totalAllowed, totalRedeemed := 0, 0
+	for i, u := range usages {
+		length, ok := usageIntervalLength[u.Interval]
+		if u.Unlimited || !ok {
+			continue
+		}
+
+		periods := float64(activeByPlan[planIds[u.SubUsageID]]) / float64(length)
+		allowed := int(math.Round(periods * float64(u.Amount)))
+		usages[i].Allowed = &allowed
+
+		if allowed > 0 {
+			utilisation := float64(u.Redeemed) / float64(allowed)
+			usages[i].Utilisation = &utilisation
+		}
+
+		totalAllowed += allowed
+		if u.Redeemed < allowed {
+			totalRedeemed += u.Redeemed
+		} else {
+			totalRedeemed += allowed
+		}
+	}
+
+	breakageRate := 0.0
+	if totalAllowed > 0 {
+		breakageRate = float64(totalAllowed - totalRedeemed) / float64(totalAllowed)
+	}
+
+	return map[string]interface{}{
+		"usages": usages,
+		"breakage": map[string]interface{}{
+			"allowed": totalAllowed,
+			"redeemed": totalRedeemed,
+			"unredeemed": totalAllowed - totalRedeemed,
+			"rate": breakageRate,
+		},
+	}, nil
+}
+
+// GetSubscriberActivity returns the most active subscribers over the range and the
+// live subscribers who haven't been in for dormantDays
+func GetSubscriberActivity(
+	sqlDB *sql.DB,
+	businessId int,
+	r *DateRange,
+	limit int,
+	dormantDays int,
+) (map[string]interface{}, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	if limit < 1 || limit > maxSubscriberLimit {
+		limit = maxSubscriberLimit
+	}
+
+	top, err := b.GetTopSubscribers(businessId, r.From, r.To, limit)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	since := time.Now().AddDate(0, 0, -dormantDays)
+	dormant, err := b.GetDormantSubscribers(businessId, since, limit)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return map[string]interface{}{
+		"top": top,
+		"dormant": dormant,
+		"dormant_since": since,
+	}, nil
+}
@@ -0,0 +1,325 @@
+package apple_pass
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/passes"
+	"github.com/johnyeocx/usual/server/utils/otp"
+)
+
+var errUnauthorized = errors.New("invalid pass authentication token")
+
+// apple passes are uploaded for the customer's first download, after that Wallet
+// fetches them from the web service
+func customerPassKey(cusId int) (string) {
+	return fmt.Sprintf("customer/pkpass/%d.pkpass", cusId)
+}
+
+func subPassKey(cusId int, subId int) (string) {
+	return fmt.Sprintf("customer/pkpass/%d/sub/%d.pkpass", cusId, subId)
+}
+
+func buildPass(sqlDB *sql.DB, store cloud.ObjectStore, pass *models.ApplePass) (*passes.WalletPass, error) {
+	return issuePass(sqlDB, store, passes.AppleWallet{}, pass.CustomerID, pass.SubID, pass)
+}
+
+// CreateCustomerPass issues the customer's wallet pass, or reissues it with the
+// same auth token, and uploads it for download
+func CreateCustomerPass(sqlDB *sql.DB, store cloud.ObjectStore, cusId int) (error) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+	p := db.PassDB{DB: sqlDB}
+
+	cus, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return err
+	}
+
+	pass, err := p.UpsertApplePass(models.ApplePass{
+		SerialNumber: cus.Uuid,
+		CustomerID: cus.ID,
+		AuthToken: otp.GenerateCode(32),
+		Updated: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	z, err := buildPass(sqlDB, store, pass)
+	if err != nil {
+		return err
+	}
+
+	return store.Put(customerPassKey(cusId), z.Data, z.ContentType)
+}
+
+// CreateSubscriptionPass issues the customer's pass for a subscription they own or
+// are a member of, returning the key it was uploaded to
+func CreateSubscriptionPass(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	cusId int,
+	subId int,
+) (string, *models.RequestError) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+	p := db.PassDB{DB: sqlDB}
+
+	cus, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	pass, err := p.UpsertApplePass(models.ApplePass{
+		SerialNumber: passes.SubPassSerial(cus.Uuid, subId),
+		CustomerID: cus.ID,
+		SubID: &subId,
+		AuthToken: otp.GenerateCode(32),
+		Updated: time.Now(),
+	})
+	if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	z, err := buildPass(sqlDB, store, pass)
+	if err == errNoSubAccess {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusForbidden,
+		}
+	} else if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	key := subPassKey(cusId, subId)
+	err = store.Put(key, z.Data, z.ContentType)
+	if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return key, nil
+}
+
+// authenticatePass checks the token Wallet sends with every request for a pass
+func authenticatePass(
+	p *db.PassDB,
+	passTypeId string,
+	serialNumber string,
+	authToken string,
+) (*models.ApplePass, *models.RequestError) {
+	if passTypeId != passes.PassTypeIdentifier {
+		return nil, &models.RequestError{
+			Err: errUnauthorized,
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	pass, err := p.GetApplePass(serialNumber)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: errUnauthorized,
+			StatusCode: http.StatusUnauthorized,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if subtle.ConstantTimeCompare([]byte(pass.AuthToken), []byte(authToken)) != 1 {
+		return nil, &models.RequestError{
+			Err: errUnauthorized,
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+	return pass, nil
+}
+
+// RegisterDevice returns whether this is a new registration for the device
+func RegisterDevice(
+	sqlDB *sql.DB,
+	deviceLibraryId string,
+	passTypeId string,
+	serialNumber string,
+	authToken string,
+	pushToken string,
+) (bool, *models.RequestError) {
+	p := db.PassDB{DB: sqlDB}
+
+	if _, reqErr := authenticatePass(&p, passTypeId, serialNumber, authToken); reqErr != nil {
+		return false, reqErr
+	}
+
+	if pushToken == "" {
+		return false, &models.RequestError{
+			Err: errors.New("missing push token"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	created, err := p.RegisterPassDevice(deviceLibraryId, passTypeId, serialNumber, pushToken)
+	if err != nil {
+		return false, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return created, nil
+}
+
+func UnregisterDevice(
+	sqlDB *sql.DB,
+	deviceLibraryId string,
+	passTypeId string,
+	serialNumber string,
+	authToken string,
+) (*models.RequestError) {
+	p := db.PassDB{DB: sqlDB}
+
+	if _, reqErr := authenticatePass(&p, passTypeId, serialNumber, authToken); reqErr != nil {
+		return reqErr
+	}
+
+	err := p.UnregisterPassDevice(deviceLibraryId, passTypeId, serialNumber)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("device not registered for pass"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+// GetUpdatedSerials returns nil when nothing on the device has changed since the
+// tag it last saw. The tag is the RFC3339 time of the latest change we sent
+func GetUpdatedSerials(
+	sqlDB *sql.DB,
+	deviceLibraryId string,
+	passTypeId string,
+	updatedSince string,
+) (map[string]interface{}, *models.RequestError) {
+	p := db.PassDB{DB: sqlDB}
+
+	var since *time.Time
+	if updatedSince != "" {
+		t, err := time.Parse(time.RFC3339Nano, updatedSince)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		since = &t
+	}
+
+	serials, lastUpdated, err := p.GetDevicePassSerials(deviceLibraryId, passTypeId, since)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if len(serials) == 0 {
+		return nil, nil
+	}
+
+	return map[string]interface{}{
+		"serialNumbers": serials,
+		"lastUpdated": lastUpdated.UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+// GetLatestPass builds the pass as it is now. It returns a nil pass if it hasn't
+// changed since modifiedSince
+func GetLatestPass(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	passTypeId string,
+	serialNumber string,
+	authToken string,
+	modifiedSince *time.Time,
+) ([]byte, *time.Time, *models.RequestError) {
+	p := db.PassDB{DB: sqlDB}
+
+	pass, reqErr := authenticatePass(&p, passTypeId, serialNumber, authToken)
+	if reqErr != nil {
+		return nil, nil, reqErr
+	}
+
+	// http dates only go down to the second
+	updated := pass.Updated.Truncate(time.Second)
+	if modifiedSince != nil && !updated.After(*modifiedSince) {
+		return nil, &updated, nil
+	}
+
+	z, err := buildPass(sqlDB, store, pass)
+	if err == errNoSubAccess {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return z.Data, &updated, nil
+}
+
+// PassesChanged marks the passes of everyone on a subscription as changed and pushes
+// to their devices so Wallet fetches the new version. Failures are only logged
+func PassesChanged(sqlDB *sql.DB, subId int) {
+	p := db.PassDB{DB: sqlDB}
+
+	serials, err := p.TouchSubPasses(subId)
+	if err != nil {
+		log.Printf("Failed to update passes for sub %d: %v\n", subId, err)
+		return
+	}
+	if len(serials) == 0 {
+		return
+	}
+
+	pushTokens, err := p.GetPassPushTokens(serials)
+	if err != nil {
+		log.Printf("Failed to get pass push tokens for sub %d: %v\n", subId, err)
+		return
+	}
+
+	stale, err := passes.PushPassUpdates(pushTokens)
+	if len(stale) > 0 {
+		if err := p.DeletePassPushTokens(stale); err != nil {
+			log.Printf("Failed to remove stale pass push tokens: %v\n", err)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to push pass updates for sub %d: %v\n", subId, err)
+	}
+}
@@ -0,0 +1,152 @@
+package apple_pass
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+)
+
+// Routes implements Apple's PassKit web service, which Wallet calls with the
+// pass's webServiceURL as the base
+func Routes(passRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	passRouter.POST("/v1/devices/:deviceId/registrations/:passTypeId/:serialNumber", registerDeviceHandler(sqlDB))
+	passRouter.DELETE("/v1/devices/:deviceId/registrations/:passTypeId/:serialNumber", unregisterDeviceHandler(sqlDB))
+	passRouter.GET("/v1/devices/:deviceId/registrations/:passTypeId", getUpdatedSerialsHandler(sqlDB))
+	passRouter.GET("/v1/passes/:passTypeId/:serialNumber", getLatestPassHandler(sqlDB, store))
+	passRouter.POST("/v1/log", logHandler())
+}
+
+// Wallet authenticates with "Authorization: ApplePass <token>"
+func passAuthToken(c *gin.Context) (string) {
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "ApplePass ")
+}
+
+func registerDeviceHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		reqBody := struct {
+			PushToken string `json:"pushToken"`
+		}{}
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		created, reqErr := RegisterDevice(
+			sqlDB,
+			c.Param("deviceId"),
+			c.Param("passTypeId"),
+			c.Param("serialNumber"),
+			passAuthToken(c),
+			reqBody.PushToken,
+		)
+		if reqErr != nil {
+			log.Println("Failed to register pass device: ", reqErr.Err)
+			c.Status(reqErr.StatusCode)
+			return
+		}
+
+		if created {
+			c.Status(http.StatusCreated)
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
+
+func unregisterDeviceHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		reqErr := UnregisterDevice(
+			sqlDB,
+			c.Param("deviceId"),
+			c.Param("passTypeId"),
+			c.Param("serialNumber"),
+			passAuthToken(c),
+		)
+		if reqErr != nil {
+			log.Println("Failed to unregister pass device: ", reqErr.Err)
+			c.Status(reqErr.StatusCode)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+func getUpdatedSerialsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		res, reqErr := GetUpdatedSerials(
+			sqlDB,
+			c.Param("deviceId"),
+			c.Param("passTypeId"),
+			c.Query("passesUpdatedSince"),
+		)
+		if reqErr != nil {
+			log.Println("Failed to get updated passes: ", reqErr.Err)
+			c.Status(reqErr.StatusCode)
+			return
+		}
+
+		if res == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func getLatestPassHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		var modifiedSince *time.Time
+		if header := c.GetHeader("If-Modified-Since"); header != "" {
+			if t, err := http.ParseTime(header); err == nil {
+				modifiedSince = &t
+			}
+		}
+
+		pass, updated, reqErr := GetLatestPass(
+			sqlDB,
+			store,
+			c.Param("passTypeId"),
+			c.Param("serialNumber"),
+			passAuthToken(c),
+			modifiedSince,
+		)
+		if reqErr != nil {
+			log.Println("Failed to get latest pass: ", reqErr.Err)
+			c.Status(reqErr.StatusCode)
+			return
+		}
+
+		c.Header("Last-Modified", updated.UTC().Format(http.TimeFormat))
+		if pass == nil {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, "application/vnd.apple.pkpass", pass)
+	}
+}
+
+// logHandler records errors Wallet hits while talking to the web service
+func logHandler() gin.HandlerFunc {
+	return func (c *gin.Context) {
+		reqBody := struct {
+			Logs []string `json:"logs"`
+		}{}
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		for _, l := range reqBody.Logs {
+			log.Println("Apple Wallet: ", l)
+		}
+		c.Status(http.StatusOK)
+	}
+}
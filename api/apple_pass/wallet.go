@@ -0,0 +1,208 @@
+package apple_pass
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/external/my_stripe"
+	"github.com/johnyeocx/usual/server/passes"
+)
+
+// errNoSubAccess means the customer isn't on a live subscription for the pass
+var errNoSubAccess = errors.New("customer has no access to subscription")
+
+func toEntitlements(usages []models.UsageInfo) ([]passes.PassEntitlement) {
+	entitlements := []passes.PassEntitlement{}
+	for _, usage := range usages {
+		if usage.SubUsage.ID == 0 {
+			continue
+		}
+
+		remaining := int(usage.SubUsage.Amount.Int16) - usage.UsageCount
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		entitlements = append(entitlements, passes.PassEntitlement{
+			ProductName: usage.ProductName,
+			BusinessName: usage.BusinessName,
+			Title: usage.SubUsage.Title,
+			Unlimited: usage.SubUsage.Unlimited,
+			Remaining: remaining,
+			Interval: usage.SubUsage.Interval.String,
+		})
+	}
+	return entitlements
+}
+
+func getCustomerPass(sqlDB *sql.DB, cusId int) (*passes.CustomerPass, error) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+	u := db.UsageDB{DB: sqlDB}
+
+	cus, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := u.GetCusEntitlements(cus.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	subs := map[int]bool{}
+	for _, usage := range usages {
+		subs[usage.SubID] = true
+	}
+
+	return &passes.CustomerPass{
+		CusName: cus.FullName(),
+		CusUuid: cus.Uuid,
+		ActiveSubs: len(subs),
+		Entitlements: toEntitlements(usages),
+		Updated: time.Now(),
+	}, nil
+}
+
+func getSubscriptionPass(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	cusId int,
+	subId int,
+) (*passes.SubscriptionPass, error) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+	s := db.SubscriptionDB{DB: sqlDB}
+	u := db.UsageDB{DB: sqlDB}
+
+	cus, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := u.GetCusEntitlements(cus.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	subUsages := []models.UsageInfo{}
+	for _, usage := range usages {
+		if usage.SubID == subId {
+			subUsages = append(subUsages, usage)
+		}
+	}
+	if len(subUsages) == 0 {
+		return nil, errNoSubAccess
+	}
+
+	sub, err := s.GetSubPassInfo(subId)
+	if err != nil {
+		return nil, err
+	}
+
+	sp := passes.SubscriptionPass{
+		SerialNumber: passes.SubPassSerial(cus.Uuid, subId),
+		CusName: cus.FullName(),
+		CusUuid: cus.Uuid,
+		ProductName: sub.SubProduct.Product.Name,
+		BusinessName: *sub.BusinessName,
+		Entitlements: toEntitlements(subUsages),
+		Updated: time.Now(),
+	}
+
+	if sub.Cancelled || sub.StripeSubID == "" {
+		sp.Ends = true
+		if sub.Expires.Valid {
+			sp.Renews = &sub.Expires.Time
+		}
+	} else if stripeSub, err := my_stripe.GetSubscription(sub.StripeSubID); err == nil {
+		renews := time.Unix(stripeSub.CurrentPeriodEnd, 0)
+		sp.Renews = &renews
+	} else {
+		log.Printf("Failed to get renewal date for sub %d: %v\n", sub.ID, err)
+	}
+
+	// businesses without a profile image keep the usual logo
+	logoKey := fmt.Sprintf("./business/profile_image/%d", *sub.BusinessID)
+	if logo, err := store.Get(logoKey); err == nil {
+		sp.BusinessLogo = logo
+		sp.BusinessLogoURL, _ = store.PresignGet(logoKey, time.Hour * 24 * 7)
+	}
+
+	return &sp, nil
+}
+
+// issuePass builds the customer's membership card, or their pass for subId, in the
+// given wallet. Apple passes carry the saved pass's auth token and last change
+func issuePass(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	wallet passes.Wallet,
+	cusId int,
+	subId *int,
+	applePass *models.ApplePass,
+) (*passes.WalletPass, error) {
+	if subId == nil {
+		cp, err := getCustomerPass(sqlDB, cusId)
+		if err != nil {
+			return nil, err
+		}
+		if applePass != nil {
+			cp.AuthToken = applePass.AuthToken
+			cp.Updated = applePass.Updated
+		}
+		return wallet.CustomerPass(*cp)
+	}
+
+	sp, err := getSubscriptionPass(sqlDB, store, cusId, *subId)
+	if err != nil {
+		return nil, err
+	}
+	if applePass != nil {
+		sp.AuthToken = applePass.AuthToken
+		sp.Updated = applePass.Updated
+	}
+	return wallet.SubscriptionPass(*sp)
+}
+
+// GetGoogleWalletPass returns a link that saves the customer's membership card, or
+// their pass for subId, to Google Wallet
+func GetGoogleWalletPass(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	cusId int,
+	subId *int,
+) (*passes.WalletPass, *models.RequestError) {
+	wallet, err := passes.NewGoogleWalletFromEnv()
+	if err == passes.ErrGoogleWalletNotConfigured {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusServiceUnavailable,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	pass, err := issuePass(sqlDB, store, wallet, cusId, subId, nil)
+	if err == errNoSubAccess {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusForbidden,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return pass, nil
+}
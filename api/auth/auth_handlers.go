@@ -6,8 +6,8 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
 	"github.com/johnyeocx/usual/server/constants"
 	"github.com/johnyeocx/usual/server/db"
@@ -21,20 +21,20 @@ import (
 )
 
 // AUTH ROUTES
-func Routes(authRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
+func Routes(authRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
 	
 	authRouter.POST("/validate", validateTokenHandler(sqlDB))
 	authRouter.POST("/refresh_token", refreshTokenHandler(sqlDB))
 	authRouter.POST("/resend_email_otp", resendEmailOTPHandler(sqlDB))
 
-	authRouter.POST("/create_business", createBusinessHandler(sqlDB, s3Sess))
+	authRouter.POST("/create_business", createBusinessHandler(sqlDB, store))
 	authRouter.POST("/verify_email", verifyEmailHandler(sqlDB))
 	authRouter.POST("/login", loginHandler(sqlDB))
 	// authRouter.POST("/verify_msg_otp", verifyRegisterOTPHandler(conn))
 	// authRouter.POST("/register_user_details", registerUserDetailsHandler(conn))
 }
 
-func createBusinessHandler(sqlDB *sql.DB, s3Sess *session.Session) gin.HandlerFunc {
+func createBusinessHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		reqBody := models.BusinessDetails{}
@@ -54,7 +54,7 @@ func createBusinessHandler(sqlDB *sql.DB, s3Sess *session.Session) gin.HandlerFu
 
 		// 2. generate presigned url
 		key := "./business/profile_image/" + strconv.Itoa(int(*id))
-		url, err := cloud.GetImageUploadUrl(s3Sess, key)
+		url, err := store.PresignPut(key, time.Hour, "", 0)
 		if err != nil {
 			log.Printf("Failed to decode req body for register business details: %v\n", err)
 			c.JSON(http.StatusBadGateway, err)
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"mime/multipart"
 	"net/http"
+	"time"
 
+	"github.com/johnyeocx/usual/server/api/search"
 	"github.com/johnyeocx/usual/server/constants"
 	busdb "github.com/johnyeocx/usual/server/db/bus_db"
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/geocode"
 	"github.com/johnyeocx/usual/server/errors/bus_errors"
 	"github.com/johnyeocx/usual/server/external/my_stripe"
 	"github.com/johnyeocx/usual/server/utils/secure"
@@ -176,6 +179,7 @@ func setBusinessProfile(
 		}
 	}
 
+	search.BusinessChanged(sqlDB, businessId)
 	return  nil
 }
 
@@ -214,7 +218,63 @@ func updateBusinessCategory(
 
 	// 3. update sql
 	err = b.SetBusinessCategory(businessId, category)
-	return err
+	if err != nil {
+		return err
+	}
+
+	search.BusinessChanged(sqlDB, businessId)
+	return nil
+}
+
+// updateBusinessAddress only saves addresses the geocoder can place, so every saved
+// address shows up in nearby searches
+func updateBusinessAddress(
+	sqlDB *sql.DB,
+	geocoder geocode.Geocoder,
+	businessId int,
+	address models.Address,
+) (*models.Location, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	if address.Line1 == "" || address.City == "" || address.PostalCode == "" {
+		return nil, &models.RequestError{
+			Err: errors.New("line1, city and postal code are required"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 1. geocode in the business's country
+	business, err := b.GetBusinessByID(businessId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	address.Country = &business.Country
+
+	location, err := geocoder.Geocode(address)
+	if err == geocode.ErrNotFound {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadRequest,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("%s geocoder failed: %v", geocoder.Name(), err),
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 2. update sql
+	err = b.SetBusinessAddress(businessId, address, *location)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return location, nil
 }
 
 func updateBusinessName(
@@ -246,6 +306,8 @@ func updateBusinessName(
 			Err: err,
 		}
 	}
+
+	search.BusinessChanged(sqlDB, businessId)
 	return nil
 }
 
@@ -374,6 +436,31 @@ func updateBusinessUrl(
 	return err
 }
 
+func updateBusinessTimeZone(
+	sqlDB *sql.DB,
+	businessId int,
+	timeZone string,
+) (*models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	if _, err := time.LoadLocation(timeZone); err != nil || timeZone == "" || timeZone == "Local" {
+		return &models.RequestError{
+			Err: fmt.Errorf("invalid time zone %s", timeZone),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	err := b.SetBusinessTimeZone(businessId, timeZone)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return nil
+}
+
 func updateBusinessBankInfo(sqlDB *sql.DB, bId int, bankInfo models.BankInfo) (*models.BankAccount, *models.RequestError) {
 
 	b := busdb.BusinessDB{DB: sqlDB}
@@ -9,26 +9,47 @@ import (
 	"mime/multipart"
 	"net/http"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/notification"
+	"github.com/johnyeocx/usual/server/api/search"
 	busdb "github.com/johnyeocx/usual/server/db/bus_db"
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/external/geocode"
 	"github.com/johnyeocx/usual/server/external/media"
 	"github.com/johnyeocx/usual/server/utils/middleware"
 	"github.com/stripe/stripe-go/v74"
 )
 
 
-func Routes(businessRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
+func Routes(
+	businessRouter *gin.RouterGroup,
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	geocoder geocode.Geocoder,
+) {
 	businessRouter.GET("", getBusinessHandler(sqlDB))
 	businessRouter.GET("/total_and_payouts", getTotalAndPayoutsHandler(sqlDB))
 	businessRouter.GET("/transactions", getBusinessTransactionsHandler(sqlDB))
+	businessRouter.GET("/invoices", listHandler(sqlDB, listBusinessInvoices))
+	businessRouter.GET("/subscribers", listHandler(sqlDB, listBusinessSubs))
+	businessRouter.GET("/usages", listHandler(sqlDB, listBusinessUsages))
+	businessRouter.GET("/payouts", listHandler(sqlDB, listBusinessPayouts))
+	businessRouter.GET("/churn_reasons", getChurnReasonsHandler(sqlDB))
+	businessRouter.GET("/dunning_stats", getDunningStatsHandler(sqlDB))
+	businessRouter.GET("/dunning_settings", getDunningSettingsHandler(sqlDB))
 	businessRouter.GET("/email_taken/:email", checkBusinessEmailTaken(sqlDB))
+	businessRouter.GET("/reviews", listReviewsHandler(sqlDB))
 
-	businessRouter.POST("set_profile", setBusinessProfileHandler(sqlDB, s3Sess))
+	businessRouter.POST("set_profile", setBusinessProfileHandler(sqlDB, store))
 	businessRouter.POST("set_description", updateBusinessDescriptionHandler(sqlDB))
 
 	businessRouter.POST("identity_document", uploadIdentityDocumentHandler(sqlDB))
+	businessRouter.POST("image_upload", startImageUploadHandler(sqlDB, store))
+	businessRouter.POST("image_upload/:uploadId/confirm", confirmImageUploadHandler(sqlDB, store))
+	businessRouter.POST("reviews/:reviewId/flag", flagReviewHandler(sqlDB))
+	businessRouter.PUT("reviews/:reviewId/reply", replyToReviewHandler(sqlDB))
+	businessRouter.DELETE("reviews/:reviewId/reply", deleteReviewReplyHandler(sqlDB))
 	
 	businessRouter.PATCH("account/category", updateBusinessCategoryHandler(sqlDB))
 	businessRouter.PATCH("account/name", updateBusinessNameHandler(sqlDB))
@@ -37,6 +58,9 @@ func Routes(businessRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Sess
 	businessRouter.PATCH("account/password", updateBusinessPasswordHandler(sqlDB))
 	businessRouter.PATCH("account/personal_info", setPersonalInfoHandler(sqlDB))
 	businessRouter.PATCH("account/bank_account", updateBusinessBankAccountHandler(sqlDB))
+	businessRouter.PATCH("account/dunning_settings", updateDunningSettingsHandler(sqlDB))
+	businessRouter.PATCH("account/time_zone", updateBusinessTimeZoneHandler(sqlDB))
+	businessRouter.PATCH("account/address", updateBusinessAddressHandler(sqlDB, geocoder))
 	
 
 	businessRouter.PATCH("account/description", updateBusinessDescriptionHandler(sqlDB))
@@ -48,6 +72,8 @@ func Routes(businessRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Sess
 
 	businessRouter.PATCH("subscription_product/description", setProductDescriptionHandler(sqlDB))
 	businessRouter.PATCH("subscription_product/subscription_pricing", setSubProductPricingHandler(sqlDB))
+
+	notification.InboxRoutes(businessRouter.Group("/notifications"), sqlDB, notification.BusinessRecipient)
 }
 
 func checkBusinessEmailTaken(sqlDB *sql.DB) gin.HandlerFunc {
@@ -117,7 +143,7 @@ func getBusinessTransactionsHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func setBusinessProfileHandler(sqlDB *sql.DB, s3Sess *session.Session)  gin.HandlerFunc {
+func setBusinessProfileHandler(sqlDB *sql.DB, store cloud.ObjectStore)  gin.HandlerFunc {
 	return func (c *gin.Context) {
 		businessId, err := middleware.AuthenticateBId(c, sqlDB)
 		if err != nil {
@@ -167,7 +193,7 @@ func setBusinessProfileHandler(sqlDB *sql.DB, s3Sess *session.Session)  gin.Hand
 		}
 		
 		// 4. Create Business QR
-		media.GenerateSubscribeQRCode(s3Sess, *businessId)
+		media.GenerateSubscribeQRCode(store, *businessId)
 		
 		c.JSON(200, nil)
 	}
@@ -286,10 +312,51 @@ func updateBusinessDescriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return;
 		}
 
+		search.BusinessChanged(sqlDB, *businessId)
+
 		c.JSON(200, nil)
 	}
 }
 
+func updateBusinessAddressHandler(sqlDB *sql.DB, geocoder geocode.Geocoder) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			Line1		string `json:"line1"`
+			Line2		string `json:"line2"`
+			City		string `json:"city"`
+			PostalCode	string `json:"postal_code"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(400, err)
+			return
+		}
+
+		location, reqErr := updateBusinessAddress(sqlDB, geocoder, *businessId, models.Address{
+			Line1: reqBody.Line1,
+			Line2: reqBody.Line2,
+			City: reqBody.City,
+			PostalCode: reqBody.PostalCode,
+		})
+		if reqErr != nil {
+			log.Printf("Failed to update business address: %v\n", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(200, map[string]interface{}{
+			"location": location,
+		})
+	}
+}
+
 func updateBusinessCategoryHandler(sqlDB *sql.DB) gin.HandlerFunc {
 
 	return func (c *gin.Context) {
@@ -319,6 +386,35 @@ func updateBusinessCategoryHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
+func updateBusinessTimeZoneHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			TimeZone	string `json:"time_zone"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(400, err)
+			return
+		}
+
+		reqErr := updateBusinessTimeZone(sqlDB, *businessId, reqBody.TimeZone)
+		if reqErr != nil {
+			log.Printf("Failed to update business time zone: %v\n", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(200, nil)
+	}
+}
+
 func updateBusinessUrlHandler(sqlDB *sql.DB) gin.HandlerFunc {
 
 	return func (c *gin.Context) {
@@ -0,0 +1,335 @@
+package business
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/imaging"
+)
+
+var (
+	maxImageSize int64 = 10 << 20
+	maxGalleryImages = 10
+	imageUploadExpiry = 15 * time.Minute
+	imageContentTypes = map[string]string{
+		"image/jpeg": "jpeg",
+		"image/png": "png",
+	}
+
+	// longest side in pixels of each variant
+	thumbnailSide = 200
+	mediumSide = 800
+	largeSide = 1600
+)
+
+// StartImageUpload opens an upload session. The url it returns only accepts an upload
+// of exactly the declared type and size
+func StartImageUpload(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	target models.ImageTarget,
+	productId *int,
+	contentType string,
+	size int64,
+) (map[string]interface{}, *models.RequestError) {
+	if _, ok := imageContentTypes[contentType]; !ok {
+		return nil, &models.RequestError{
+			Err: imaging.ErrUnsupportedFormat,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if size <= 0 || size > maxImageSize {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("image must be between 1 and %d bytes", maxImageSize),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 1. check the target
+	switch target {
+	case models.ImageTargetBusiness:
+		productId = nil
+	case models.ImageTargetProduct, models.ImageTargetGallery:
+		if productId == nil {
+			return nil, &models.RequestError{
+				Err: errors.New("missing product id"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		b := db.BusinessDB{DB: sqlDB}
+		if _, err := b.BusinessOwnsProduct(businessId, *productId); err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusForbidden,
+			}
+		}
+
+		if target == models.ImageTargetGallery {
+			if reqErr := checkGallerySpace(sqlDB, *productId); reqErr != nil {
+				return nil, reqErr
+			}
+		}
+	default:
+		return nil, &models.RequestError{
+			Err: errors.New("invalid image target"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 2. create the session
+	i := db.ImageDB{DB: sqlDB}
+	upload := models.ImageUpload{
+		UploadID: uuid.NewString(),
+		BusinessID: businessId,
+		Target: target,
+		ProductID: productId,
+		ContentType: contentType,
+		Size: size,
+		Status: models.ImageUploadPending,
+		Expires: time.Now().Add(imageUploadExpiry),
+	}
+	upload.StagingKey = "uploads/" + upload.UploadID
+
+	if err := i.InsertImageUpload(upload); err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	url, err := store.PresignPut(upload.StagingKey, imageUploadExpiry, contentType, size)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return map[string]interface{}{
+		"upload_id": upload.UploadID,
+		"upload_url": url,
+		"expires": upload.Expires,
+		"max_size": maxImageSize,
+	}, nil
+}
+
+// ConfirmImageUpload checks what was uploaded really is the image that was declared,
+// then stores resized copies without any metadata and points the business or product
+// at them. Gallery uploads are added to the end of the gallery with altText
+func ConfirmImageUpload(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	uploadId string,
+	altText string,
+) (*models.ImageVariants, *models.RequestError) {
+	i := db.ImageDB{DB: sqlDB}
+
+	// 1. claim the session so a second confirm of the same upload can't run alongside
+	upload, err := i.ClaimImageUpload(uploadId, businessId)
+	if err == sql.ErrNoRows {
+		existing, err := i.GetImageUpload(uploadId, businessId)
+		if err == sql.ErrNoRows {
+			return nil, &models.RequestError{
+				Err: errors.New("upload not found"),
+				StatusCode: http.StatusNotFound,
+			}
+		} else if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadGateway,
+			}
+		}
+
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("upload already %s", existing.Status),
+			StatusCode: http.StatusConflict,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 2. get what was uploaded, handing the session back if the client can still retry
+	data, err := store.Get(upload.StagingKey)
+	if err != nil {
+		setImageUploadStatus(&i, uploadId, models.ImageUploadPending)
+	}
+	if err == cloud.ErrObjectNotFound {
+		return nil, &models.RequestError{
+			Err: errors.New("nothing has been uploaded yet"),
+			StatusCode: http.StatusBadRequest,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if upload.Target == models.ImageTargetGallery {
+		if reqErr := checkGallerySpace(sqlDB, *upload.ProductID); reqErr != nil {
+			store.Delete(upload.StagingKey)
+			setImageUploadStatus(&i, uploadId, models.ImageUploadFailed)
+			return nil, reqErr
+		}
+	}
+
+	images, err := storeImageVariants(store, upload, data)
+	store.Delete(upload.StagingKey)
+	if err != nil {
+		setImageUploadStatus(&i, uploadId, models.ImageUploadFailed)
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 3. point the business or product at the new variants
+	var prev *models.ImageVariants
+	switch upload.Target {
+	case models.ImageTargetProduct:
+		prev, err = i.SetProductImages(businessId, *upload.ProductID, *images)
+	case models.ImageTargetGallery:
+		_, err = i.InsertProductImage(*upload.ProductID, *images, altText)
+	default:
+		prev, err = i.SetBusinessImages(businessId, *images)
+	}
+	if err != nil {
+		DeleteImageVariants(store, images)
+		setImageUploadStatus(&i, uploadId, models.ImageUploadFailed)
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	setImageUploadStatus(&i, uploadId, models.ImageUploadConfirmed)
+	DeleteImageVariants(store, prev)
+
+	return images, nil
+}
+
+func setImageUploadStatus(i *db.ImageDB, uploadId string, status models.ImageUploadStatus) {
+	if err := i.SetImageUploadStatus(uploadId, status); err != nil {
+		log.Printf("Failed to mark image upload %s %s: %v\n", uploadId, status, err)
+	}
+}
+
+// storeImageVariants decodes the upload and puts each resized copy in the store. The
+// large copy also goes to the old single image key so passes and older clients
+// still find it
+func storeImageVariants(
+	store cloud.ObjectStore,
+	upload *models.ImageUpload,
+	data []byte,
+) (*models.ImageVariants, error) {
+	if int64(len(data)) != upload.Size {
+		return nil, fmt.Errorf("upload should be %d bytes, got %d", upload.Size, len(data))
+	}
+	if http.DetectContentType(data) != upload.ContentType {
+		return nil, fmt.Errorf("upload is not %s", upload.ContentType)
+	}
+
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("business/%d/images/%s", upload.BusinessID, upload.UploadID)
+	legacyKey := "./business/profile_image/" + strconv.Itoa(upload.BusinessID)
+	switch upload.Target {
+	case models.ImageTargetProduct:
+		prefix = fmt.Sprintf("business/%d/product/%d/images/%s",
+			upload.BusinessID, *upload.ProductID, upload.UploadID)
+		legacyKey = "./business/product_image/" + strconv.Itoa(*upload.ProductID)
+	case models.ImageTargetGallery:
+		// gallery images are new, nothing older reads them
+		prefix = fmt.Sprintf("business/%d/product/%d/gallery/%s",
+			upload.BusinessID, *upload.ProductID, upload.UploadID)
+		legacyKey = ""
+	}
+
+	images := models.ImageVariants{}
+	variants := []struct {
+		side 	int
+		key 	*string
+		name 	string
+	}{
+		{thumbnailSide, &images.Thumbnail, "thumbnail"},
+		{mediumSide, &images.Medium, "medium"},
+		{largeSide, &images.Large, "large"},
+	}
+
+	var large []byte
+	var largeType string
+	for _, v := range variants {
+		b, contentType, err := imaging.Encode(imaging.Fit(img, v.side), format)
+		if err != nil {
+			DeleteImageVariants(store, &images)
+			return nil, err
+		}
+
+		key := prefix + "/" + v.name + "." + format
+		if err := store.Put(key, b, contentType); err != nil {
+			DeleteImageVariants(store, &images)
+			return nil, err
+		}
+		*v.key = key
+		large, largeType = b, contentType
+	}
+
+	if legacyKey != "" {
+		if err := store.Put(legacyKey, large, largeType); err != nil {
+			log.Printf("Failed to update legacy image %s: %v\n", legacyKey, err)
+		}
+	}
+	return &images, nil
+}
+
+func checkGallerySpace(sqlDB *sql.DB, productId int) (*models.RequestError) {
+	i := db.ImageDB{DB: sqlDB}
+
+	gallery, err := i.GetProductGallery(productId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if len(gallery) >= maxGalleryImages {
+		return &models.RequestError{
+			Err: fmt.Errorf("a product can have at most %d images", maxGalleryImages),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// DeleteImageVariants removes every stored size of an image
+func DeleteImageVariants(store cloud.ObjectStore, images *models.ImageVariants) {
+	if images == nil {
+		return
+	}
+
+	for _, key := range []string{images.Thumbnail, images.Medium, images.Large} {
+		if key != "" {
+			store.Delete(key)
+		}
+	}
+}
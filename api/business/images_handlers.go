@@ -0,0 +1,68 @@
+package business
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func startImageUploadHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			Target 			models.ImageTarget 	`json:"target"`
+			ProductID 		*int 				`json:"product_id"`
+			ContentType 	string 				`json:"content_type"`
+			Size 			int64 				`json:"size"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		res, reqErr := StartImageUpload(
+			sqlDB, store, *businessId, reqBody.Target, reqBody.ProductID, reqBody.ContentType, reqBody.Size,
+		)
+		if reqErr != nil {
+			log.Println("Failed to start image upload:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func confirmImageUploadHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		images, reqErr := ConfirmImageUpload(sqlDB, store, *businessId, c.Param("uploadId"), "")
+		if reqErr != nil {
+			log.Println("Failed to confirm image upload:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		cloud.PresignImages(store, images)
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"images": images,
+		})
+	}
+}
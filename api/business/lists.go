@@ -0,0 +1,78 @@
+package business
+
+import (
+	"database/sql"
+
+	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/errors/bus_errors"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+func listBusinessInvoices(
+	sqlDB *sql.DB,
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) (*models.Page, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	invoices, next, err := b.ListBusinessInvoices(businessId, filter, page)
+	if err != nil {
+		return nil, bus_errors.GetInvoicesFailedErr(err)
+	}
+
+	res := pagination.NewPage(invoices, next)
+	return &res, nil
+}
+
+func listBusinessSubs(
+	sqlDB *sql.DB,
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) (*models.Page, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	subInfos, next, err := b.ListBusinessSubs(businessId, filter, page)
+	if err != nil {
+		return nil, bus_errors.GetSubsFailedErr(err)
+	}
+
+	res := pagination.NewPage(subInfos, next)
+	return &res, nil
+}
+
+func listBusinessUsages(
+	sqlDB *sql.DB,
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) (*models.Page, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	usageInfos, next, err := b.ListBusinessUsages(businessId, filter, page)
+	if err != nil {
+		return nil, bus_errors.GetUsagesFailedErr(err)
+	}
+
+	res := pagination.NewPage(usageInfos, next)
+	return &res, nil
+}
+
+func listBusinessPayouts(
+	sqlDB *sql.DB,
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) (*models.Page, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	payouts, next, err := b.ListBusinessPayouts(businessId, filter, page)
+	if err != nil {
+		return nil, bus_errors.GetPayoutsFailedErr(err)
+	}
+
+	res := pagination.NewPage(payouts, next)
+	return &res, nil
+}
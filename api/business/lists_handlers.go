@@ -0,0 +1,48 @@
+package business
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/analytics"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+type listFunc func(*sql.DB, int, *models.ListFilter, *models.PageParams) (*models.Page, *models.RequestError)
+
+// listHandler reads the page and filters, with dates in the business's time zone
+func listHandler(sqlDB *sql.DB, list listFunc) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		loc, err := analytics.BusinessLocation(sqlDB, *businessId)
+		if err != nil {
+			log.Println("Failed to get business location:", err)
+			c.JSON(http.StatusBadGateway, err)
+			return
+		}
+
+		page, filter, reqErr := pagination.FromQuery(c, loc)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, reqErr := list(sqlDB, *businessId, filter, page)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,92 @@
+package business
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+var maxReplyLength = 2000
+
+// ListBusinessReviews pages through every review of the business's products, including
+// hidden ones so the business can see what was taken down
+func ListBusinessReviews(
+	sqlDB *sql.DB,
+	businessId int,
+	productId *int,
+	page *models.PageParams,
+) (map[string]interface{}, *models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	summary, err := r.GetRatingSummary(&businessId, productId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	reviews, next, err := r.ListReviews(&businessId, productId, false, page)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	res := pagination.NewPage(reviews, next)
+	return map[string]interface{}{
+		"rating": summary,
+		"reviews": res.Items,
+		"next_cursor": res.NextCursor,
+	}, nil
+}
+
+// ReplyToReview sets the business's public reply to a review of one of its products,
+// a nil reply removes it
+func ReplyToReview(
+	sqlDB *sql.DB,
+	businessId int,
+	reviewId int,
+	reply *string,
+) (*models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	if reply != nil {
+		trimmed := strings.TrimSpace(*reply)
+		if trimmed == "" {
+			return &models.RequestError{
+				Err: errors.New("reply is empty"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		if utf8.RuneCountInString(trimmed) > maxReplyLength {
+			return &models.RequestError{
+				Err: fmt.Errorf("reply can be at most %d characters", maxReplyLength),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		reply = &trimmed
+	}
+
+	err := r.SetReviewReply(reviewId, businessId, reply)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("review not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package business
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/c/review"
+	"github.com/johnyeocx/usual/server/constants"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+func listReviewsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		var productId *int
+		if p := c.Query("product_id"); p != "" {
+			id, err := strconv.Atoi(p)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, "invalid product_id")
+				return
+			}
+			productId = &id
+		}
+
+		res, reqErr := ListBusinessReviews(sqlDB, *businessId, productId, page)
+		if reqErr != nil {
+			log.Println("Failed to list business reviews: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func replyToReviewHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqBody := struct {
+			Reply	string	`json:"reply"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := ReplyToReview(sqlDB, *businessId, reviewId, &reqBody.Reply)
+		if reqErr != nil {
+			log.Println("Failed to reply to review: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func deleteReviewReplyHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := ReplyToReview(sqlDB, *businessId, reviewId, nil)
+		if reqErr != nil {
+			log.Println("Failed to remove review reply: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func flagReviewHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqBody := struct {
+			Reason	my_enums.ReviewFlagReason	`json:"reason"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := review.FlagReview(sqlDB, constants.UserTypes.Business, *businessId, reviewId, reqBody.Reason)
+		if reqErr != nil {
+			log.Println("Failed to flag review: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
@@ -3,7 +3,11 @@ package business
 import (
 	"database/sql"
 
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db"
 	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/errors/bus_errors"
 )
 
 func getBusinessStats(
@@ -31,12 +35,18 @@ func getBusinessStats(
 	if err != nil {
 		return nil, err
 	}
+
+	capacities, err := b.GetBusinessPlanCapacities(businessId)
+	if err != nil {
+		return nil, err
+	}
 	
 	return map[string]interface{}{
 		"sub_infos": subInfos,
 		"invoices": invoices,
 		"usage_infos": usageInfos,
 		"bank_accounts": bankAccounts,
+		"plan_capacities": capacities,
 	}, nil
 }
 
@@ -57,4 +67,38 @@ func GetTotalAndPayouts (
 	return map[string]interface{}{
 		"total": total,
 	}, err
-}
\ No newline at end of file
+}
+
+func GetChurnReasons(
+	sqlDB *sql.DB,
+	busId int,
+) (map[string]interface{}, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+	reasons, err := b.GetBusinessChurnReasons(busId)
+	if err != nil {
+		return nil, bus_errors.GetChurnReasonsFailedErr(err)
+	}
+
+	totals := map[my_enums.CancellationReason]int{}
+	for _, r := range reasons {
+		totals[r.Reason] += r.Count
+	}
+
+	return map[string]interface{}{
+		"totals": totals,
+		"by_product": reasons,
+	}, nil
+}
+
+func GetDunningStats(
+	sqlDB *sql.DB,
+	busId int,
+) (*models.DunningStats, *models.RequestError) {
+	d := db.DunningDB{DB: sqlDB}
+	stats, err := d.GetBusinessDunningStats(busId)
+	if err != nil {
+		return nil, bus_errors.GetDunningStatsFailedErr(err)
+	}
+
+	return stats, nil
+}
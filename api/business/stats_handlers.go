@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/dunning"
+	"github.com/johnyeocx/usual/server/db/models"
 	"github.com/johnyeocx/usual/server/utils/middleware"
 )
 
@@ -27,4 +29,86 @@ func getTotalAndPayoutsHandler(sqlDB *sql.DB) gin.HandlerFunc {
 
 		c.JSON(200, res)
 	}
+}
+
+func getChurnReasonsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetChurnReasons(sqlDB, *businessId)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(200, res)
+	}
+}
+
+func getDunningStatsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetDunningStats(sqlDB, *businessId)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(200, res)
+	}
+}
+
+func getDunningSettingsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, err := dunning.GetDunningSettings(sqlDB, *businessId)
+		if err != nil {
+			log.Println("Failed to get dunning settings:", err)
+			c.JSON(http.StatusBadGateway, err)
+			return
+		}
+
+		c.JSON(200, res)
+	}
+}
+
+func updateDunningSettingsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := models.DunningSettings{}
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := dunning.UpdateDunningSettings(sqlDB, *businessId, reqBody)
+		if reqErr != nil {
+			log.Println("Failed to update dunning settings:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(200, nil)
+	}
 }
\ No newline at end of file
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/search"
 	"github.com/johnyeocx/usual/server/db"
 	"github.com/johnyeocx/usual/server/db/models"
 	"github.com/johnyeocx/usual/server/utils/middleware"
@@ -39,6 +40,8 @@ func setProductDescriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		search.ProductChanged(sqlDB, reqBody.ProductID)
+
 		c.JSON(200, nil)
 	}
 }
@@ -8,7 +8,6 @@ import (
 	"time"
 
 	firebase "firebase.google.com/go"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
 	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/external/cloud"
@@ -17,11 +16,11 @@ import (
 )
 
 // AUTH ROUTES
-func Routes(authRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session, fbApp *firebase.App) {
+func Routes(authRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore, fbApp *firebase.App) {
 	
 
-	authRouter.GET("/pkpass", getPkPassPresignedUrlHandler(sqlDB, s3Sess))
-	authRouter.GET("/qr", getQRPresignedUrlHandler(sqlDB, s3Sess))
+	authRouter.GET("/pkpass", getPkPassPresignedUrlHandler(sqlDB, store))
+	authRouter.GET("/qr", getQRPresignedUrlHandler(sqlDB, store))
 
 	
 	
@@ -31,7 +30,7 @@ func Routes(authRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session,
 	authRouter.POST("/login", loginHandler(sqlDB))
 }
 
-func getPkPassPresignedUrlHandler(sqlDB *sql.DB, s3sess *session.Session) gin.HandlerFunc {
+func getPkPassPresignedUrlHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		cusId, err := middleware.AuthenticateCId(c, sqlDB)
 
@@ -43,7 +42,7 @@ func getPkPassPresignedUrlHandler(sqlDB *sql.DB, s3sess *session.Session) gin.Ha
 		key := fmt.Sprintf("customer/pkpass/%d.pkpass", *cusId)
 		fmt.Println("KEY:", key)
 
-		url, err := cloud.GetObjectPresignedURL(s3sess, key, time.Minute)
+		url, err := store.PresignGet(key, time.Minute)
 		if err != nil {
 			c.JSON(http.StatusBadGateway, err)
 			return
@@ -53,7 +52,7 @@ func getPkPassPresignedUrlHandler(sqlDB *sql.DB, s3sess *session.Session) gin.Ha
 	}
 }
 
-func getQRPresignedUrlHandler(sqlDB *sql.DB, s3sess *session.Session) gin.HandlerFunc {
+func getQRPresignedUrlHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		cusId, err := middleware.AuthenticateCId(c, sqlDB)
 
@@ -64,7 +63,7 @@ func getQRPresignedUrlHandler(sqlDB *sql.DB, s3sess *session.Session) gin.Handle
 
 		key := fmt.Sprintf("customer/profile_qr/%d", *cusId)
 
-		url, err := cloud.GetObjectPresignedURL(s3sess, key, time.Hour)
+		url, err := store.PresignGet(key, time.Hour)
 		if err != nil {
 			c.JSON(http.StatusBadGateway, err)
 			return
@@ -7,38 +7,200 @@ import (
 	"github.com/johnyeocx/usual/server/db"
 	busdb "github.com/johnyeocx/usual/server/db/bus_db"
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/pagination"
 )
 
-func GetExploreData(sqlDB *sql.DB) ([]models.ExploreResult, error) {
+// GetExploreData is personalised for a signed in customer once their recommendations
+// have been computed, with the most subscribed products they weren't recommended
+// following on. Everyone else just gets the most subscribed products. Either way
+// businesses close to near are favoured when it's given
+func GetExploreData(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	cusId *int,
+	page *models.PageParams,
+	near *models.Location,
+) (*models.Page, error) {
 	b := db.BusinessDB{DB: sqlDB}
+	r := db.RecommendationDB{DB: sqlDB}
 
-	res, err := b.GetBusinessWithTopSubbedProduct()
+	personalised := false
+	if cusId != nil {
+		has, err := r.HasRecommendations(*cusId)
+		if err != nil {
+			return nil, err
+		}
+		personalised = has
+	}
+
+	offset := pagination.Offset(page)
+	var res []models.ExploreResult
+	var err error
+	if personalised {
+		res, err = getPersonalisedExplore(b, r, *cusId, offset, page.Limit + 1, near)
+	} else {
+		res, err = b.GetBusinessWithTopSubbedProduct(offset, page.Limit + 1, near, nil)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	presignResults(store, res)
+	return offsetPage(res, offset, page.Limit), nil
+}
+
+// getPersonalisedExplore pages through the customer's recommendations and then the
+// global list without them, as if they were one list
+func getPersonalisedExplore(
+	b db.BusinessDB,
+	r db.RecommendationDB,
+	cusId int,
+	offset int,
+	limit int,
+	near *models.Location,
+) ([]models.ExploreResult, error) {
+	res, err := r.GetRecommendedProducts(cusId, offset, limit, near)
+	if err != nil || len(res) == limit {
+		return res, err
+	}
 
-	return res, nil
+	recCount, err := r.CountRecommendedProducts(cusId)
+	if err != nil {
+		return nil, err
+	}
+
+	globalOffset := offset - recCount
+	if globalOffset < 0 {
+		globalOffset = 0
+	}
+
+	more, err := b.GetBusinessWithTopSubbedProduct(globalOffset, limit - len(res), near, &cusId)
+	if err != nil {
+		return nil, err
+	}
+	return append(res, more...), nil
 }
 
-func SearchAccounts(sqlDB *sql.DB, query string) ([]models.Business, error) {
-	b := db.BusinessDB{DB: sqlDB}
-	accounts, err := b.SearchAccounts(query)
+func SearchAccounts(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	query string,
+	sort models.SearchSort,
+	page *models.PageParams,
+) (*models.Page, error) {
+	s := db.SearchDB{DB: sqlDB}
+
+	offset := pagination.Offset(page)
+	accounts, err := s.SearchAccounts(query, sort, offset, page.Limit + 1)
 	if err != nil {
 		return nil, err
 	}
 
-	return accounts, nil
+	for i := range accounts {
+		cloud.PresignImages(store, accounts[i].Images)
+	}
+
+	var next *models.Cursor
+	if len(accounts) > page.Limit {
+		accounts = accounts[:page.Limit]
+		next = &models.Cursor{Offset: offset + page.Limit}
+	}
+
+	res := pagination.NewPage(accounts, next)
+	return &res, nil
 }
 
-func SearchSubProducts(sqlDB *sql.DB, query string) ([]models.ExploreResult, error) {
+func SearchSubProducts(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	query string,
+	near *models.Location,
+	sort models.SearchSort,
+	page *models.PageParams,
+) (*models.Page, error) {
+	s := db.SearchDB{DB: sqlDB}
+
+	offset := pagination.Offset(page)
+	res, err := s.SearchSubProducts(query, near, sort, offset, page.Limit + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. attach each product's gallery
+	productIds := []int{}
+	for _, r := range res {
+		productIds = append(productIds, r.SubProduct.Product.ProductID)
+	}
+
+	i := db.ImageDB{DB: sqlDB}
+	galleries, err := i.GetProductGalleries(productIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for j := range res {
+		res[j].SubProduct.Product.Gallery = galleries[res[j].SubProduct.Product.ProductID]
+	}
+
+	presignResults(store, res)
+	return offsetPage(res, offset, page.Limit), nil
+}
+
+// GetNearbyBusinesses pages through located businesses closest first
+func GetNearbyBusinesses(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	q models.GeoQuery,
+	page *models.PageParams,
+) (*models.Page, error) {
 	b := db.BusinessDB{DB: sqlDB}
-	res, err := b.SearchSubProducts(query)
+
+	offset := pagination.Offset(page)
+	businesses, err := b.GetNearbyBusinesses(q, offset, page.Limit + 1)
 	if err != nil {
 		return nil, err
 	}
 
-	return res, nil
+	for i := range businesses {
+		cloud.PresignImages(store, businesses[i].Images)
+	}
+
+	var next *models.Cursor
+	if len(businesses) > page.Limit {
+		businesses = businesses[:page.Limit]
+		next = &models.Cursor{Offset: offset + page.Limit}
+	}
+
+	res := pagination.NewPage(businesses, next)
+	return &res, nil
+}
+
+// presignResults turns the image keys in explore results into urls
+func presignResults(store cloud.ObjectStore, res []models.ExploreResult) {
+	for i := range res {
+		cloud.PresignImages(store, res[i].Business.Images)
+		presignProduct(store, &res[i].SubProduct.Product)
+	}
+}
+
+func presignProduct(store cloud.ObjectStore, product *models.Product) {
+	cloud.PresignImages(store, product.Images)
+	for i := range product.Gallery {
+		cloud.PresignImages(store, &product.Gallery[i].Images)
+	}
+}
+
+// offsetPage trims the extra row read to tell whether there's more
+func offsetPage(res []models.ExploreResult, offset int, limit int) (*models.Page) {
+	var next *models.Cursor
+	if len(res) > limit {
+		res = res[:limit]
+		next = &models.Cursor{Offset: offset + limit}
+	}
+
+	page := pagination.NewPage(res, next)
+	return &page
 }
 
 func GetBusiness(sqlDB *sql.DB, businessId int) (*models.Business, *models.RequestError) {
@@ -56,7 +218,11 @@ func GetBusiness(sqlDB *sql.DB, businessId int) (*models.Business, *models.Reque
 	return business, nil
 }
 
-func GetBusinessSubProducts(sqlDB *sql.DB, businessId int) (map[string]interface{}, error) {
+func GetBusinessSubProducts(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+) (map[string]interface{}, error) {
 
 	b := db.BusinessDB{DB: sqlDB}
 
@@ -65,6 +231,7 @@ func GetBusinessSubProducts(sqlDB *sql.DB, businessId int) (map[string]interface
 	if err != nil {
 		return nil, err
 	}
+	cloud.PresignImages(store, business.Images)
 
 	// 2. Get products
 	subProducts, err := b.GetCBusinessSubProducts(businessId)
@@ -72,6 +239,10 @@ func GetBusinessSubProducts(sqlDB *sql.DB, businessId int) (map[string]interface
 		return nil, err
 	}
 
+	for i := range *subProducts {
+		presignProduct(store, &(*subProducts)[i].Product)
+	}
+
 	// 3. Get product categories
 	productCats, err := b.GetBusinessProductCategories(businessId)
 	if err != nil {
@@ -86,7 +257,7 @@ func GetBusinessSubProducts(sqlDB *sql.DB, businessId int) (map[string]interface
 	}, nil
 }
 
-func GetCSubProduct(sqlDB *sql.DB, productId int) (*models.SubscriptionProduct, error) {
+func GetCSubProduct(sqlDB *sql.DB, store cloud.ObjectStore, productId int) (*models.SubscriptionProduct, error) {
 
 	b := db.BusinessDB{DB: sqlDB}
 
@@ -104,6 +275,16 @@ func GetCSubProduct(sqlDB *sql.DB, productId int) (*models.SubscriptionProduct,
 
 	subProduct.SubPlan.Usages = usages
 
+	// 3. Get gallery
+	i := db.ImageDB{DB: sqlDB}
+	gallery, err := i.GetProductGallery(productId)
+	if err != nil {
+		return nil, err
+	}
+
+	subProduct.Product.Gallery = gallery
+	presignProduct(store, &subProduct.Product)
+
 
 	return subProduct, nil
 }
\ No newline at end of file
@@ -8,27 +8,49 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
 )
 
 
-func Routes(businessRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
+func Routes(businessRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
 	// businessRouter.GET("", getBusinessHandler(sqlDB))
-	businessRouter.GET("/explore", getExploreDataHandler(sqlDB))
+	businessRouter.GET("/explore", getExploreDataHandler(sqlDB, store))
+	businessRouter.GET("/nearby", getNearbyBusinessesHandler(sqlDB, store))
 
 	businessRouter.GET("/:id", getBusinessHandler(sqlDB))
-	businessRouter.GET("/data/:id", getBusinessDataHandler(sqlDB))
+	businessRouter.GET("/data/:id", getBusinessDataHandler(sqlDB, store))
 	
-	businessRouter.GET("/accounts", accountSearch(sqlDB))
-	businessRouter.GET("/sub_products", searchSubProductsHandler(sqlDB))
+	businessRouter.GET("/accounts", accountSearch(sqlDB, store))
+	businessRouter.GET("/sub_products", searchSubProductsHandler(sqlDB, store))
 
-	businessRouter.GET("/sub_product/:id", getSubProductHandler(sqlDB))
+	businessRouter.GET("/sub_product/:id", getSubProductHandler(sqlDB, store))
 }
 
-func getExploreDataHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func getExploreDataHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
-		res, err := GetExploreData(sqlDB)
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		near, reqErr := parseNear(c)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		// explore is open to everyone, signed in customers get their own feed
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			cusId = nil
+		}
+
+		res, err := GetExploreData(sqlDB, store, cusId, page, near)
 		if err != nil {
 			c.JSON(http.StatusBadGateway, err)
 			return
@@ -38,15 +60,52 @@ func getExploreDataHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func accountSearch(sqlDB *sql.DB) gin.HandlerFunc {
+func getNearbyBusinessesHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
-		query := c.Query("q")
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		q, reqErr := parseGeoQuery(c)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, err := GetNearbyBusinesses(sqlDB, store, *q, page)
+		if err != nil {
+			log.Println("Failed to get nearby businesses: ", err)
+			c.JSON(http.StatusBadGateway, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func accountSearch(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
 		if query == "" {
 			c.JSON(http.StatusBadRequest, errors.New("query field empty"))
 			return
 		}
 		
-		accounts, err := SearchAccounts(sqlDB, strings.ToLower(query))
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		sort, reqErr := parseSearchSort(c)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, err := SearchAccounts(sqlDB, store, query, sort, page)
 		if err != nil {
 			log.Println("Failed to search for accounts: ", err)
 			c.JSON(http.StatusBadGateway, err)
@@ -54,21 +113,40 @@ func accountSearch(sqlDB *sql.DB) gin.HandlerFunc {
 		}
 
 		c.JSON(http.StatusOK, map[string]interface{}{
-			"accounts": accounts,
+			"accounts": res.Items,
+			"next_cursor": res.NextCursor,
 		})
 		
 	}
 }
 
-func searchSubProductsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func searchSubProductsHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
-		query := c.Query("q")
+		query := strings.TrimSpace(c.Query("q"))
 		if query == "" {
 			c.JSON(http.StatusBadRequest, errors.New("query field empty"))
 			return
 		}
 		
-		res, err := SearchSubProducts(sqlDB, strings.ToLower(query))
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+		
+		near, reqErr := parseNear(c)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		sort, reqErr := parseSearchSort(c)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, err := SearchSubProducts(sqlDB, store, query, near, sort, page)
 		if err != nil {
 			log.Println("Failed to search for sub products: ", err)
 			c.JSON(http.StatusBadGateway, err)
@@ -81,7 +159,7 @@ func searchSubProductsHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func getBusinessDataHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func getBusinessDataHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		businessId := c.Param("id")
 		businessIdInt, err := strconv.Atoi(businessId)
@@ -90,7 +168,7 @@ func getBusinessDataHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		res, err := GetBusinessSubProducts(sqlDB, businessIdInt)
+		res, err := GetBusinessSubProducts(sqlDB, store, businessIdInt)
 		if err != nil {
 			log.Println("Failed to get business for customer: ", err)
 			c.JSON(http.StatusBadGateway, err)
@@ -122,7 +200,7 @@ func getBusinessHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func getSubProductHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func getSubProductHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		subProductId := c.Param("id")
 		subProductIdInt, err := strconv.Atoi(subProductId)
@@ -131,7 +209,7 @@ func getSubProductHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		res, err := GetCSubProduct(sqlDB, subProductIdInt)
+		res, err := GetCSubProduct(sqlDB, store, subProductIdInt)
 		if err != nil {
 			log.Println("Failed to get sub product for customer: ", err)
 			c.JSON(http.StatusBadGateway, err)
@@ -141,4 +219,15 @@ func getSubProductHandler(sqlDB *sql.DB) gin.HandlerFunc {
 
 		c.JSON(http.StatusOK, res)
 	}
-}
\ No newline at end of file
+}
+// parseSearchSort reads the optional sort query param, relevance by default
+func parseSearchSort(c *gin.Context) (models.SearchSort, *models.RequestError) {
+	sort := models.SearchSort(c.DefaultQuery("sort", string(models.SortRelevance)))
+	if sort != models.SortRelevance && sort != models.SortRating {
+		return "", &models.RequestError{
+			Err: errors.New("sort must be relevance or rating"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return sort, nil
+}
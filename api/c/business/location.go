@@ -0,0 +1,119 @@
+package c_business
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+var (
+	defaultRadiusKm = 10.0
+	maxRadiusKm = 100.0
+	// widest box in degrees, about 200km north to south
+	maxBoxSpan = 2.0
+)
+
+func parseFloats(c *gin.Context, keys ...string) ([]float64, *models.RequestError) {
+	values := []float64{}
+	for _, key := range keys {
+		v, err := strconv.ParseFloat(c.Query(key), 64)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: fmt.Errorf("invalid %s", key),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// parseNear reads the optional lat and lng query params, nil if neither is given
+func parseNear(c *gin.Context) (*models.Location, *models.RequestError) {
+	if c.Query("lat") == "" && c.Query("lng") == "" {
+		return nil, nil
+	}
+
+	v, reqErr := parseFloats(c, "lat", "lng")
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	near := models.Location{Latitude: v[0], Longitude: v[1]}
+	if !near.Valid() {
+		return nil, &models.RequestError{
+			Err: errors.New("lat or lng out of range"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return &near, nil
+}
+
+// parseGeoQuery reads either lat, lng and radius_km, or a box from min_lat, min_lng,
+// max_lat and max_lng. A box is sorted from lat and lng if they're given, otherwise
+// from its centre
+func parseGeoQuery(c *gin.Context) (*models.GeoQuery, *models.RequestError) {
+	near, reqErr := parseNear(c)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+
+	if c.Query("min_lat") != "" {
+		v, reqErr := parseFloats(c, "min_lat", "min_lng", "max_lat", "max_lng")
+		if reqErr != nil {
+			return nil, reqErr
+		}
+
+		box := models.BoundingBox{
+			Min: models.Location{Latitude: v[0], Longitude: v[1]},
+			Max: models.Location{Latitude: v[2], Longitude: v[3]},
+		}
+		if !box.Min.Valid() || !box.Max.Valid() ||
+			box.Min.Latitude > box.Max.Latitude || box.Min.Longitude > box.Max.Longitude {
+			return nil, &models.RequestError{
+				Err: errors.New("invalid bounding box"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		if box.Max.Latitude - box.Min.Latitude > maxBoxSpan || box.Max.Longitude - box.Min.Longitude > maxBoxSpan {
+			return nil, &models.RequestError{
+				Err: fmt.Errorf("bounding box can be at most %g degrees across", maxBoxSpan),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+
+		q := models.GeoQuery{Center: box.Center(), Box: &box}
+		if near != nil {
+			q.Center = *near
+		}
+		return &q, nil
+	}
+
+	if near == nil {
+		return nil, &models.RequestError{
+			Err: errors.New("lat and lng or a bounding box are required"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	radiusKm := defaultRadiusKm
+	if c.Query("radius_km") != "" {
+		v, reqErr := parseFloats(c, "radius_km")
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		radiusKm = v[0]
+	}
+	if radiusKm <= 0 || radiusKm > maxRadiusKm {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("radius_km must be between 0 and %g", maxRadiusKm),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return &models.GeoQuery{Center: *near, RadiusM: radiusKm * 1000}, nil
+}
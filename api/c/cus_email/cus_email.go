@@ -0,0 +1,79 @@
+package cus_email
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/media"
+)
+
+// SendReceipt emails the customer for a paid subscription invoice, with the
+// card it was charged to when we know it
+func SendReceipt(sqlDB *sql.DB, invoice *models.Invoice) (error) {
+	s := db.SubscriptionDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	contact, err := s.GetSubContact(invoice.SubID)
+	if err != nil {
+		return err
+	}
+
+	var card *models.CardInfo
+	if invoice.CardID != 0 {
+		card, err = c.GetCusCard(contact.CustomerID, invoice.CardID)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	return media.SendReceiptEmail(
+		contact.CusEmail,
+		contact.CusFirstName,
+		contact.ProductName,
+		contact.BusinessName,
+		invoice.Total,
+		invoice.Created,
+		card,
+		invoice.InvoiceURL,
+	)
+}
+
+func SendPaymentFailed(sqlDB *sql.DB, subId int, amount int, updateCardLink string) (error) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	contact, err := s.GetSubContact(subId)
+	if err != nil {
+		return err
+	}
+
+	return media.SendPaymentFailedEmail(
+		contact.CusEmail,
+		contact.CusFirstName,
+		contact.ProductName,
+		contact.BusinessName,
+		amount,
+		updateCardLink,
+	)
+}
+
+// SendCancellation confirms a cancellation, expires is when access ends if
+// it doesn't end straight away
+func SendCancellation(sqlDB *sql.DB, subId int, expires *time.Time) (error) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	contact, err := s.GetSubContact(subId)
+	if err != nil {
+		return err
+	}
+
+	return media.SendSubCancelledEmail(
+		contact.CusEmail,
+		contact.CusFirstName,
+		contact.ProductName,
+		contact.BusinessName,
+		expires,
+	)
+}
@@ -5,18 +5,19 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/google/uuid"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
 	"github.com/johnyeocx/usual/server/api/auth"
 	"github.com/johnyeocx/usual/server/constants"
 	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/db"
 	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
 	"github.com/johnyeocx/usual/server/external/media"
 	"github.com/johnyeocx/usual/server/external/my_stripe"
-	"github.com/johnyeocx/usual/server/passes"
 	"github.com/johnyeocx/usual/server/utils/secure"
 )
 
@@ -85,7 +86,7 @@ func CreateCustomer(
 }
 
 func VerifyCustomerRegEmail(
-	s3sess *session.Session,
+	store cloud.ObjectStore,
 	sqlDB *sql.DB,
 	email string,
 	otp string,
@@ -135,15 +136,14 @@ func VerifyCustomerRegEmail(
 	}
 
 	// 6. Add pkpass and image to cloud
-	fullName := cus.FirstName + " " + cus.LastName
-	err = passes.GenerateCustomerPass(s3sess, fullName, cus.Uuid, cus.ID)
+	err = apple_pass.CreateCustomerPass(sqlDB, store, cus.ID)
 	if err != nil {
 		return nil, &models.RequestError{
 			Err: err,
 			StatusCode: http.StatusBadGateway,
 		}
 	}
-	err = media.GenerateCusQR(s3sess, cus.Uuid, cus.ID)
+	err = media.GenerateCusQR(store, cus.Uuid, cus.ID)
 	if err != nil {
 		return nil, &models.RequestError{
 			Err: err,
@@ -376,7 +376,7 @@ func AddCusCreditCard(
 
 
 
-func CreateCusPass(sqlDB *sql.DB, s3sess *session.Session, cusId int) (*models.RequestError) {
+func CreateCusPass(sqlDB *sql.DB, store cloud.ObjectStore, cusId int) (*models.RequestError) {
 	// 6. Add pkpass and image to cloud
 
 	c := cusdb.CustomerDB{DB: sqlDB}
@@ -388,9 +388,7 @@ func CreateCusPass(sqlDB *sql.DB, s3sess *session.Session, cusId int) (*models.R
 		}
 	}
 
-	fullName := cus.FullName()
-
-	err = passes.GenerateCustomerPass(s3sess, fullName, cus.Uuid, cus.ID)
+	err = apple_pass.CreateCustomerPass(sqlDB, store, cus.ID)
 	if err != nil {
 		return &models.RequestError{
 			Err: err,
@@ -398,7 +396,7 @@ func CreateCusPass(sqlDB *sql.DB, s3sess *session.Session, cusId int) (*models.R
 		}
 	}
 
-	err = media.GenerateCusQR(s3sess, cus.Uuid, cus.ID)
+	err = media.GenerateCusQR(store, cus.Uuid, cus.ID)
 	if err != nil {
 		return &models.RequestError{
 			Err: err,
@@ -407,4 +405,26 @@ func CreateCusPass(sqlDB *sql.DB, s3sess *session.Session, cusId int) (*models.R
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+// CreateSubPass issues a wallet pass for one of the customer's subscriptions and
+// returns a link to download it
+func CreateSubPass(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	cusId int,
+	subId int,
+) (string, *models.RequestError) {
+	key, reqErr := apple_pass.CreateSubscriptionPass(sqlDB, store, cusId, subId)
+	if reqErr != nil {
+		return "", reqErr
+	}
+
+	url, err := store.PresignGet(key, time.Minute)
+	if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return url, nil
+}
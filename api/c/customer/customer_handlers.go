@@ -7,24 +7,32 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	"github.com/johnyeocx/usual/server/api/notification"
 	"github.com/johnyeocx/usual/server/constants"
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
 	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
 )
 
-func Routes(customerRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
+func Routes(customerRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
 	customerRouter.GET("data", getCustomerDataHandler(sqlDB))
 	customerRouter.GET("subs", getCusSubsAndInvoicesHandler(sqlDB))
+	customerRouter.GET("invoices", listCusInvoicesHandler(sqlDB))
+	customerRouter.GET("usages", listCusUsagesHandler(sqlDB))
+	customerRouter.GET("google_pass", getGooglePassHandler(sqlDB, store))
 
 	customerRouter.POST("fcm_token", saveCusFCMTokenHandler(sqlDB))
 
 
 	customerRouter.POST("create", createCustomerHandler(sqlDB))
-	customerRouter.POST("create_pass", createCusPassHandler(sqlDB, s3Sess))
-	customerRouter.POST("verify_email", verifyCustomerEmailHandler(sqlDB, s3Sess))
+	customerRouter.POST("create_pass", createCusPassHandler(sqlDB, store))
+	customerRouter.POST("sub_pass/:subId", createSubPassHandler(sqlDB, store))
+	customerRouter.POST("verify_email", verifyCustomerEmailHandler(sqlDB, store))
 	customerRouter.POST("add_card", addCustomerCardHandler(sqlDB))
 	customerRouter.POST("resend_email_otp", resendEmailOTPHandler(sqlDB))
 
@@ -36,9 +44,90 @@ func Routes(customerRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Sess
 	customerRouter.PATCH("default_payment", updateCusDefaultPaymentHandler(sqlDB))
 
 	customerRouter.DELETE("card/:cardId", deleteCusCardHandler(sqlDB))
+	customerRouter.DELETE("fcm_token", deleteCusFCMTokenHandler(sqlDB))
+
+	notification.InboxRoutes(customerRouter.Group("notifications"), sqlDB, notification.CustomerRecipient)
+}
+
+func listCusInvoicesHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, errors.New("unauthenticated user"))
+			return
+		}
+
+		page, filter, reqErr := pagination.FromQuery(c, time.UTC)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, reqErr := ListCusInvoices(sqlDB, *cusId, filter, page)
+		if reqErr != nil {
+			log.Println("Failed to list cus invoices: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func listCusUsagesHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, errors.New("unauthenticated user"))
+			return
+		}
+
+		page, filter, reqErr := pagination.FromQuery(c, time.UTC)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, reqErr := ListCusUsages(sqlDB, *cusId, filter, page)
+		if reqErr != nil {
+			log.Println("Failed to list cus usages: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
 }
 
 func saveCusFCMTokenHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+
+		cusId , err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, errors.New("unauthenticated user"))
+			return
+		}
+		
+		reqBody := models.CusDevice{}
+
+		err = c.BindJSON(&reqBody)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr  := registerCusDevice(sqlDB, *cusId, reqBody)
+		if reqErr != nil {
+			log.Println("Failed to register cus device: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+		
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func deleteCusFCMTokenHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	return func (c *gin.Context) {
 
 		cusId , err := middleware.AuthenticateCId(c, sqlDB)
@@ -55,9 +144,9 @@ func saveCusFCMTokenHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		reqErr  := updateCusFCMToken(sqlDB, *cusId, reqBody.FCMToken)
+		reqErr  := removeCusDevice(sqlDB, *cusId, reqBody.FCMToken)
 		if reqErr != nil {
-			log.Println("Failed to update cus fcm token: ", reqErr.Err)
+			log.Println("Failed to remove cus device: ", reqErr.Err)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
 			return
 		}
@@ -136,7 +225,7 @@ func createCustomerHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func createCusPassHandler(sqlDB *sql.DB, s3sess *session.Session) gin.HandlerFunc {
+func createCusPassHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		cId, err := middleware.AuthenticateCId(c, sqlDB)
 		if err != nil {
@@ -144,7 +233,7 @@ func createCusPassHandler(sqlDB *sql.DB, s3sess *session.Session) gin.HandlerFun
 			return
 		}
 
-		reqErr := CreateCusPass(sqlDB, s3sess, *cId)
+		reqErr := CreateCusPass(sqlDB, store, *cId)
 		if reqErr != nil {
 			c.JSON(200, reqErr.Err)
 			return
@@ -154,7 +243,63 @@ func createCusPassHandler(sqlDB *sql.DB, s3sess *session.Session) gin.HandlerFun
 	}
 }
 
-func verifyCustomerEmailHandler(sqlDB *sql.DB, s3Sess *session.Session) gin.HandlerFunc {
+func createSubPassHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		subId, err := strconv.Atoi(c.Param("subId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, errors.New("invalid sub id"))
+			return
+		}
+
+		url, reqErr := CreateSubPass(sqlDB, store, *cId, subId)
+		if reqErr != nil {
+			log.Printf("Failed to create sub pass: %v\n", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, url)
+	}
+}
+
+// getGooglePassHandler returns a save to Google Wallet link for the membership card,
+// or for one subscription when sub_id is given
+func getGooglePassHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		var subId *int
+		if subIdStr := c.Query("sub_id"); subIdStr != "" {
+			id, err := strconv.Atoi(subIdStr)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, errors.New("invalid sub id"))
+				return
+			}
+			subId = &id
+		}
+
+		pass, reqErr := apple_pass.GetGoogleWalletPass(sqlDB, store, *cId, subId)
+		if reqErr != nil {
+			log.Printf("Failed to get google wallet pass: %v\n", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, pass)
+	}
+}
+
+func verifyCustomerEmailHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		// 1. Get user email and search if exists in db
@@ -172,7 +317,7 @@ func verifyCustomerEmailHandler(sqlDB *sql.DB, s3Sess *session.Session) gin.Hand
 		fmt.Println(reqBody)
 		
 		// 2. Verify email
-		res, reqErr := VerifyCustomerRegEmail(s3Sess, sqlDB, reqBody.Email, reqBody.OTP)
+		res, reqErr := VerifyCustomerRegEmail(store, sqlDB, reqBody.Email, reqBody.OTP)
 		if reqErr != nil {
 			log.Println(reqErr.Err)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
@@ -0,0 +1,50 @@
+package customer
+
+import (
+	"database/sql"
+	"net/http"
+
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+func ListCusInvoices(
+	sqlDB *sql.DB,
+	cusId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) (*models.Page, *models.RequestError) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	invoices, next, err := c.ListCusInvoices(cusId, filter, page)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	res := pagination.NewPage(invoices, next)
+	return &res, nil
+}
+
+func ListCusUsages(
+	sqlDB *sql.DB,
+	cusId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) (*models.Page, *models.RequestError) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	usages, next, err := c.ListCusUsages(cusId, filter, page)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	res := pagination.NewPage(usages, next)
+	return &res, nil
+}
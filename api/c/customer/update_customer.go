@@ -347,18 +347,44 @@ func updateCusPassword(
 	return nil
 }
 
-func updateCusFCMToken(
+func registerCusDevice(
+	sqlDB *sql.DB,
+	cusId int,
+	device models.CusDevice,
+) (*models.RequestError) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	if device.Token == "" {
+		return &models.RequestError{
+			Err: errors.New("fcm token required"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	err := c.UpsertCusDevice(cusId, device)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return nil
+}
+
+// removeCusDevice stops pushes to a device, eg. when the customer signs out on it
+func removeCusDevice(
 	sqlDB *sql.DB,
 	cusId int,
 	fcmToken string,
 ) (*models.RequestError) {
 	c := cusdb.CustomerDB{DB: sqlDB}
-	err := c.InsertOrUpdateCusFCMToken(cusId, fcmToken)
 
+	err := c.DeleteCusDevice(cusId, fcmToken)
 	if err != nil {
 		return &models.RequestError{
 			Err: err,
-			StatusCode: http.StatusUnauthorized,
+			StatusCode: http.StatusBadGateway,
 		}
 	}
 
@@ -0,0 +1,337 @@
+package gift
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	"github.com/johnyeocx/usual/server/api/c/subscription"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/errors/sub_errors"
+	"github.com/johnyeocx/usual/server/external/media"
+	"github.com/johnyeocx/usual/server/external/my_stripe"
+	"github.com/johnyeocx/usual/server/utils/otp"
+	"github.com/stripe/stripe-go/v74"
+)
+
+var (
+	giftClaimWindow = time.Hour * 24 * 90
+	giftReminderBefore = time.Hour * 24 * 14
+	maxGiftPeriods = 12
+	giftCodeLength = 10
+)
+
+func PurchaseGift(
+	sqlDB *sql.DB,
+	cusId int,
+	cardId int,
+	productId int,
+	periods int,
+	recipientEmail string,
+	message string,
+) (map[string]interface{}, *models.RequestError) {
+
+	if periods < 1 || periods > maxGiftPeriods {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("gift must be between 1 and %d periods", maxGiftPeriods),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	recipientEmail = strings.TrimSpace(strings.ToLower(recipientEmail))
+	if recipientEmail == "" {
+		return nil, &models.RequestError{
+			Err: errors.New("recipient email required"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	s := db.SubscriptionDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+	g := db.GiftDB{DB: sqlDB}
+
+	subProduct, stripeBusId, err := s.GetCreateSubData(productId)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadRequest,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	cusStripeId, cardStripeId, err := c.GetCustomerAndCardStripeId(cusId, cardId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	// 1. Insert gift as pending so the payment can reference it
+	now := time.Now()
+	gift := models.Gift{
+		Code: otp.GenerateCode(giftCodeLength),
+		PurchaserID: cusId,
+		RecipientEmail: recipientEmail,
+		PlanID: subProduct.SubPlan.PlanID,
+		Periods: periods,
+		Amount: subProduct.SubPlan.UnitAmount * periods,
+		Currency: subProduct.SubPlan.Currency,
+		Status: my_enums.GiftPendingPayment,
+		Created: now,
+		ClaimBy: now.Add(giftClaimWindow),
+	}
+	gift.Message.String = message
+	gift.Message.Valid = message != ""
+
+	giftId, err := g.InsertGift(gift)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	gift.ID = *giftId
+
+	// 2. Take one off payment for all periods
+	paymentIntent, err := my_stripe.CreateGiftPayment(
+		*cusStripeId, *stripeBusId, *cardStripeId, gift.Amount, gift.Currency, gift.ID,
+	)
+	if err != nil {
+		g.DeleteGift(gift.ID)
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	err = g.SetGiftPaymentIntent(gift.ID, paymentIntent.ID)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 3. If no further action needed send gift straight away,
+	// otherwise the payment_intent.succeeded webhook completes it
+	if paymentIntent.Status == stripe.PaymentIntentStatusSucceeded {
+		err = CompleteGiftPurchase(sqlDB, gift.ID)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadGateway,
+			}
+		}
+		gift.Status = my_enums.GiftPurchased
+	}
+
+	return map[string]interface{}{
+		"gift": gift,
+		"status": paymentIntent.Status,
+		"payment_intent": paymentIntent,
+	}, nil
+}
+
+func CompleteGiftPurchase(sqlDB *sql.DB, giftId int) (error) {
+	g := db.GiftDB{DB: sqlDB}
+
+	updated, err := g.SetGiftPurchased(giftId)
+	if err != nil {
+		return err
+	}
+
+	// already completed
+	if !updated {
+		return nil
+	}
+
+	gift, err := g.GetGiftByID(giftId)
+	if err != nil {
+		return err
+	}
+
+	return sendGiftEmail(gift, false)
+}
+
+func ClaimGift(
+	sqlDB *sql.DB,
+	cusId int,
+	code string,
+) (*models.Subscription, *models.RequestError) {
+	g := db.GiftDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	gift, err := g.GetGiftByCode(strings.ToUpper(strings.TrimSpace(code)))
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: errors.New("invalid gift code"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if gift.Status != my_enums.GiftPurchased || gift.ClaimBy.Before(time.Now()) {
+		return nil, &models.RequestError{
+			Err: errors.New("gift is no longer claimable"),
+			StatusCode: http.StatusGone,
+		}
+	}
+
+	err = c.CheckCusSubscribed(cusId, []int{gift.SubProduct.Product.ProductID})
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	// entitlements run until the end of the last gifted period
+	now := time.Now()
+	expires := now
+	for i := 0; i < gift.Periods; i++ {
+		expires = subscription.GetNextBillingDate(gift.SubProduct.SubPlan.RecurringDuration, expires)
+	}
+
+	sub := models.Subscription{
+		CustomerID: cusId,
+		PlanID: gift.PlanID,
+		StartDate: now,
+		Cancelled: true,
+		BusinessName: gift.BusinessName,
+		SubProduct: gift.SubProduct,
+	}
+	sub.Expires.Time = expires
+	sub.Expires.Valid = true
+
+	claimed, err := g.ClaimGift(gift.ID, &sub)
+	if err == db.ErrPlanFull {
+		return nil, sub_errors.PlanFullReqErr()
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	} else if !claimed {
+		return nil, &models.RequestError{
+			Err: errors.New("gift has already been claimed"),
+			StatusCode: http.StatusGone,
+		}
+	}
+
+	go subscription.NotifyNewSubscriber(sqlDB, sub.ID)
+	go apple_pass.PassesChanged(sqlDB, sub.ID)
+
+	return &sub, nil
+}
+
+func GetCusGifts(
+	sqlDB *sql.DB,
+	cusId int,
+) (map[string]interface{}, *models.RequestError) {
+	g := db.GiftDB{DB: sqlDB}
+
+	purchased, err := g.GetCusPurchasedGifts(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	received, err := g.GetCusReceivedGifts(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// recipients only see codes through email
+	for i := range received {
+		received[i].Code = ""
+	}
+
+	return map[string]interface{}{
+		"purchased": purchased,
+		"received": received,
+	}, nil
+}
+
+// HandleUnclaimedGifts refunds gifts past their claim window and reminds
+// recipients of gifts that are close to it
+func HandleUnclaimedGifts(sqlDB *sql.DB) {
+	g := db.GiftDB{DB: sqlDB}
+	now := time.Now()
+
+	expired, err := g.GetUnclaimedGiftsBefore(now)
+	if err != nil {
+		log.Println("Failed to get expired gifts:", err)
+		return
+	}
+
+	for _, gift := range expired {
+		if gift.StripePMIID.Valid {
+			err = my_stripe.RefundPayment(gift.StripePMIID.String)
+			if err != nil {
+				log.Printf("Failed to refund gift %d: %v\n", gift.ID, err)
+				continue
+			}
+		}
+
+		err = g.SetGiftRefunded(gift.ID)
+		if err != nil {
+			log.Printf("Failed to set gift %d refunded: %v\n", gift.ID, err)
+		}
+	}
+
+	expiring, err := g.GetGiftsNeedingReminder(now.Add(giftReminderBefore))
+	if err != nil {
+		log.Println("Failed to get expiring gifts:", err)
+		return
+	}
+
+	for _, gift := range expiring {
+		gift := gift
+		err = sendGiftEmail(&gift, true)
+		if err != nil {
+			log.Printf("Failed to send gift %d reminder: %v\n", gift.ID, err)
+			continue
+		}
+
+		err = g.SetGiftReminderSent(gift.ID)
+		if err != nil {
+			log.Printf("Failed to set gift %d reminder sent: %v\n", gift.ID, err)
+		}
+	}
+}
+
+func sendGiftEmail(gift *models.Gift, reminder bool) (error) {
+	return media.SendGiftEmail(
+		gift.RecipientEmail,
+		*gift.PurchaserName,
+		gift.SubProduct.Product.Name,
+		*gift.BusinessName,
+		gift.Periods,
+		gift.Message.String,
+		gift.Code,
+		gift.ClaimBy,
+		reminder,
+	)
+}
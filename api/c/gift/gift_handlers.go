@@ -0,0 +1,116 @@
+package gift
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/stripe/stripe-go/v74"
+)
+
+func Routes(giftRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	giftRouter.GET("", getCusGiftsHandler(sqlDB))
+
+	giftRouter.POST("purchase", purchaseGiftHandler(sqlDB))
+	giftRouter.POST("claim", claimGiftHandler(sqlDB))
+}
+
+func getCusGiftsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetCusGifts(sqlDB, *cusId)
+		if reqErr != nil {
+			log.Println("Failed to get customer gifts: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func purchaseGiftHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			ProductID		int		`json:"product_id"`
+			CardID			int		`json:"card_id"`
+			Periods			int 	`json:"periods"`
+			RecipientEmail	string 	`json:"recipient_email"`
+			Message			string 	`json:"message"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		res, reqErr := PurchaseGift(
+			sqlDB, 
+			*cusId, 
+			reqBody.CardID, 
+			reqBody.ProductID, 
+			reqBody.Periods, 
+			reqBody.RecipientEmail, 
+			reqBody.Message,
+		)
+		if reqErr != nil {
+			stripeErr := stripe.Error{}
+			err := json.Unmarshal([]byte(reqErr.Err.Error()), &stripeErr)
+			if err == nil {
+				log.Println("Stripe error: ", stripeErr.Code)
+				c.JSON(reqErr.StatusCode, stripeErr.Code)
+				return
+			}
+
+			log.Println("Failed to purchase gift: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func claimGiftHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			Code	string	`json:"code"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		sub, reqErr := ClaimGift(sqlDB, *cusId, reqBody.Code)
+		if reqErr != nil {
+			log.Println("Failed to claim gift: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, sub)
+	}
+}
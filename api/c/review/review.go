@@ -0,0 +1,200 @@
+package review
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/johnyeocx/usual/server/constants"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/errors/review_errors"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+var (
+	minRating = 1
+	maxRating = 5
+	maxReviewLength = 2000
+	// a review goes to moderation once this many of the product's subscribers flag it
+	moderateAtFlags = 3
+)
+
+// ReviewProduct creates or updates the customer's review of a product they have,
+// or have had, a subscription to
+func ReviewProduct(
+	sqlDB *sql.DB,
+	cusId int,
+	productId int,
+	rating int,
+	body string,
+) (*models.Review, *models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	body = strings.TrimSpace(body)
+	if rating < minRating || rating > maxRating {
+		return nil, review_errors.InvalidRatingReqErr(minRating, maxRating)
+	}
+	if utf8.RuneCountInString(body) > maxReviewLength {
+		return nil, review_errors.ReviewTooLongReqErr(maxReviewLength)
+	}
+
+	businessId, subscribed, err := r.GetReviewEligibility(cusId, productId)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: errors.New("product not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if !subscribed {
+		return nil, review_errors.NotSubscribedReqErr()
+	}
+
+	review, err := r.UpsertReview(cusId, productId, businessId, rating, body)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return review, nil
+}
+
+func DeleteReview(sqlDB *sql.DB, cusId int, reviewId int) (*models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	err := r.DeleteReview(reviewId, cusId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("review not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+func GetCusReviews(sqlDB *sql.DB, cusId int) ([]models.Review, *models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	reviews, err := r.GetCustomerReviews(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return reviews, nil
+}
+
+// GetReviews pages through the public reviews of a business, or one of its products,
+// along with their rating
+func GetReviews(
+	sqlDB *sql.DB,
+	businessId *int,
+	productId *int,
+	page *models.PageParams,
+) (map[string]interface{}, *models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	summary, err := r.GetRatingSummary(businessId, productId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	reviews, next, err := r.ListReviews(businessId, productId, true, page)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	res := pagination.NewPage(reviews, next)
+	return map[string]interface{}{
+		"rating": summary,
+		"reviews": res.Items,
+		"next_cursor": res.NextCursor,
+	}, nil
+}
+
+// FlagReview reports a review for moderation. Customers can flag any review but
+// their own, businesses only reviews of their products. Only flags from customers who
+// could review the product themselves count towards moderation, so neither the business
+// nor people who have never subscribed can get a review taken down
+func FlagReview(
+	sqlDB *sql.DB,
+	flaggerType string,
+	flaggerId int,
+	reviewId int,
+	reason my_enums.ReviewFlagReason,
+) (*models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	if !my_enums.ValidReviewFlagReason(reason) {
+		return &models.RequestError{
+			Err: errors.New("invalid flag reason"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	authorId, productId, businessId, err := r.GetReviewAuthor(reviewId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("review not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if flaggerType == constants.UserTypes.Customer && authorId == flaggerId {
+		return review_errors.OwnReviewReqErr()
+	}
+	if flaggerType == constants.UserTypes.Business && businessId != flaggerId {
+		return &models.RequestError{
+			Err: errors.New("review not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+
+	counts := false
+	if flaggerType == constants.UserTypes.Customer {
+		_, counts, err = r.GetReviewEligibility(flaggerId, productId)
+		if err != nil {
+			return &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadGateway,
+			}
+		}
+	}
+
+	// flagging twice is a no op
+	err = r.FlagReview(reviewId, flaggerType, flaggerId, reason, counts, moderateAtFlags)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
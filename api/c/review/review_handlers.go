@@ -0,0 +1,177 @@
+package review
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/constants"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+func Routes(reviewRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	reviewRouter.GET("", getCusReviewsHandler(sqlDB))
+	reviewRouter.GET("/product/:productId", getReviewsHandler(sqlDB, "productId"))
+	reviewRouter.GET("/business/:businessId", getReviewsHandler(sqlDB, "businessId"))
+
+	reviewRouter.POST("", reviewProductHandler(sqlDB))
+	reviewRouter.POST("/:reviewId/flag", flagReviewHandler(sqlDB))
+
+	reviewRouter.DELETE("/:reviewId", deleteReviewHandler(sqlDB))
+}
+
+func getCusReviewsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reviews, reqErr := GetCusReviews(sqlDB, *cusId)
+		if reqErr != nil {
+			log.Println("Failed to get customer reviews: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, reviews)
+	}
+}
+
+// getReviewsHandler is public, param says whether the id is a product or business
+func getReviewsHandler(sqlDB *sql.DB, param string) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		id, err := strconv.Atoi(c.Param(param))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		var businessId, productId *int
+		if param == "productId" {
+			productId = &id
+		} else {
+			businessId = &id
+		}
+
+		res, reqErr := GetReviews(sqlDB, businessId, productId, page)
+		if reqErr != nil {
+			log.Println("Failed to get reviews: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func reviewProductHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			ProductID	int		`json:"product_id"`
+			Rating		int		`json:"rating"`
+			Body		string	`json:"body"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		review, reqErr := ReviewProduct(sqlDB, *cusId, reqBody.ProductID, reqBody.Rating, reqBody.Body)
+		if reqErr != nil {
+			log.Println("Failed to review product: ", reqErr.Err)
+			if reqErr.Code != "" {
+				c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+				return
+			}
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, review)
+	}
+}
+
+func flagReviewHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqBody := struct {
+			Reason	my_enums.ReviewFlagReason	`json:"reason"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := FlagReview(sqlDB, constants.UserTypes.Customer, *cusId, reviewId, reqBody.Reason)
+		if reqErr != nil {
+			log.Println("Failed to flag review: ", reqErr.Err)
+			if reqErr.Code != "" {
+				c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+				return
+			}
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func deleteReviewHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := DeleteReview(sqlDB, *cusId, reviewId)
+		if reqErr != nil {
+			log.Println("Failed to delete review: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
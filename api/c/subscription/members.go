@@ -0,0 +1,281 @@
+package subscription
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/media"
+)
+
+func InviteSubMember(
+	sqlDB *sql.DB,
+	cusId int,
+	subId int,
+	email string,
+) (*models.SubMember, *models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	email = strings.TrimSpace(strings.ToLower(email))
+
+	// 1. only the owner can invite, and only while the subscription lasts
+	sub, _, _, err := s.CusOwnsSub(cusId, subId)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusForbidden,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if sub.Cancelled && (!sub.Expires.Valid || !sub.Expires.Time.After(time.Now())) {
+		return nil, &models.RequestError{
+			Err: errors.New("subscription has expired"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	owner, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if owner.Email == email {
+		return nil, &models.RequestError{
+			Err: errors.New("cannot invite yourself"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 2. link straight to existing customer if there is one
+	var memberCusId models.JsonNullInt64
+	invitee, err := c.GetCustomerByEmail(email)
+	if err == nil {
+		memberCusId.Int64 = int64(invitee.ID)
+		memberCusId.Valid = true
+	} else if err != sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	memberId, err := s.InsertSubMember(subId, email, memberCusId)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: errors.New("no seats left on this subscription"),
+			StatusCode: http.StatusConflict,
+		}
+	} else if err == db.ErrAlreadyMember {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusConflict,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	member, err := s.GetSubMember(*memberId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	subs, err := c.GetCustomerSubscriptions(cusId)
+	if err == nil {
+		for _, sub := range subs {
+			if sub.ID == subId {
+				media.SendSeatInviteEmail(email, owner.FullName(), sub.SubProduct.Product.Name, *sub.BusinessName)
+			}
+		}
+	}
+
+	return member, nil
+}
+
+func GetSubMembers(
+	sqlDB *sql.DB,
+	cusId int,
+	subId int,
+) (map[string]interface{}, *models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	_, _, _, err := s.CusOwnsSub(cusId, subId)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusForbidden,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	members, err := s.GetSubMembers(subId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	usages, err := s.GetSubMemberUsages(subId, 50)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return map[string]interface{}{
+		"members": members,
+		"usages": usages,
+	}, nil
+}
+
+func GetCusSubInvitations(
+	sqlDB *sql.DB,
+	cusId int,
+) ([]models.SubMember, *models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	cus, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	invitations, err := s.GetCusSubInvitations(strings.ToLower(cus.Email))
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return invitations, nil
+}
+
+func AcceptSubInvitation(
+	sqlDB *sql.DB,
+	cusId int,
+	memberId int,
+) (*models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	member, err := s.GetSubMember(memberId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	cus, err := c.GetCustomerByID(cusId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if strings.ToLower(cus.Email) != member.Email {
+		return &models.RequestError{
+			Err: errors.New("invitation was sent to a different email"),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	if member.Status != my_enums.SubMemberInvited {
+		return &models.RequestError{
+			Err: errors.New("invitation is no longer valid"),
+			StatusCode: http.StatusGone,
+		}
+	}
+
+	err = s.AcceptSubMember(memberId, cusId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	go apple_pass.PassesChanged(sqlDB, member.SubID)
+
+	return nil
+}
+
+// RemoveSubMember can be called by the owner to free up a seat or by the member to leave
+func RemoveSubMember(
+	sqlDB *sql.DB,
+	cusId int,
+	memberId int,
+) (*models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	member, err := s.GetSubMember(memberId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	isOwner := *member.OwnerID == cusId
+	isMember := member.CustomerID.Valid && int(member.CustomerID.Int64) == cusId
+	if !isOwner && !isMember {
+		return &models.RequestError{
+			Err: errors.New("not allowed to remove this member"),
+			StatusCode: http.StatusForbidden,
+		}
+	}
+
+	err = s.RemoveSubMember(memberId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	go apple_pass.PassesChanged(sqlDB, member.SubID)
+
+	return nil
+}
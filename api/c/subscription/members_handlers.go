@@ -0,0 +1,135 @@
+package subscription
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func GetSubMembersHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		customerId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		subIdInt, err := strconv.Atoi(c.Param("subId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		res, reqErr := GetSubMembers(sqlDB, *customerId, subIdInt)
+		if reqErr != nil {
+			log.Println("Failed to get subscription members: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func GetSubInvitationsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		customerId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetCusSubInvitations(sqlDB, *customerId)
+		if reqErr != nil {
+			log.Println("Failed to get subscription invitations: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func InviteSubMemberHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		customerId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			SubID	int 	`json:"sub_id"`
+			Email	string 	`json:"email"`
+		}{}
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		member, reqErr := InviteSubMember(sqlDB, *customerId, reqBody.SubID, reqBody.Email)
+		if reqErr != nil {
+			log.Println("Failed to invite subscription member: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, member)
+	}
+}
+
+func AcceptSubInvitationHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		customerId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			MemberID	int `json:"member_id"`
+		}{}
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := AcceptSubInvitation(sqlDB, *customerId, reqBody.MemberID)
+		if reqErr != nil {
+			log.Println("Failed to accept subscription invitation: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func RemoveSubMemberHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		customerId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		memberIdInt, err := strconv.Atoi(c.Param("memberId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := RemoveSubMember(sqlDB, *customerId, memberIdInt)
+		if reqErr != nil {
+			log.Println("Failed to remove subscription member: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
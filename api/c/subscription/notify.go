@@ -0,0 +1,43 @@
+package subscription
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/johnyeocx/usual/server/api/notification"
+	"github.com/johnyeocx/usual/server/constants"
+	"github.com/johnyeocx/usual/server/db"
+)
+
+// NotifyNewSubscriber lets the business know someone subscribed, errors are only logged
+func NotifyNewSubscriber(sqlDB *sql.DB, subId int) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	contact, err := s.GetSubContact(subId)
+	if err != nil {
+		log.Printf("Failed to get contact for sub %d: %v\n", subId, err)
+		return
+	}
+
+	n := notification.NewSubscriber(
+		contact.BusinessID, subId, constants.FullName(contact.CusFirstName, contact.CusLastName), contact.ProductName)
+	if err := notification.NotifyBusiness(sqlDB, n); err != nil {
+		log.Printf("Failed to notify business %d of new sub: %v\n", contact.BusinessID, err)
+	}
+}
+
+func notifySubscriberCancelled(sqlDB *sql.DB, subId int, unpaid bool) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	contact, err := s.GetSubContact(subId)
+	if err != nil {
+		log.Printf("Failed to get contact for sub %d: %v\n", subId, err)
+		return
+	}
+
+	n := notification.SubscriberCancelled(
+		contact.BusinessID, subId, constants.FullName(contact.CusFirstName, contact.CusLastName), contact.ProductName, unpaid)
+	if err := notification.NotifyBusiness(sqlDB, n); err != nil {
+		log.Printf("Failed to notify business %d of cancelled sub: %v\n", contact.BusinessID, err)
+	}
+}
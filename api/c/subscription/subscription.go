@@ -3,23 +3,36 @@ package subscription
 import (
 	"database/sql"
 	"errors"
+	"log"
 	"net/http"
 	"time"
 
+	firebase "firebase.google.com/go"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	"github.com/johnyeocx/usual/server/api/c/cus_email"
+	"github.com/johnyeocx/usual/server/api/notification"
 	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/db"
 	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/errors/sub_errors"
 	"github.com/johnyeocx/usual/server/external/my_stripe"
+	"github.com/johnyeocx/usual/server/utils/pagination"
 	"github.com/stripe/stripe-go/v74"
 )
 
+// gift subscriptions are paid for up front and have no stripe subscription behind them
+var errNotBilled = errors.New("subscription is a gift and isn't billed")
 
 
+
+// GetSubscriptionData returns the customer's spend on a product with a page of its invoices,
+// invoices_next_cursor pages through the rest the same way /c/customer/invoices does
 func GetSubscriptionData(
 	sqlDB *sql.DB,
 	cusId int,
 	productId int,
+	page *models.PageParams,
 ) (map[string]interface{}, *models.RequestError) {
 
 	c := cusdb.CustomerDB{DB: sqlDB}
@@ -32,13 +45,14 @@ func GetSubscriptionData(
 	}
 
 	// 1. Get Invoices
-	invoices, err := c.GetSubInvoices(cusId, productId, 20)
+	invoices, next, err := c.ListCusInvoices(cusId, &models.ListFilter{ProductID: &productId}, page)
 	if err != nil {
 		return nil, &models.RequestError{
 			Err: err,
 			StatusCode: http.StatusBadGateway,
 		}
 	}
+	invoicePage := pagination.NewPage(invoices, next)
 
 	// 2. Get Usages
 	usages, err := c.GetSubscriptionUsages(cusId, productId, 20)
@@ -51,7 +65,8 @@ func GetSubscriptionData(
 
 	return map[string]interface{}{
 		"total": total,
-		"invoices": invoices,
+		"invoices": invoicePage.Items,
+		"invoices_next_cursor": invoicePage.NextCursor,
 		"usages": usages,
 	}, nil
 }
@@ -94,11 +109,31 @@ func CreateSubscription(
 		}
 	}
 
-	
-	stripeSub, err := my_stripe.CreateSubscription(
-		*cusStripeId, *stripeBusId, *cardStripeId, *subProduct,
-	)
+	sub := models.Subscription{
+		CustomerID: customerId,
+		PlanID: subProduct.SubPlan.PlanID,
+		CardID: cardId,
+	}
+
+	// 1. create on stripe while holding the plan's capacity
+	var stripeSub *stripe.Subscription
+	err = s.InsertSubscriptionWithinCapacity(&sub, func() error {
+		stripeSub, err = my_stripe.CreateSubscription(
+			*cusStripeId, *stripeBusId, *cardStripeId, *subProduct,
+		)
+		if err != nil {
+			return err
+		}
+
+		sub.StripeSubID = stripeSub.ID
+		sub.StartDate = time.Now()
+		return nil
+	})
 	if err != nil {
+		cancelOrphanedStripeSub(sub.StripeSubID)
+		if err == db.ErrPlanFull {
+			return nil, sub_errors.PlanFullReqErr()
+		}
 		return nil, &models.RequestError{
 			Err: err,
 			StatusCode: http.StatusBadGateway,
@@ -107,9 +142,7 @@ func CreateSubscription(
 
 	stripeIn := stripeSub.LatestInvoice
 	lastIn := models.Invoice{}
-	now := time.Now()
 
-	
 	if stripeIn != nil {
 		lastIn.CardID = cardId
 		lastIn.Total = int(stripeIn.Total)
@@ -118,24 +151,15 @@ func CreateSubscription(
 		lastIn.Status = string(stripeIn.Status)
 		lastIn.PaymentIntentStatus = my_enums.StripePMStatusToMYPMStatus(stripeSub.LatestInvoice.PaymentIntent.Status)
 	}
-	sub := models.Subscription{
-		StripeSubID: stripeSub.ID,
-		CustomerID: customerId,
-		PlanID: subProduct.SubPlan.PlanID,
-		StartDate: now,
-		CardID: cardId,
-		LastInvoice: &lastIn,
-	}
-	
-	// // 4. INSERT INTO DB
-	returnedSubs, err := s.InsertSubscriptions(&[]models.Subscription{sub})
-	if err != nil {
-		return nil, &models.RequestError{
-			Err: err,
-			StatusCode: http.StatusBadGateway,
-		}
-	}
-	sub.ID = returnedSubs[0].ID
+	sub.LastInvoice = &lastIn
+
+	// 2. customer took their spot so they're off the waitlist
+	w := db.WaitlistDB{DB: sqlDB}
+	w.SetWaitlistClaimed(sub.PlanID, customerId)
+
+	go NotifyNewSubscriber(sqlDB, sub.ID)
+	go apple_pass.PassesChanged(sqlDB, sub.ID)
+
 	return &models.CreateSubReturn{
 		Sub: sub,
 		Status: stripeSub.LatestInvoice.PaymentIntent.Status,
@@ -171,6 +195,13 @@ func ResolvePaymentIntent(
 		}
 	}
 
+	if sub.StripeSubID == "" {
+		return nil, &models.RequestError{
+			Err: errNotBilled,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
 	stripeSub, paymentIntent, err := my_stripe.UpdateSubDefaultCardAndConfirm(sub.StripeSubID, card.StripeID)
 	if err != nil {
 		return nil, &models.RequestError{
@@ -196,6 +227,12 @@ func ResolvePaymentIntent(
 		
 	}
 
+	// lift any suspension straight away rather than waiting on the webhook
+	if paymentIntent.Status == stripe.PaymentIntentStatusSucceeded {
+		d := db.DunningDB{DB: sqlDB}
+		d.ResolveDunningCase(stripeSub.LatestInvoice.ID, my_enums.DunningRecovered)
+	}
+
 	return map[string]interface{}{
 		"payment_method_id": card.StripeID,
 		"payment_intent": paymentIntent,
@@ -262,6 +299,20 @@ func ResumeSubscription(
 		}
 	}
 
+	// still within paid period, stripe sub is alive so just undo the cancellation,
+	// switching it to the card the customer picked
+	if sub.Expires.Valid && sub.Expires.Time.After(time.Now()) {
+		if sub.CardID != cardId {
+			if reqErr := ChangeSubDefaultCard(sqlDB, customerId, subId, cardId); reqErr != nil {
+				return nil, reqErr
+			}
+		}
+		if reqErr := UndoCancelSubscription(sqlDB, customerId, subId); reqErr != nil {
+			return nil, reqErr
+		}
+		return &models.ResumeSubReturn{}, nil
+	}
+
 
 	var cardStripeId string
 
@@ -284,24 +335,27 @@ func ResumeSubscription(
 	busStripeId := data["stripe_bus_id"].(string)
 
 	
-	stripeSub, err := my_stripe.ResumeSubscription(
-		cusStripeId,
-		busStripeId,
-		*plan.StripePriceID,
-		cardStripeId,
-		sub.Expires.Time,
-	)
-
-
-	if err != nil {
-		return nil, &models.RequestError{
-			Err: err,
-			StatusCode: http.StatusBadGateway,
+	// new stripe sub is created while the plan's spot is held, the old one has ended
+	var stripeSub *stripe.Subscription
+	sub.StripeSubID = ""
+	err = s.ResumeSubscription(&sub, cardId, func() (string, error) {
+		stripeSub, err = my_stripe.ResumeSubscription(
+			cusStripeId,
+			busStripeId,
+			*plan.StripePriceID,
+			cardStripeId,
+			sub.Expires.Time,
+		)
+		if err != nil {
+			return "", err
 		}
-	}
-
-	err = s.ResumeSubscription(subId, cardId, stripeSub.ID)
+		return stripeSub.ID, nil
+	})
 	if err != nil {
+		cancelOrphanedStripeSub(sub.StripeSubID)
+		if err == db.ErrPlanFull {
+			return nil, sub_errors.PlanFullReqErr()
+		}
 		return nil, &models.RequestError{
 			Err: err,
 			StatusCode: http.StatusBadGateway,
@@ -332,6 +386,183 @@ func ResumeSubscription(
 
 
 func CancelSubscription(
+	sqlDB *sql.DB,
+	fbApp *firebase.App,
+	cusId int,
+	subId int,
+	survey *models.CancellationSurvey,
+) (map[string]interface{}, *models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	// 1. check if cus owns sub
+	sub, _, _, err := s.CusOwnsSub(cusId, subId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if sub.Cancelled {
+		return nil, &models.RequestError{
+			Err: errors.New("subscription is already cancelled"),
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	if survey != nil && !my_enums.ValidCancellationReason(survey.Reason) {
+		return nil, &models.RequestError{
+			Err: errors.New("invalid cancellation reason"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 2. Delete if only one payment
+	deleted, err := DeleteSubIfNoPayments(sqlDB, subId, sub.StripeSubID)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	
+	if deleted {
+		return map[string]interface{}{
+			"deleted": true,
+		}, nil
+	}
+
+	// 3. cancel at period end on stripe, customer keeps access until then
+	stripeSub, err := my_stripe.CancelSubscriptionAtPeriodEnd(sub.StripeSubID)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	expires := time.Unix(stripeSub.CurrentPeriodEnd, 0)
+
+	// 4. update sql
+	err = s.CancelSubscription(subId, expires)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if survey != nil {
+		survey.SubID = subId
+		survey.Created = time.Now()
+		err = s.InsertCancellationSurvey(*survey)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadGateway,
+			}
+		}
+	}
+
+	go func() {
+		contact, err := s.GetSubContact(subId)
+		if err != nil {
+			log.Printf("Failed to get contact for sub %d: %v\n", subId, err)
+			return
+		}
+
+		n := notification.SubCancelled(contact.CustomerID, subId, contact.ProductName, contact.BusinessName, false)
+		n.Email = func() error { return cus_email.SendCancellation(sqlDB, subId, &expires) }
+		if err := notification.Notify(sqlDB, fbApp, n); err != nil {
+			log.Printf("Failed to notify cancellation for sub %d: %v\n", subId, err)
+		}
+
+		notifySubscriberCancelled(sqlDB, subId, false)
+		apple_pass.PassesChanged(sqlDB, subId)
+	}()
+
+	return map[string]interface{}{
+		"expires": expires,
+	}, nil
+}
+
+func UndoCancelSubscription(
+	sqlDB *sql.DB,
+	cusId int,
+	subId int,
+) (*models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+
+	sub, _, _, err := s.CusOwnsSub(cusId, subId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadRequest,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if !sub.Cancelled {
+		return &models.RequestError{
+			Err: errors.New("subscription is not cancelled"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if !sub.Expires.Valid || !sub.Expires.Time.After(time.Now()) {
+		return &models.RequestError{
+			Err: errors.New("subscription has already expired, resume instead"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if sub.StripeSubID == "" {
+		return &models.RequestError{
+			Err: errNotBilled,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	_, err = my_stripe.UndoCancelSubscription(sub.StripeSubID)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	err = s.UndoCancelSubscription(subId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	err = s.DeleteCancellationSurvey(subId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return nil
+}
+
+// EndSubscription cancels the stripe subscription immediately, used when
+// payment for the subscription could not be recovered
+func EndSubscription(
 	sqlDB *sql.DB,
 	cusId int,
 	subId int,
@@ -354,6 +585,13 @@ func CancelSubscription(
 		}
 	}
 
+	if sub.StripeSubID == "" {
+		return nil, &models.RequestError{
+			Err: errNotBilled,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
 	// 2. Delete if only one payment
 	deleted, err := DeleteSubIfNoPayments(sqlDB, subId, sub.StripeSubID)
 	if err != nil {
@@ -396,12 +634,27 @@ func CancelSubscription(
 		}
 	}
 
+	go notifySubscriberCancelled(sqlDB, subId, true)
+	go apple_pass.PassesChanged(sqlDB, subId)
+
 	return map[string]interface{}{
 		"expires": expires,
 	}, nil
 }
 
 
+// cancelOrphanedStripeSub cancels a stripe sub that was created but never saved,
+// so the customer isn't billed for a subscription we don't know about
+func cancelOrphanedStripeSub(stripeSubId string) {
+	if stripeSubId == "" {
+		return
+	}
+
+	if err := my_stripe.CancelSubscription(stripeSubId); err != nil {
+		log.Printf("Failed to cancel orphaned stripe sub %s: %v\n", stripeSubId, err)
+	}
+}
+
 func DeleteSubIfNoPayments(sqlDB *sql.DB, subId int, stripeSubId string) (bool, error) {
 	s := db.SubscriptionDB{DB: sqlDB}
 
@@ -450,6 +703,13 @@ func ChangeSubDefaultCard(
 		}
 	}
 
+	if sub.StripeSubID == "" {
+		return &models.RequestError{
+			Err: errNotBilled,
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
 	// 2. get card stripe id
 	_, cardStripeId, err :=c.GetCustomerAndCardStripeId(cusId, cardId)
 	if err != nil {
@@ -4,29 +4,39 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	firebase "firebase.google.com/go"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
 	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
 	"github.com/stripe/stripe-go/v74"
 )
 
 
-func Routes(subRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
+func Routes(subRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore, fbApp *firebase.App) {
 	subRouter.GET("/:id", getSubscriptionDataHandler(sqlDB))
 	subRouter.GET("/payment_intent/:subId", GetPaymentIntentHandler(sqlDB))
+	subRouter.GET("/members/:subId", GetSubMembersHandler(sqlDB))
+	subRouter.GET("/invitations", GetSubInvitationsHandler(sqlDB))
 
 	subRouter.POST("create", CreateSubscriptionHandler(sqlDB))
 	subRouter.POST("resolve_payment_intent", ResolvePaymentIntentHandler(sqlDB))
+	subRouter.POST("members/invite", InviteSubMemberHandler(sqlDB))
+	subRouter.POST("members/accept", AcceptSubInvitationHandler(sqlDB))
 
 	subRouter.PATCH("resume", ResumeSubscriptionHandler(sqlDB))
+	subRouter.PATCH("undo_cancel", UndoCancelSubscriptionHandler(sqlDB))
 	
 	subRouter.PATCH("default_card", ChangeSubDefaultCardHandler(sqlDB))
 	
-	subRouter.DELETE("cancel/:subId", CancelSubscriptionHandler(sqlDB))
+	subRouter.DELETE("cancel/:subId", CancelSubscriptionHandler(sqlDB, fbApp))
+	subRouter.DELETE("members/:memberId", RemoveSubMemberHandler(sqlDB))
 }
 
 
@@ -46,7 +56,13 @@ func getSubscriptionDataHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		res, reqErr := GetSubscriptionData(sqlDB, *cusId, productIdInt)
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, reqErr := GetSubscriptionData(sqlDB, *cusId, productIdInt, page)
 		if reqErr != nil {
 			log.Println("Failed to get customer: ", reqErr.Err)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
@@ -83,6 +99,10 @@ func CreateSubscriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
 		res, reqErr := CreateSubscription(sqlDB, *customerId, reqBody.CardID, reqBody.ProductID)
 		if reqErr != nil {
 			log.Println("Failed to create subscription:", reqErr.Err)
+			if reqErr.Code != "" {
+				c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+				return
+			}
 			c.JSON(reqErr.StatusCode, reqErr.Err)
 			return
 		}
@@ -124,7 +144,7 @@ func ResumeSubscriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func CancelSubscriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func CancelSubscriptionHandler(sqlDB *sql.DB, fbApp *firebase.App) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		
 		// GET CUSTOMER ID
@@ -141,7 +161,17 @@ func CancelSubscriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		res, reqErr := CancelSubscription(sqlDB, *customerId, productIdInt)
+		// cancellation survey is optional
+		var survey *models.CancellationSurvey
+		reqBody := models.CancellationSurvey{}
+		if err := c.ShouldBindJSON(&reqBody); err == nil {
+			survey = &reqBody
+		} else if err != io.EOF {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		res, reqErr := CancelSubscription(sqlDB, fbApp, *customerId, productIdInt, survey)
 		if reqErr != nil {
 			log.Println("Failed to cancel subscription: ", reqErr.Err)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
@@ -152,6 +182,35 @@ func CancelSubscriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
+func UndoCancelSubscriptionHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		
+		// GET CUSTOMER ID
+		customerId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			SubID	int `json:"sub_id"`
+		}{}
+		if err := c.BindJSON(&reqBody); err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := UndoCancelSubscription(sqlDB, *customerId, reqBody.SubID)
+		if reqErr != nil {
+			log.Println("Failed to undo subscription cancellation: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(200, nil)
+	}
+}
+
 func ChangeSubDefaultCardHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		
@@ -0,0 +1,188 @@
+package waitlist
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/errors/sub_errors"
+	"github.com/johnyeocx/usual/server/external/media"
+)
+
+var (
+	waitlistClaimWindow = time.Hour * 48
+)
+
+func JoinWaitlist(
+	sqlDB *sql.DB,
+	cusId int,
+	productId int,
+) (*models.WaitlistEntry, *models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+	c := cusdb.CustomerDB{DB: sqlDB}
+	w := db.WaitlistDB{DB: sqlDB}
+
+	err := c.CheckCusSubscribed(cusId, []int{productId})
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusConflict,
+		}
+	}
+
+	subProduct, _, err := s.GetCreateSubData(productId)
+	if err == sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 1. only capped plans that are full have a waitlist
+	capacity, err := w.GetPlanCapacity(subProduct.SubPlan.PlanID)
+	if err == sql.ErrNoRows {
+		return nil, sub_errors.PlanNotFullReqErr()
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if capacity.Subscribers + capacity.HeldSpots < capacity.MaxSubscribers && capacity.Waiting == 0 {
+		return nil, sub_errors.PlanNotFullReqErr()
+	}
+
+	// 2. join
+	waitlistId, err := w.InsertWaitlistEntry(capacity.PlanID, cusId)
+	if err == sql.ErrNoRows {
+		return nil, sub_errors.AlreadyOnWaitlistReqErr()
+	} else if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	entry, err := w.GetWaitlistEntry(*waitlistId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return entry, nil
+}
+
+func LeaveWaitlist(
+	sqlDB *sql.DB,
+	cusId int,
+	productId int,
+) (*models.RequestError) {
+	s := db.SubscriptionDB{DB: sqlDB}
+	w := db.WaitlistDB{DB: sqlDB}
+
+	subProduct, _, err := s.GetCreateSubData(productId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	err = w.LeaveWaitlist(subProduct.SubPlan.PlanID, cusId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// a held spot may have been given up
+	InviteFromWaitlist(sqlDB, subProduct.SubPlan.PlanID)
+	return nil
+}
+
+func GetCusWaitlists(
+	sqlDB *sql.DB,
+	cusId int,
+) ([]models.WaitlistEntry, *models.RequestError) {
+	w := db.WaitlistDB{DB: sqlDB}
+
+	entries, err := w.GetCusWaitlistEntries(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return entries, nil
+}
+
+// InviteFromWaitlist hands out a plan's free spots to the front of its waitlist,
+// each invitee gets waitlistClaimWindow to subscribe before the spot moves on
+func InviteFromWaitlist(sqlDB *sql.DB, planId int) {
+	w := db.WaitlistDB{DB: sqlDB}
+
+	claimBy := time.Now().Add(waitlistClaimWindow)
+	ids, err := w.InviteNextFromWaitlist(planId, claimBy)
+	if err == sql.ErrNoRows {
+		return
+	} else if err != nil {
+		log.Printf("Failed to invite from waitlist for plan %d: %v\n", planId, err)
+		return
+	}
+
+	for _, id := range ids {
+		entry, err := w.GetWaitlistEntry(id)
+		if err != nil {
+			log.Printf("Failed to get waitlist entry %d: %v\n", id, err)
+			continue
+		}
+
+		err = media.SendWaitlistInviteEmail(
+			*entry.CusEmail, *entry.CusFirstName, *entry.ProductName, *entry.BusinessName, claimBy,
+		)
+		if err != nil {
+			log.Printf("Failed to send waitlist invite %d: %v\n", id, err)
+		}
+	}
+}
+
+// HandleWaitlists lapses invitations that weren't claimed in time and
+// invites the next in line wherever spots have freed up
+func HandleWaitlists(sqlDB *sql.DB) {
+	w := db.WaitlistDB{DB: sqlDB}
+
+	err := w.ExpireWaitlistInvitations(time.Now())
+	if err != nil {
+		log.Println("Failed to expire waitlist invitations:", err)
+		return
+	}
+
+	planIds, err := w.GetPlansWithFreeSpots()
+	if err != nil {
+		log.Println("Failed to get plans with free spots:", err)
+		return
+	}
+
+	for _, planId := range planIds {
+		InviteFromWaitlist(sqlDB, planId)
+	}
+}
@@ -0,0 +1,97 @@
+package waitlist
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func Routes(waitlistRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	waitlistRouter.GET("", getCusWaitlistsHandler(sqlDB))
+
+	waitlistRouter.POST("join", joinWaitlistHandler(sqlDB))
+
+	waitlistRouter.DELETE("/:productId", leaveWaitlistHandler(sqlDB))
+}
+
+func getCusWaitlistsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetCusWaitlists(sqlDB, *cusId)
+		if reqErr != nil {
+			log.Println("Failed to get customer waitlists: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func joinWaitlistHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			ProductID	int	`json:"product_id"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		entry, reqErr := JoinWaitlist(sqlDB, *cusId, reqBody.ProductID)
+		if reqErr != nil {
+			log.Println("Failed to join waitlist: ", reqErr.Err)
+			if reqErr.Code != "" {
+				c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+				return
+			}
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, entry)
+	}
+}
+
+func leaveWaitlistHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		productIdInt, err := strconv.Atoi(c.Param("productId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := LeaveWaitlist(sqlDB, *cusId, productIdInt)
+		if reqErr != nil {
+			log.Println("Failed to leave waitlist: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
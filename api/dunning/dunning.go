@@ -0,0 +1,281 @@
+package dunning
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	firebase "firebase.google.com/go"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	"github.com/johnyeocx/usual/server/api/c/cus_email"
+	"github.com/johnyeocx/usual/server/api/c/subscription"
+	"github.com/johnyeocx/usual/server/api/notification"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/media"
+)
+
+var (
+	defaultReminderDays = []int64{0, 3, 7, 12}
+	defaultSuspendAfterDays = 7
+	defaultCancelAfterDays = 14
+	maxDunningDays = 30
+)
+
+// UpdateCardLink opens the app's update card screen for the subscription,
+// which resolves the outstanding payment intent with the chosen card
+func UpdateCardLink(subId int) string {
+	return fmt.Sprintf("usual://subscription/%d/update_card", subId)
+}
+
+func GetDunningSettings(sqlDB *sql.DB, businessId int) (*models.DunningSettings, error) {
+	d := db.DunningDB{DB: sqlDB}
+
+	settings, err := d.GetDunningSettings(businessId)
+	if err == sql.ErrNoRows {
+		return &models.DunningSettings{
+			BusinessID: businessId,
+			ReminderDays: defaultReminderDays,
+			SuspendAfterDays: defaultSuspendAfterDays,
+			CancelAfterDays: defaultCancelAfterDays,
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func UpdateDunningSettings(
+	sqlDB *sql.DB,
+	businessId int,
+	settings models.DunningSettings,
+) (*models.RequestError) {
+	d := db.DunningDB{DB: sqlDB}
+
+	if settings.CancelAfterDays < 1 || settings.CancelAfterDays > maxDunningDays {
+		return &models.RequestError{
+			Err: fmt.Errorf("cancel after days must be between 1 and %d", maxDunningDays),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if settings.SuspendAfterDays < 0 || settings.SuspendAfterDays > settings.CancelAfterDays {
+		return &models.RequestError{
+			Err: errors.New("suspend after days must be before cancellation"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if len(settings.ReminderDays) == 0 {
+		return &models.RequestError{
+			Err: errors.New("at least one reminder required"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	sort.Slice(settings.ReminderDays, func(i, j int) bool {
+		return settings.ReminderDays[i] < settings.ReminderDays[j]
+	})
+	for i, day := range settings.ReminderDays {
+		if day < 0 || day >= int64(settings.CancelAfterDays) || (i > 0 && day == settings.ReminderDays[i - 1]) {
+			return &models.RequestError{
+				Err: errors.New("reminder days must be unique and before cancellation"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+
+	settings.BusinessID = businessId
+	err := d.SetDunningSettings(settings)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return nil
+}
+
+// StartDunning opens a dunning case for a failed subscription invoice and emails the customer
+func StartDunning(sqlDB *sql.DB, fbApp *firebase.App, invoice *models.Invoice) (error) {
+	d := db.DunningDB{DB: sqlDB}
+
+	if !invoice.SubStripeID.Valid {
+		return nil
+	}
+
+	dunningId, err := d.InsertDunningCase(invoice.SubID, invoice.InStripeID, invoice.Total)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	dunning, err := d.GetDunningCase(*dunningId)
+	if err != nil {
+		return err
+	}
+
+	settings, err := GetDunningSettings(sqlDB, *dunning.BusinessID)
+	if err != nil {
+		return err
+	}
+
+	if settings.ReminderDays[0] == 0 {
+		return sendDunningReminder(sqlDB, fbApp, dunning, settings)
+	}
+
+	// the schedule's first reminder comes later, let them know now anyway
+	link := UpdateCardLink(dunning.SubID)
+	n := notification.PaymentFailed(*dunning.CustomerID, dunning.SubID, *dunning.ProductName, *dunning.BusinessName, link)
+	n.Email = func() error { return cus_email.SendPaymentFailed(sqlDB, dunning.SubID, dunning.Amount, link) }
+
+	return notification.Notify(sqlDB, fbApp, n)
+}
+
+// ResolveDunning closes the case on an invoice once it's paid or given up on
+func ResolveDunning(sqlDB *sql.DB, inStripeId string, status my_enums.DunningStatus) (error) {
+	d := db.DunningDB{DB: sqlDB}
+	_, err := d.ResolveDunningCase(inStripeId, status)
+	return err
+}
+
+func GetCusDunningCases(
+	sqlDB *sql.DB,
+	cusId int,
+) ([]models.DunningCase, *models.RequestError) {
+	d := db.DunningDB{DB: sqlDB}
+
+	cases, err := d.GetCusOpenDunningCases(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	for i := range cases {
+		cases[i].UpdateCardLink = UpdateCardLink(cases[i].SubID)
+	}
+	return cases, nil
+}
+
+// HandleDunning moves every open case along its business's schedule: sending
+// reminders that are due, suspending entitlements and finally cancelling
+func HandleDunning(sqlDB *sql.DB, fbApp *firebase.App) {
+	d := db.DunningDB{DB: sqlDB}
+	now := time.Now()
+
+	cases, err := d.GetOpenDunningCases()
+	if err != nil {
+		log.Println("Failed to get open dunning cases:", err)
+		return
+	}
+
+	settingsByBus := map[int]*models.DunningSettings{}
+	for _, dunning := range cases {
+		dunning := dunning
+
+		settings, ok := settingsByBus[*dunning.BusinessID]
+		if !ok {
+			settings, err = GetDunningSettings(sqlDB, *dunning.BusinessID)
+			if err != nil {
+				log.Printf("Failed to get dunning settings for business %d: %v\n", *dunning.BusinessID, err)
+				continue
+			}
+			settingsByBus[*dunning.BusinessID] = settings
+		}
+
+		if now.After(dunningDay(dunning.Started, int64(settings.CancelAfterDays))) {
+			err = cancelDunningSub(sqlDB, fbApp, &dunning)
+			if err != nil {
+				log.Printf("Failed to cancel subscription for dunning %d: %v\n", dunning.ID, err)
+			}
+			continue
+		}
+
+		if !dunning.Suspended && now.After(dunningDay(dunning.Started, int64(settings.SuspendAfterDays))) {
+			suspended, err := d.SetDunningSuspended(dunning.ID)
+			if err != nil {
+				log.Printf("Failed to suspend dunning %d: %v\n", dunning.ID, err)
+			} else if suspended {
+				go apple_pass.PassesChanged(sqlDB, dunning.SubID)
+			}
+		}
+
+		if dunning.RemindersSent < len(settings.ReminderDays) && 
+			now.After(dunningDay(dunning.Started, settings.ReminderDays[dunning.RemindersSent])) {
+			err = sendDunningReminder(sqlDB, fbApp, &dunning, settings)
+			if err != nil {
+				log.Printf("Failed to send reminder for dunning %d: %v\n", dunning.ID, err)
+			}
+		}
+	}
+}
+
+func dunningDay(started time.Time, days int64) (time.Time) {
+	return started.Add(time.Hour * 24 * time.Duration(days))
+}
+
+func sendDunningReminder(
+	sqlDB *sql.DB,
+	fbApp *firebase.App,
+	dunning *models.DunningCase,
+	settings *models.DunningSettings,
+) (error) {
+	d := db.DunningDB{DB: sqlDB}
+	link := UpdateCardLink(dunning.SubID)
+
+	// mark first so a failing channel doesn't resend every run, and
+	// so only one instance sends when the job runs on several
+	claimed, err := d.SetDunningReminderSent(dunning.ID, dunning.RemindersSent)
+	if err != nil || !claimed {
+		return err
+	}
+
+	n := notification.PaymentFailed(*dunning.CustomerID, dunning.SubID, *dunning.ProductName, *dunning.BusinessName, link)
+	n.Email = func() error {
+		return media.SendPaymentReminderEmail(
+			*dunning.CusEmail,
+			*dunning.CusFirstName,
+			*dunning.ProductName,
+			*dunning.BusinessName,
+			dunning.Amount,
+			link,
+			dunningDay(dunning.Started, int64(settings.SuspendAfterDays)),
+			dunningDay(dunning.Started, int64(settings.CancelAfterDays)),
+		)
+	}
+
+	return notification.Notify(sqlDB, fbApp, n)
+}
+
+func cancelDunningSub(sqlDB *sql.DB, fbApp *firebase.App, dunning *models.DunningCase) (error) {
+	d := db.DunningDB{DB: sqlDB}
+
+	// closing the case first claims it, another run that lost the race has nothing to do
+	resolved, err := d.ResolveDunningCase(dunning.InStripeID, my_enums.DunningLost)
+	if err != nil || !resolved {
+		return err
+	}
+
+	_, reqErr := subscription.EndSubscription(sqlDB, *dunning.CustomerID, dunning.SubID)
+	if reqErr != nil {
+		if err := d.ReopenDunningCase(dunning.ID); err != nil {
+			log.Printf("Failed to reopen dunning %d: %v\n", dunning.ID, err)
+		}
+		return reqErr.Err
+	}
+
+	n := notification.SubCancelled(*dunning.CustomerID, dunning.SubID, *dunning.ProductName, *dunning.BusinessName, true)
+	n.Email = func() error { return cus_email.SendCancellation(sqlDB, dunning.SubID, nil) }
+
+	return notification.Notify(sqlDB, fbApp, n)
+}
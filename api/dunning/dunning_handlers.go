@@ -0,0 +1,34 @@
+package dunning
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func Routes(dunningRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	dunningRouter.GET("", getCusDunningCasesHandler(sqlDB))
+}
+
+func getCusDunningCasesHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetCusDunningCases(sqlDB, *cusId)
+		if reqErr != nil {
+			log.Println("Failed to get customer dunning cases: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,256 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+	"github.com/johnyeocx/usual/server/utils/spreadsheet"
+)
+
+const dateLayout = "2006-01-02"
+const timeLayout = "2006-01-02 15:04:05"
+
+// Dataset is one exportable table, Stream writes each row through the writer as it's read
+type Dataset struct {
+	Name 		string
+	Columns 	[]spreadsheet.Column
+	Stream 		func(b *busdb.BusinessDB, businessId int, from time.Time, to time.Time, loc *time.Location, w spreadsheet.Writer) error
+}
+
+var datasets = map[string]Dataset{
+	"invoices": {
+		Name: "Invoices",
+		Columns: []spreadsheet.Column{
+			{Name: "Invoice ID"},
+			{Name: "Created"},
+			{Name: "Customer ID", Numeric: true},
+			{Name: "First Name"},
+			{Name: "Last Name"},
+			{Name: "Product ID", Numeric: true},
+			{Name: "Product"},
+			{Name: "Total", Numeric: true},
+			{Name: "Fee", Numeric: true},
+			{Name: "Status"},
+			{Name: "Payment Status"},
+			{Name: "Invoice URL"},
+		},
+		Stream: func(b *busdb.BusinessDB, businessId int, from time.Time, to time.Time, loc *time.Location, w spreadsheet.Writer) error {
+			return b.StreamBusinessInvoices(businessId, from, to, func(i models.InvoiceData) error {
+				productId, productName := "", ""
+				if i.ProductID != nil {
+					productId = strconv.Itoa(*i.ProductID)
+				}
+				if i.ProductName != nil {
+					productName = *i.ProductName
+				}
+
+				fee := ""
+				if i.ApplicationFeeAmt.Valid {
+					fee = formatAmount(i.ApplicationFeeAmt.Int64)
+				}
+
+				return w.WriteRow([]string{
+					i.InvoiceID,
+					i.Created.In(loc).Format(timeLayout),
+					strconv.Itoa(i.CustomerID),
+					i.CusFirstName,
+					i.CusLastName,
+					productId,
+					productName,
+					formatAmount(int64(i.Total)),
+					fee,
+					i.Status,
+					i.PaymentIntentStatus,
+					i.InvoiceURL,
+				})
+			})
+		},
+	},
+	"subscribers": {
+		Name: "Subscribers",
+		Columns: []spreadsheet.Column{
+			{Name: "Subscription ID", Numeric: true},
+			{Name: "Start Date"},
+			{Name: "Customer ID", Numeric: true},
+			{Name: "First Name"},
+			{Name: "Last Name"},
+			{Name: "Email"},
+			{Name: "Product ID", Numeric: true},
+			{Name: "Product"},
+			{Name: "Cancelled"},
+			{Name: "Cancelled Date"},
+			{Name: "Expires"},
+		},
+		Stream: func(b *busdb.BusinessDB, businessId int, from time.Time, to time.Time, loc *time.Location, w spreadsheet.Writer) error {
+			return b.StreamBusinessSubs(businessId, from, to, func(s models.SubInfo) error {
+				return w.WriteRow([]string{
+					strconv.Itoa(s.Subscription.ID),
+					s.Subscription.StartDate.In(loc).Format(timeLayout),
+					strconv.Itoa(s.Subscription.CustomerID),
+					s.FirstName,
+					s.LastName,
+					s.Email,
+					strconv.Itoa(s.ProductID),
+					s.ProductName,
+					strconv.FormatBool(s.Subscription.Cancelled),
+					formatNullTime(s.Subscription.CancelledDate.NullTime, loc),
+					formatNullTime(s.Subscription.Expires.NullTime, loc),
+				})
+			})
+		},
+	},
+	"usages": {
+		Name: "Usages",
+		Columns: []spreadsheet.Column{
+			{Name: "Created"},
+			{Name: "Customer UUID"},
+			{Name: "First Name"},
+			{Name: "Last Name"},
+			{Name: "Subscription ID", Numeric: true},
+			{Name: "Product ID", Numeric: true},
+			{Name: "Product"},
+			{Name: "Usage ID", Numeric: true},
+			{Name: "Usage"},
+		},
+		Stream: func(b *busdb.BusinessDB, businessId int, from time.Time, to time.Time, loc *time.Location, w spreadsheet.Writer) error {
+			return b.StreamBusinessUsages(businessId, from, to, func(u models.UsageInfo) error {
+				subId := ""
+				if u.SubID != 0 {
+					subId = strconv.Itoa(u.SubID)
+				}
+
+				return w.WriteRow([]string{
+					u.Created.In(loc).Format(timeLayout),
+					u.CusUUID,
+					u.CusFirstName,
+					u.CusLastName,
+					subId,
+					strconv.Itoa(u.ProductID),
+					u.ProductName,
+					strconv.Itoa(u.SubUsage.ID),
+					u.SubUsage.Title,
+				})
+			})
+		},
+	},
+	"payouts": {
+		Name: "Payouts",
+		Columns: []spreadsheet.Column{
+			{Name: "Payout ID", Numeric: true},
+			{Name: "Arrival Date"},
+			{Name: "Amount", Numeric: true},
+			{Name: "Currency"},
+			{Name: "Status"},
+			{Name: "Type"},
+		},
+		Stream: func(b *busdb.BusinessDB, businessId int, from time.Time, to time.Time, loc *time.Location, w spreadsheet.Writer) error {
+			return b.StreamBusinessPayouts(businessId, from, to, func(p bus_models.BusinessPayout) error {
+				return w.WriteRow([]string{
+					strconv.Itoa(p.ID),
+					p.ArrivalDate.In(loc).Format(dateLayout),
+					formatAmount(int64(p.Amount)),
+					p.Currency,
+					string(p.Status),
+					p.Type,
+				})
+			})
+		},
+	},
+}
+
+// ExportRange reads from/to as inclusive YYYY-MM-DD days in loc, an empty from means all history
+func ExportRange(from string, to string, loc *time.Location) (time.Time, time.Time, *models.RequestError) {
+	start := time.Unix(0, 0)
+	end := time.Now().In(loc).AddDate(0, 0, 1)
+
+	var err error
+	if from != "" {
+		start, err = time.ParseInLocation(dateLayout, from, loc)
+		if err != nil {
+			return start, end, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+
+	if to != "" {
+		end, err = time.ParseInLocation(dateLayout, to, loc)
+		if err != nil {
+			return start, end, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+
+	if !start.Before(end) {
+		return start, end, &models.RequestError{
+			Err: fmt.Errorf("from must be before to"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	return start, end, nil
+}
+
+func GetDataset(name string) (*Dataset, *models.RequestError) {
+	dataset, ok := datasets[name]
+	if !ok {
+		return nil, &models.RequestError{
+			Err: fmt.Errorf("unknown export %s", name),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+	return &dataset, nil
+}
+
+// WriteExport streams the dataset into out, nothing is buffered beyond the writer's own flush size
+func WriteExport(
+	sqlDB *sql.DB,
+	out io.Writer,
+	dataset *Dataset,
+	format spreadsheet.Format,
+	businessId int,
+	from time.Time,
+	to time.Time,
+	loc *time.Location,
+) (error) {
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	w, err := spreadsheet.NewWriter(out, format, dataset.Name, dataset.Columns)
+	if err != nil {
+		return err
+	}
+
+	if err := dataset.Stream(&b, businessId, from, to, loc, w); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount / 100, amount % 100)
+}
+
+func formatNullTime(t sql.NullTime, loc *time.Location) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.In(loc).Format(timeLayout)
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/analytics"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/spreadsheet"
+)
+
+func Routes(exportRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	exportRouter.GET("/:dataset", exportHandler(sqlDB))
+}
+
+func exportHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		dataset, reqErr := GetDataset(c.Param("dataset"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		format := spreadsheet.Format(c.DefaultQuery("format", string(spreadsheet.CSV)))
+		if format != spreadsheet.CSV && format != spreadsheet.XLSX {
+			c.JSON(http.StatusBadRequest, errors.New("format must be csv or xlsx").Error())
+			return
+		}
+
+		loc, err := analytics.BusinessLocation(sqlDB, *businessId)
+		if err != nil {
+			log.Println("Failed to get business location:", err)
+			c.JSON(http.StatusBadGateway, err)
+			return
+		}
+
+		from, to, reqErr := ExportRange(c.Query("from"), c.Query("to"), loc)
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		filename := fmt.Sprintf("%s_%s_%s.%s", c.Param("dataset"), 
+			from.Format(dateLayout), to.AddDate(0, 0, -1).Format(dateLayout), format)
+		c.Header("Content-Type", format.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		c.Status(http.StatusOK)
+
+		// headers are already sent so a failure part way can only be logged
+		err = WriteExport(sqlDB, c.Writer, dataset, format, *businessId, from, to, loc)
+		if err != nil {
+			log.Println("Failed to write export:", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package moderation
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// ListFlaggedReviews pages through reviews enough subscribers have flagged,
+// they stay public until a moderator hides or restores them
+func ListFlaggedReviews(sqlDB *sql.DB, page *models.PageParams) (*models.Page, *models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	reviews, next, err := r.ListFlaggedReviews(page)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	res := pagination.NewPage(reviews, next)
+	return &res, nil
+}
+
+func HideReview(sqlDB *sql.DB, reviewId int) (*models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	err := r.HideReview(reviewId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("review not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+// RestoreReview puts a flagged or hidden review back up
+func RestoreReview(sqlDB *sql.DB, reviewId int) (*models.RequestError) {
+	r := db.ReviewDB{DB: sqlDB}
+
+	err := r.RestoreReview(reviewId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("review not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package moderation
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// Routes are for staff, not customers or businesses, and authenticate with the
+// moderation key rather than a user token
+func Routes(moderationRouter *gin.RouterGroup, sqlDB *sql.DB) {
+	moderationRouter.GET("/reviews", listFlaggedReviewsHandler(sqlDB))
+
+	moderationRouter.POST("/reviews/:reviewId/hide", hideReviewHandler(sqlDB))
+	moderationRouter.POST("/reviews/:reviewId/restore", restoreReviewHandler(sqlDB))
+}
+
+func listFlaggedReviewsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		if err := middleware.AuthenticateModerator(c); err != nil {
+			c.JSON(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, reqErr := ListFlaggedReviews(sqlDB, page)
+		if reqErr != nil {
+			log.Println("Failed to list flagged reviews: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func hideReviewHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		if err := middleware.AuthenticateModerator(c); err != nil {
+			c.JSON(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := HideReview(sqlDB, reviewId)
+		if reqErr != nil {
+			log.Println("Failed to hide review: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func restoreReviewHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		if err := middleware.AuthenticateModerator(c); err != nil {
+			c.JSON(http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		reviewId, err := strconv.Atoi(c.Param("reviewId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := RestoreReview(sqlDB, reviewId)
+		if reqErr != nil {
+			log.Println("Failed to restore review: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,137 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/utils/money"
+)
+
+func PaymentSucceeded(cusId int, subId int, amount int, productName string, businessName string) (Notification) {
+	return Notification{
+		Event: my_enums.NEPaymentSucceeded,
+		CustomerID: cusId,
+		Title: "Payment Succeeded",
+		Body: fmt.Sprintf("You have successfully paid %s for your subscription to %s by %s",
+			money.Format(amount), productName, businessName),
+		Data: map[string]string{
+			"type": string(my_enums.PNPaymentSucceeded),
+			"sub_id": fmt.Sprint(subId),
+		},
+	}
+}
+
+func PaymentFailed(cusId int, subId int, productName string, businessName string, updateCardLink string) (Notification) {
+	return Notification{
+		Event: my_enums.NEPaymentFailed,
+		CustomerID: cusId,
+		Title: "Payment Failed",
+		Body: fmt.Sprintf("Your payment for %s by %s didn't go through, tap to update your card",
+			productName, businessName),
+		Data: map[string]string{
+			"type": string(my_enums.PNPaymentReminder),
+			"sub_id": fmt.Sprint(subId),
+			"link": updateCardLink,
+		},
+	}
+}
+
+// SubCancelled covers both customer cancellations and subscriptions ended
+// because payment was never recovered
+func SubCancelled(cusId int, subId int, productName string, businessName string, unpaid bool) (Notification) {
+	title := "Subscription Cancelled"
+	body := fmt.Sprintf("Your subscription to %s by %s has been cancelled", productName, businessName)
+	if unpaid {
+		title = "Payment Failed"
+		body = fmt.Sprintf("Your subscription to %s by %s has been cancelled due to an expired payment",
+			productName, businessName)
+	}
+
+	return Notification{
+		Event: my_enums.NESubCancelled,
+		CustomerID: cusId,
+		Title: title,
+		Body: body,
+		Data: map[string]string{
+			"type": string(my_enums.PNSubCancelled),
+			"sub_id": fmt.Sprint(subId),
+		},
+	}
+}
+
+func UsageRedeemed(cusId int, subId int, usageTitle string, businessName string) (Notification) {
+	return Notification{
+		Event: my_enums.NEUsageRedeemed,
+		CustomerID: cusId,
+		Title: "Redeemed",
+		Body: fmt.Sprintf("You redeemed %s at %s", usageTitle, businessName),
+		Data: map[string]string{
+			"type": string(my_enums.PNUsageRedeemed),
+			"sub_id": fmt.Sprint(subId),
+		},
+	}
+}
+
+func RenewalUpcoming(
+	cusId int,
+	subId int,
+	amount int,
+	productName string,
+	businessName string,
+	renews time.Time,
+) (Notification) {
+	return Notification{
+		Event: my_enums.NERenewalUpcoming,
+		CustomerID: cusId,
+		Title: "Renewal Upcoming",
+		Body: fmt.Sprintf("Your subscription to %s by %s renews on %s for %s",
+			productName, businessName, renews.Format("2 Jan 2006"), money.Format(amount)),
+		Data: map[string]string{
+			"type": string(my_enums.PNRenewalUpcoming),
+			"sub_id": fmt.Sprint(subId),
+		},
+	}
+}
+
+func NewSubscriber(businessId int, subId int, cusName string, productName string) (Notification) {
+	return Notification{
+		Event: my_enums.NENewSubscriber,
+		BusinessID: businessId,
+		Title: "New Subscriber",
+		Body: fmt.Sprintf("%s subscribed to %s", cusName, productName),
+		Data: map[string]string{
+			"sub_id": fmt.Sprint(subId),
+		},
+	}
+}
+
+func SubscriberCancelled(businessId int, subId int, cusName string, productName string, unpaid bool) (Notification) {
+	body := fmt.Sprintf("%s cancelled their subscription to %s", cusName, productName)
+	if unpaid {
+		body = fmt.Sprintf("%s's subscription to %s was cancelled after payment failed", cusName, productName)
+	}
+
+	return Notification{
+		Event: my_enums.NESubCancelled,
+		BusinessID: businessId,
+		Title: "Subscription Cancelled",
+		Body: body,
+		Data: map[string]string{
+			"sub_id": fmt.Sprint(subId),
+		},
+	}
+}
+
+func PayoutPaid(businessId int, stripePayoutId string, amount int, arrival time.Time) (Notification) {
+	return Notification{
+		Event: my_enums.NEPayoutPaid,
+		BusinessID: businessId,
+		Title: "Payout Sent",
+		Body: fmt.Sprintf("A payout of %s is on its way and should arrive by %s",
+			money.Format(amount), arrival.Format("2 Jan 2006")),
+		Data: map[string]string{
+			"stripe_payout_id": stripePayoutId,
+		},
+	}
+}
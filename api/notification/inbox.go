@@ -0,0 +1,115 @@
+package notification
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// setType defaults the "type" the apps route on to the event
+func setType(n *Notification) {
+	if n.Data == nil {
+		n.Data = map[string]string{}
+	}
+	if _, ok := n.Data["type"]; !ok {
+		n.Data["type"] = string(n.Event)
+	}
+}
+
+func addToInbox(nDB *db.NotificationDB, r models.Recipient, n Notification) (error) {
+	_, err := nDB.InsertNotification(r, models.Notification{
+		Event: n.Event,
+		Title: n.Title,
+		Body: n.Body,
+		Data: n.Data,
+		Created: time.Now(),
+	})
+	return err
+}
+
+// NotifyBusiness adds n to the business's inbox, businesses only get in-app notifications
+func NotifyBusiness(sqlDB *sql.DB, n Notification) (error) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	setType(&n)
+
+	return addToInbox(&nDB, models.Recipient{BusinessID: &n.BusinessID}, n)
+}
+
+func ListInbox(
+	sqlDB *sql.DB,
+	r models.Recipient,
+	unreadOnly bool,
+	page *models.PageParams,
+) (map[string]interface{}, *models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	notifications, next, err := nDB.ListNotifications(r, unreadOnly, page)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	unread, err := nDB.CountUnreadNotifications(r)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return map[string]interface{}{
+		"notifications": pagination.NewPage(notifications, next),
+		"unread_count": unread,
+	}, nil
+}
+
+func GetUnreadCount(sqlDB *sql.DB, r models.Recipient) (*int, *models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	unread, err := nDB.CountUnreadNotifications(r)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return &unread, nil
+}
+
+func MarkRead(sqlDB *sql.DB, r models.Recipient, notificationId int) (*models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	err := nDB.MarkNotificationRead(r, notificationId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+func MarkAllRead(sqlDB *sql.DB, r models.Recipient) (*models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	err := nDB.MarkAllNotificationsRead(r)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// RecipientFunc authenticates the request and returns whose inbox it's for
+type RecipientFunc func(c *gin.Context, sqlDB *sql.DB) (*models.Recipient, error)
+
+func CustomerRecipient(c *gin.Context, sqlDB *sql.DB) (*models.Recipient, error) {
+	cusId, err := middleware.AuthenticateCId(c, sqlDB)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Recipient{CustomerID: cusId}, nil
+}
+
+func BusinessRecipient(c *gin.Context, sqlDB *sql.DB) (*models.Recipient, error) {
+	businessId, err := middleware.AuthenticateBId(c, sqlDB)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Recipient{BusinessID: businessId}, nil
+}
+
+// InboxRoutes serves an inbox under the customer and business routers
+func InboxRoutes(inboxRouter *gin.RouterGroup, sqlDB *sql.DB, recipient RecipientFunc) {
+	inboxRouter.GET("", listInboxHandler(sqlDB, recipient))
+	inboxRouter.GET("/unread_count", getUnreadCountHandler(sqlDB, recipient))
+
+	inboxRouter.PATCH("/read_all", markAllReadHandler(sqlDB, recipient))
+	inboxRouter.PATCH("/:notificationId/read", markReadHandler(sqlDB, recipient))
+}
+
+func listInboxHandler(sqlDB *sql.DB, recipient RecipientFunc) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		r, err := recipient(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		page, reqErr := pagination.ParsePage(c.Query("limit"), c.Query("cursor"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		res, reqErr := ListInbox(sqlDB, *r, c.Query("unread") == "true", page)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func getUnreadCountHandler(sqlDB *sql.DB, recipient RecipientFunc) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		r, err := recipient(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		unread, reqErr := GetUnreadCount(sqlDB, *r)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]int{
+			"unread_count": *unread,
+		})
+	}
+}
+
+func markReadHandler(sqlDB *sql.DB, recipient RecipientFunc) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		r, err := recipient(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		notificationId, err := strconv.Atoi(c.Param("notificationId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := MarkRead(sqlDB, *r, notificationId)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func markAllReadHandler(sqlDB *sql.DB, recipient RecipientFunc) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		r, err := recipient(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqErr := MarkAllRead(sqlDB, *r)
+		if reqErr != nil {
+			reqErr.Log()
+			c.JSON(reqErr.StatusCode, reqErr.ErrToMap())
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
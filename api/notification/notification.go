@@ -0,0 +1,391 @@
+package notification
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	firebase "firebase.google.com/go"
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db"
+	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/media"
+	"github.com/johnyeocx/usual/server/utils/fcm"
+)
+
+// Notification is one event for a customer or business. Email sends the event's own
+// templated email, events without one get a plain email built from the title and body
+type Notification struct {
+	Event 		my_enums.NotificationEvent
+	CustomerID 	int
+	BusinessID 	int
+	Title 		string
+	Body 		string
+	Data 		map[string]string
+	Email 		func() error
+}
+
+var events = []my_enums.NotificationEvent{
+	my_enums.NEPaymentSucceeded,
+	my_enums.NEPaymentFailed,
+	my_enums.NESubCancelled,
+	my_enums.NEUsageRedeemed,
+	my_enums.NERenewalUpcoming,
+}
+
+// sms is opt in everywhere, and redemptions happen in person so only get a push
+func defaultPreference(event my_enums.NotificationEvent) (models.NotificationPreference) {
+	return models.NotificationPreference{
+		Event: event,
+		Push: true,
+		Email: event != my_enums.NEUsageRedeemed,
+		SMS: false,
+	}
+}
+
+func getPreference(
+	n *db.NotificationDB,
+	cusId int,
+	event my_enums.NotificationEvent,
+) (*models.NotificationPreference, error) {
+	pref, err := n.GetNotificationPreference(cusId, event)
+	if err == sql.ErrNoRows {
+		p := defaultPreference(event)
+		return &p, nil
+	}
+	return pref, err
+}
+
+func getSettings(n *db.NotificationDB, cusId int) (*models.NotificationSettings, error) {
+	settings, err := n.GetNotificationSettings(cusId)
+	if err == sql.ErrNoRows {
+		return &models.NotificationSettings{TimeZone: "Europe/London"}, nil
+	}
+	return settings, err
+}
+
+// quietUntil returns when the customer's quiet hours end if now falls inside them
+func quietUntil(settings *models.NotificationSettings, now time.Time) (*time.Time) {
+	if settings.QuietStart == nil || settings.QuietEnd == nil || *settings.QuietStart == *settings.QuietEnd {
+		return nil
+	}
+
+	loc, err := time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour() * 60 + local.Minute()
+	start, end := *settings.QuietStart, *settings.QuietEnd
+
+	quiet := minute >= start && minute < end
+	if start > end {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return nil
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end / 60, end % 60, 0, 0, loc)
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day() + 1, end / 60, end % 60, 0, 0, loc)
+	}
+	return &until
+}
+
+// Notify adds n to the customer's inbox and delivers it on every channel they've left
+// on for the event. Push and sms are held through quiet hours, email goes out
+// straight away. Channel failures are logged rather than returned
+func Notify(sqlDB *sql.DB, fbApp *firebase.App, n Notification) (error) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	setType(&n)
+
+	err := addToInbox(&nDB, models.Recipient{CustomerID: &n.CustomerID}, n)
+	if err != nil {
+		return err
+	}
+
+	pref, err := getPreference(&nDB, n.CustomerID, n.Event)
+	if err != nil {
+		return err
+	}
+
+	settings, err := getSettings(&nDB, n.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	until := quietUntil(settings, now)
+
+	channels := []my_enums.NotificationChannel{}
+	if pref.Push {
+		channels = append(channels, my_enums.NCPush)
+	}
+	if pref.SMS {
+		channels = append(channels, my_enums.NCSMS)
+	}
+
+	for _, channel := range channels {
+		l := models.NotificationLog{
+			CustomerID: n.CustomerID,
+			Event: n.Event,
+			Channel: channel,
+			Title: n.Title,
+			Body: n.Body,
+			Data: n.Data,
+			Created: now,
+		}
+
+		if until != nil {
+			l.Status = my_enums.NotificationHeld
+			l.SendAfter = models.JsonNullTime{NullTime: sql.NullTime{Time: *until, Valid: true}}
+			if _, err := nDB.InsertNotificationLog(l); err != nil {
+				log.Printf("Failed to hold %s notification for customer %d: %v\n", channel, n.CustomerID, err)
+			}
+			continue
+		}
+
+		deliver(sqlDB, fbApp, settings, &l)
+		if _, err := nDB.InsertNotificationLog(l); err != nil {
+			log.Printf("Failed to log %s notification for customer %d: %v\n", channel, n.CustomerID, err)
+		}
+	}
+
+	if pref.Email {
+		l := models.NotificationLog{
+			CustomerID: n.CustomerID,
+			Event: n.Event,
+			Channel: my_enums.NCEmail,
+			Title: n.Title,
+			Body: n.Body,
+			Data: n.Data,
+			Created: now,
+		}
+		setResult(&l, sendEmail(sqlDB, n))
+
+		if _, err := nDB.InsertNotificationLog(l); err != nil {
+			log.Printf("Failed to log email notification for customer %d: %v\n", n.CustomerID, err)
+		}
+	}
+
+	return nil
+}
+
+var errNoDestination = errors.New("no destination for channel")
+
+func sendEmail(sqlDB *sql.DB, n Notification) (error) {
+	if n.Email != nil {
+		return n.Email()
+	}
+
+	c := cusdb.CustomerDB{DB: sqlDB}
+	cus, err := c.GetCustomerByID(n.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	return media.SendNotificationEmail(cus.Email, cus.FirstName, n.Title, n.Body)
+}
+
+// deliver sends a push or sms notification and records the result on l
+func deliver(
+	sqlDB *sql.DB,
+	fbApp *firebase.App,
+	settings *models.NotificationSettings,
+	l *models.NotificationLog,
+) {
+	var err error
+
+	switch l.Channel {
+	case my_enums.NCPush:
+		err = push(sqlDB, fbApp, l)
+
+	case my_enums.NCSMS:
+		if settings.Mobile == nil || *settings.Mobile == "" {
+			err = errNoDestination
+		} else {
+			err = media.MessageClient(fmt.Sprintf("%s: %s", l.Title, l.Body), *settings.Mobile)
+		}
+	}
+
+	setResult(l, err)
+}
+
+// push multicasts to every device the customer has registered, dropping the ones
+// FCM says are gone
+func push(sqlDB *sql.DB, fbApp *firebase.App, l *models.NotificationLog) (error) {
+	c := cusdb.CustomerDB{DB: sqlDB}
+
+	fcmTokens, err := c.GetCusFCMTokens(l.CustomerID)
+	if err != nil {
+		return err
+	}
+	if len(fcmTokens) == 0 || fbApp == nil {
+		return errNoDestination
+	}
+
+	delivered, stale, err := fcm.SendToDevices(fbApp, fcmTokens, l.Title, l.Body, l.Data)
+	if len(stale) > 0 {
+		if err := c.DeleteFCMTokens(stale); err != nil {
+			log.Printf("Failed to remove stale fcm tokens for customer %d: %v\n", l.CustomerID, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if delivered == 0 {
+		return errNoDestination
+	}
+	return nil
+}
+
+func setResult(l *models.NotificationLog, err error) {
+	if err == errNoDestination {
+		l.Status = my_enums.NotificationSkipped
+		l.Error = models.JsonNullString{NullString: sql.NullString{String: err.Error(), Valid: true}}
+	} else if err != nil {
+		l.Status = my_enums.NotificationFailed
+		l.Error = models.JsonNullString{NullString: sql.NullString{String: err.Error(), Valid: true}}
+	} else {
+		l.Status = my_enums.NotificationSent
+		l.Sent = models.JsonNullTime{NullTime: sql.NullTime{Time: time.Now(), Valid: true}}
+	}
+}
+
+// SendHeldNotifications delivers push and sms notifications held back by quiet hours
+func SendHeldNotifications(sqlDB *sql.DB, fbApp *firebase.App) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	held, err := nDB.ClaimDueHeldNotifications(time.Now())
+	if err != nil {
+		log.Println("Failed to get held notifications:", err)
+		return
+	}
+
+	for _, l := range held {
+		settings, err := getSettings(&nDB, l.CustomerID)
+		if err != nil {
+			log.Printf("Failed to get notification settings for customer %d: %v\n", l.CustomerID, err)
+			continue
+		}
+
+		deliver(sqlDB, fbApp, settings, &l)
+
+		err = nDB.SetNotificationLogStatus(l.ID, l.Status, l.Error.NullString)
+		if err != nil {
+			log.Printf("Failed to update notification %d: %v\n", l.ID, err)
+		}
+	}
+}
+
+// GetPreferences lists every event with the customer's choices filled in over the defaults
+func GetPreferences(sqlDB *sql.DB, cusId int) (map[string]interface{}, *models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	saved, err := nDB.GetNotificationPreferences(cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	settings, err := getSettings(&nDB, cusId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	prefs := []models.NotificationPreference{}
+	for _, event := range events {
+		pref := defaultPreference(event)
+		for _, p := range saved {
+			if p.Event == event {
+				pref = p
+			}
+		}
+		prefs = append(prefs, pref)
+	}
+
+	return map[string]interface{}{
+		"preferences": prefs,
+		"settings": settings,
+	}, nil
+}
+
+func UpdatePreference(
+	sqlDB *sql.DB,
+	cusId int,
+	pref models.NotificationPreference,
+) (*models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	if !my_enums.ValidNotificationEvent(pref.Event) {
+		return &models.RequestError{
+			Err: fmt.Errorf("unknown notification event %s", pref.Event),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	err := nDB.UpsertNotificationPreference(cusId, pref)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+func UpdateSettings(
+	sqlDB *sql.DB,
+	cusId int,
+	settings models.NotificationSettings,
+) (*models.RequestError) {
+	nDB := db.NotificationDB{DB: sqlDB}
+
+	if (settings.QuietStart == nil) != (settings.QuietEnd == nil) {
+		return &models.RequestError{
+			Err: errors.New("quiet hours need both a start and an end"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	for _, m := range []*int{settings.QuietStart, settings.QuietEnd} {
+		if m != nil && (*m < 0 || *m >= 24 * 60) {
+			return &models.RequestError{
+				Err: errors.New("quiet hours must be minutes within a day"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+
+	if settings.TimeZone == "" {
+		settings.TimeZone = "Europe/London"
+	}
+	if _, err := time.LoadLocation(settings.TimeZone); err != nil {
+		return &models.RequestError{
+			Err: fmt.Errorf("unknown time zone %s", settings.TimeZone),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	err := nDB.UpsertNotificationSettings(cusId, settings)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
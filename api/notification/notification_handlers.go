@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func Routes(notificationRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	notificationRouter.GET("/preferences", getPreferencesHandler(sqlDB))
+	notificationRouter.PATCH("/preferences", updatePreferenceHandler(sqlDB))
+	notificationRouter.PATCH("/settings", updateSettingsHandler(sqlDB))
+}
+
+func getPreferencesHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		res, reqErr := GetPreferences(sqlDB, *cusId)
+		if reqErr != nil {
+			log.Println("Failed to get notification preferences: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func updatePreferenceHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		var reqBody models.NotificationPreference
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := UpdatePreference(sqlDB, *cusId, reqBody)
+		if reqErr != nil {
+			log.Println("Failed to update notification preference: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func updateSettingsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		cusId, err := middleware.AuthenticateCId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		var reqBody models.NotificationSettings
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := UpdateSettings(sqlDB, *cusId, reqBody)
+		if reqErr != nil {
+			log.Println("Failed to update notification settings: ", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,193 @@
+package recommendation
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/johnyeocx/usual/server/constants"
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+// how far back usage counts towards how much a customer cares about a subscription
+const usageWindow = 90 * 24 * time.Hour
+
+// at most this many products are kept per customer
+const maxPerCustomer = 50
+
+// weights of each signal in a product's score
+const (
+	coSubWeight		= 0.5
+	categoryWeight	= 0.35
+	popularWeight	= 0.15
+)
+
+// businesses in a different category with the same mcc count for this much of a match
+const relatedCategoryWeight = 0.5
+
+// ComputeRecommendations precomputes every customer's explore feed. Customers without
+// subscriptions get nothing and explore falls back to the global list for them
+func ComputeRecommendations(sqlDB *sql.DB) {
+	r := db.RecommendationDB{DB: sqlDB}
+
+	signals, err := r.GetSubSignals(time.Now().Add(-usageWindow))
+	if err != nil {
+		log.Println("Failed to get subscription signals: ", err)
+		return
+	}
+
+	profiles, err := r.GetProductProfiles()
+	if err != nil {
+		log.Println("Failed to get product profiles: ", err)
+		return
+	}
+
+	recs := rank(signals, profiles)
+	if err := r.ReplaceRecommendations(recs); err != nil {
+		log.Println("Failed to save recommendations: ", err)
+	}
+}
+
+// rank scores every product a customer doesn't already have by how often it's
+// subscribed to alongside theirs, how well its category matches the ones they use,
+// and how popular it is overall
+func rank(signals []models.SubSignal, profiles []models.ProductProfile) ([]models.Recommendation) {
+	mccs := categoryMCCs()
+
+	// 1. each customer's products, weighted by how much they use them
+	owned := map[int]map[int]float64{}
+	for _, s := range signals {
+		if owned[s.CustomerID] == nil {
+			owned[s.CustomerID] = map[int]float64{}
+		}
+		owned[s.CustomerID][s.ProductID] = 1 + math.Log1p(float64(s.UsageCount))
+	}
+
+	// 2. how many customers have each product, and each pair of products
+	subscribers := map[int]int{}
+	together := map[int]map[int]int{}
+	for _, products := range owned {
+		for p := range products {
+			subscribers[p]++
+			for q := range products {
+				if p == q {
+					continue
+				}
+				if together[p] == nil {
+					together[p] = map[int]int{}
+				}
+				together[p][q]++
+			}
+		}
+	}
+
+	categories := map[int]string{}
+	for _, s := range signals {
+		categories[s.ProductID] = s.BusinessCategory
+	}
+
+	maxSubs := 0
+	for _, p := range profiles {
+		if p.SubCount > maxSubs {
+			maxSubs = p.SubCount
+		}
+	}
+
+	recs := []models.Recommendation{}
+	for cusId, products := range owned {
+		// 3. share of the customer's usage in each category, and in each mcc
+		total := 0.0
+		categoryShare := map[string]float64{}
+		mccShare := map[string]float64{}
+		for p, w := range products {
+			total += w
+			cat := categories[p]
+			if mcc, ok := mccs[cat]; ok {
+				categoryShare[cat] += w
+				mccShare[mcc] += w
+			}
+		}
+
+		// 4. cosine similarity of each candidate to the customer's products
+		coSub := map[int]float64{}
+		for p, w := range products {
+			for q, n := range together[p] {
+				if _, ok := products[q]; ok {
+					continue
+				}
+				sim := float64(n) / math.Sqrt(float64(subscribers[p] * subscribers[q]))
+				coSub[q] += w * sim
+			}
+		}
+
+		cusRecs := []models.Recommendation{}
+		for _, prof := range profiles {
+			if _, ok := products[prof.ProductID]; ok {
+				continue
+			}
+
+			co := coSub[prof.ProductID] / total
+
+			cat := categoryShare[prof.BusinessCategory] / total
+			if mcc, ok := mccs[prof.BusinessCategory]; ok {
+				cat = math.Max(cat, relatedCategoryWeight * mccShare[mcc] / total)
+			} else {
+				cat = 0
+			}
+
+			// popularity alone isn't personal, the global list already covers that
+			if co == 0 && cat == 0 {
+				continue
+			}
+
+			pop := 0.0
+			if maxSubs > 0 {
+				pop = math.Log1p(float64(prof.SubCount)) / math.Log1p(float64(maxSubs))
+			}
+
+			reason := models.RecommendedSubscribedTogether
+			best := coSubWeight * co
+			if categoryWeight * cat > best {
+				reason, best = models.RecommendedCategory, categoryWeight * cat
+			}
+			if popularWeight * pop > best {
+				reason = models.RecommendedPopular
+			}
+
+			cusRecs = append(cusRecs, models.Recommendation{
+				CustomerID: cusId,
+				ProductID: prof.ProductID,
+				Score: coSubWeight * co + categoryWeight * cat + popularWeight * pop,
+				Reason: reason,
+			})
+		}
+
+		sort.Slice(cusRecs, func(i, j int) bool {
+			if cusRecs[i].Score != cusRecs[j].Score {
+				return cusRecs[i].Score > cusRecs[j].Score
+			}
+			return cusRecs[i].ProductID < cusRecs[j].ProductID
+		})
+		if len(cusRecs) > maxPerCustomer {
+			cusRecs = cusRecs[:maxPerCustomer]
+		}
+		recs = append(recs, cusRecs...)
+	}
+
+	return recs
+}
+
+// categoryMCCs maps each business category label to its merchant category code.
+// Categories not in the list are ignored rather than matched on their free text
+func categoryMCCs() (map[string]string) {
+	mccs := map[string]string{}
+	for _, c := range constants.BusinessCategories {
+		label, _ := c["label"].(string)
+		mcc, _ := c["mcc"].(string)
+		mccs[label] = mcc
+	}
+	return mccs
+}
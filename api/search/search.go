@@ -0,0 +1,39 @@
+package search
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/johnyeocx/usual/server/db"
+)
+
+// the index is only a copy of product and business data, so failing to update it is
+// logged rather than failing the change. The nightly rebuild catches anything missed
+
+func ProductChanged(sqlDB *sql.DB, productId int) {
+	s := db.SearchDB{DB: sqlDB}
+	if err := s.IndexProduct(productId); err != nil {
+		log.Printf("Failed to index product %d: %v\n", productId, err)
+	}
+}
+
+func ProductDeleted(sqlDB *sql.DB, productId int, businessId int) {
+	s := db.SearchDB{DB: sqlDB}
+	if err := s.RemoveProduct(productId, businessId); err != nil {
+		log.Printf("Failed to remove product %d from search: %v\n", productId, err)
+	}
+}
+
+func BusinessChanged(sqlDB *sql.DB, businessId int) {
+	s := db.SearchDB{DB: sqlDB}
+	if err := s.IndexBusiness(businessId); err != nil {
+		log.Printf("Failed to index business %d: %v\n", businessId, err)
+	}
+}
+
+func RebuildIndex(sqlDB *sql.DB) {
+	s := db.SearchDB{DB: sqlDB}
+	if err := s.RebuildIndex(); err != nil {
+		log.Printf("Failed to rebuild search index: %v\n", err)
+	}
+}
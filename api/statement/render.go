@@ -0,0 +1,128 @@
+package statement
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+	"github.com/johnyeocx/usual/server/utils/money"
+	"github.com/johnyeocx/usual/server/utils/pdf"
+)
+
+const (
+	marginX 	= 50.0
+	top 		= 790.0
+	bottom 		= 60.0
+	right 		= pdf.PageWidth - marginX
+)
+
+type statementData struct {
+	BusinessName	string
+	Statement		bus_models.Statement
+	Products		[]bus_models.StatementProduct
+	Payouts			[]bus_models.BusinessPayout
+	Loc				*time.Location
+}
+
+// layout tracks where the next line goes and starts a new page when we run out
+type layout struct {
+	doc		*pdf.Document
+	y		float64
+}
+
+func (l *layout) next(height float64) (float64) {
+	if l.y - height < bottom {
+		l.doc.AddPage()
+		l.y = top
+	}
+	l.y -= height
+	return l.y
+}
+
+func (l *layout) heading(title string) {
+	y := l.next(34)
+	l.doc.Text(marginX, y, pdf.HelveticaBold, 13, title)
+	l.doc.Line(marginX, y - 6, right, y - 6, 0.5)
+	l.y -= 6
+}
+
+func (l *layout) row(label string, amount string, bold bool) {
+	y := l.next(18)
+	font := pdf.Helvetica
+	if bold {
+		font = pdf.HelveticaBold
+	}
+	l.doc.Text(marginX, y, font, 10, label)
+	l.doc.TextRight(right, y, 10, amount)
+}
+
+func renderStatement(d statementData) ([]byte) {
+	st := d.Statement
+	monthStart := time.Date(st.Month.Year(), st.Month.Month(), 1, 0, 0, 0, 0, d.Loc)
+	monthEnd := monthStart.AddDate(0, 1, -1)
+
+	l := layout{doc: pdf.New(), y: top}
+
+	l.doc.Text(marginX, l.next(0), pdf.HelveticaBold, 22, "Usual")
+	l.doc.Text(right - 150, l.y, pdf.HelveticaBold, 16, "Monthly Statement")
+	l.doc.Text(marginX, l.next(28), pdf.Helvetica, 11, d.BusinessName)
+	l.doc.Text(marginX, l.next(16), pdf.Helvetica, 10, fmt.Sprintf(
+		"%s to %s", monthStart.Format("2 January 2006"), monthEnd.Format("2 January 2006")))
+	l.doc.Text(marginX, l.next(14), pdf.Helvetica, 9, fmt.Sprintf(
+		"Generated %s, amounts in %s", st.Created.In(d.Loc).Format("2 Jan 2006 15:04"), st.Currency))
+
+	net := st.Gross - st.Fees - st.Refunds
+
+	l.heading("Summary")
+	l.row("Opening balance", money.Format(st.OpeningBalance), false)
+	l.row("Gross revenue", money.Format(st.Gross), false)
+	l.row("Application fees", money.Format(-st.Fees), false)
+	l.row("Refunds", money.Format(-st.Refunds), false)
+	l.row("Net revenue", money.Format(net), true)
+	l.row("Payouts", money.Format(-st.Payouts), false)
+	l.doc.Line(marginX, l.y - 6, right, l.y - 6, 0.5)
+	l.y -= 6
+	l.row("Closing balance", money.Format(st.ClosingBalance), true)
+
+	l.heading("Revenue by product")
+	if len(d.Products) == 0 {
+		l.doc.Text(marginX, l.next(18), pdf.Helvetica, 10, "No subscription payments this month")
+	} else {
+		y := l.next(18)
+		l.doc.Text(marginX, y, pdf.HelveticaBold, 9, "Product")
+		l.doc.Text(right - 250, y, pdf.HelveticaBold, 9, "Payments")
+		l.doc.Text(right - 150, y, pdf.HelveticaBold, 9, "Gross")
+		l.doc.Text(right - 40, y, pdf.HelveticaBold, 9, "Fees")
+
+		for _, p := range d.Products {
+			y := l.next(16)
+			l.doc.Text(marginX, y, pdf.Helvetica, 10, truncate(p.Name, 40))
+			l.doc.TextRight(right - 200, y, 10, strconv.Itoa(p.Payments))
+			l.doc.TextRight(right - 90, y, 10, money.Format(p.Gross))
+			l.doc.TextRight(right, y, 10, money.Format(p.Fees))
+		}
+	}
+
+	l.heading("Payouts")
+	if len(d.Payouts) == 0 {
+		l.doc.Text(marginX, l.next(18), pdf.Helvetica, 10, "No payouts this month")
+	} else {
+		for _, p := range d.Payouts {
+			y := l.next(16)
+			l.doc.Text(marginX, y, pdf.Helvetica, 10, p.ArrivalDate.In(d.Loc).Format("2 Jan 2006"))
+			l.doc.Text(marginX + 120, y, pdf.Helvetica, 10, string(p.Status))
+			l.doc.TextRight(right, y, 10, money.Format(p.Amount))
+		}
+	}
+
+	return l.doc.Bytes()
+}
+
+func truncate(s string, n int) (string) {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n - 1]) + "…"
+}
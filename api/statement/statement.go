@@ -0,0 +1,273 @@
+package statement
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/johnyeocx/usual/server/api/analytics"
+	busdb "github.com/johnyeocx/usual/server/db/bus_db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/external/media"
+)
+
+var (
+	statementCurrency = "GBP"
+	statementUrlExpiry = time.Minute * 15
+)
+
+func statementKey(businessId int, month time.Time) (string) {
+	return fmt.Sprintf("./business/statement/%d/%s.pdf", businessId, month.Format("2006-01"))
+}
+
+// ParseMonth reads YYYY-MM, months are kept as the 1st in UTC and only
+// mapped onto the business's time zone when summing
+func ParseMonth(month string) (time.Time, *models.RequestError) {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return t, &models.RequestError{
+			Err: errors.New("month must be YYYY-MM"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+	return t, nil
+}
+
+// lastCompleteMonth is the month before the one it is now for the business
+func lastCompleteMonth(loc *time.Location) (time.Time) {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+}
+
+// GenerateStatement sums the month in the business's time zone, renders it and
+// stores it, replacing any earlier copy
+func GenerateStatement(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	month time.Time,
+) (*bus_models.Statement, []byte, *models.RequestError) {
+	b := busdb.BusinessDB{DB: sqlDB}
+	s := busdb.StatementDB{DB: sqlDB}
+
+	loc, err := analytics.BusinessLocation(sqlDB, businessId)
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 1, 0)
+	if to.After(time.Now()) {
+		return nil, nil, &models.RequestError{
+			Err: errors.New("month isn't over yet"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	business, err := b.GetBusinessByID(businessId)
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 1. balance carried in from everything before the month
+	before, err := s.GetStatementTotals(businessId, time.Unix(0, 0), from)
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	totals, err := s.GetStatementTotals(businessId, from, to)
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	products, err := s.GetStatementProducts(businessId, from, to)
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	payouts := []bus_models.BusinessPayout{}
+	err = b.StreamBusinessPayouts(businessId, from, to, func(p bus_models.BusinessPayout) error {
+		payouts = append(payouts, p)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	opening := before.Gross - before.Fees - before.Refunds - before.Payouts
+	st := bus_models.Statement{
+		BusinessID: businessId,
+		Month: time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC),
+		Currency: statementCurrency,
+		Gross: totals.Gross,
+		Fees: totals.Fees,
+		Refunds: totals.Refunds,
+		Payouts: totals.Payouts,
+		OpeningBalance: opening,
+		ClosingBalance: opening + totals.Gross - totals.Fees - totals.Refunds - totals.Payouts,
+		S3Key: statementKey(businessId, month),
+		Created: time.Now(),
+	}
+
+	// 2. render and store
+	file := renderStatement(statementData{
+		BusinessName: business.Name,
+		Statement: st,
+		Products: products,
+		Payouts: payouts,
+		Loc: loc,
+	})
+
+	err = store.Put(st.S3Key, file, "application/pdf")
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	err = s.UpsertStatement(&st)
+	if err != nil {
+		return nil, nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return &st, file, nil
+}
+
+func GetStatements(sqlDB *sql.DB, businessId int) ([]bus_models.Statement, *models.RequestError) {
+	s := busdb.StatementDB{DB: sqlDB}
+
+	statements, err := s.GetBusinessStatements(businessId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return statements, nil
+}
+
+// GetStatementURL presigns the month's statement, generating it first if
+// it's never been made
+func GetStatementURL(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	month time.Time,
+) (string, *models.RequestError) {
+	s := busdb.StatementDB{DB: sqlDB}
+
+	st, err := s.GetStatement(businessId, month)
+	if err == sql.ErrNoRows {
+		var reqErr *models.RequestError
+		st, _, reqErr = GenerateStatement(sqlDB, store, businessId, month)
+		if reqErr != nil {
+			return "", reqErr
+		}
+	} else if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	url, err := store.PresignGet(st.S3Key, statementUrlExpiry)
+	if err != nil {
+		return "", &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	return url, nil
+}
+
+// SendStatements emails every business last month's statement once it's over
+// where they are, runs daily so a failed send goes out the next day
+func SendStatements(sqlDB *sql.DB, store cloud.ObjectStore) {
+	s := busdb.StatementDB{DB: sqlDB}
+	b := busdb.BusinessDB{DB: sqlDB}
+
+	// two months back covers every time zone's last month
+	since := time.Now().AddDate(0, -2, 0)
+	businessIds, err := s.GetStatementBusinessIDs(since)
+	if err != nil {
+		log.Println("Failed to get statement businesses:", err)
+		return
+	}
+
+	for _, businessId := range businessIds {
+		loc, err := analytics.BusinessLocation(sqlDB, businessId)
+		if err != nil {
+			log.Printf("Failed to get location for business %d: %v\n", businessId, err)
+			continue
+		}
+
+		month := lastCompleteMonth(loc)
+		existing, err := s.GetStatement(businessId, month)
+		if err == nil && existing.EmailedAt.Valid {
+			continue
+		}
+
+		st, file, reqErr := GenerateStatement(sqlDB, store, businessId, month)
+		if reqErr != nil {
+			log.Printf("Failed to generate statement for business %d: %v\n", businessId, reqErr.Err)
+			continue
+		}
+
+		business, err := b.GetBusinessByID(businessId)
+		if err != nil {
+			log.Printf("Failed to get business %d: %v\n", businessId, err)
+			continue
+		}
+
+		err = media.SendStatementEmail(
+			business.Email, 
+			business.Name, 
+			month, 
+			st.Gross - st.Fees - st.Refunds, 
+			st.ClosingBalance,
+			media.Attachment{
+				Filename: fmt.Sprintf("usual_statement_%s.pdf", month.Format("2006-01")),
+				ContentType: "application/pdf",
+				Data: file,
+			},
+		)
+		if err != nil {
+			log.Printf("Failed to email statement to business %d: %v\n", businessId, err)
+			continue
+		}
+
+		err = s.SetStatementEmailed(st.ID)
+		if err != nil {
+			log.Printf("Failed to set statement %d emailed: %v\n", st.ID, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package statement
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func Routes(statementRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	statementRouter.GET("", getStatementsHandler(sqlDB))
+	statementRouter.GET("/:month/url", getStatementURLHandler(sqlDB, store))
+}
+
+func getStatementsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		statements, reqErr := GetStatements(sqlDB, *businessId)
+		if reqErr != nil {
+			log.Println("Failed to get statements:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(http.StatusOK, statements)
+	}
+}
+
+func getStatementURLHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		month, reqErr := ParseMonth(c.Param("month"))
+		if reqErr != nil {
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		url, reqErr := GetStatementURL(sqlDB, store, *businessId, month)
+		if reqErr != nil {
+			log.Println("Failed to get statement url:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]string{
+			"url": url,
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+)
+
+// Routes serves presigned urls for stores that don't have a provider of their own
+// (local disk and memory). With s3 there's nothing here
+func Routes(storageRouter *gin.RouterGroup, store cloud.ObjectStore) {
+	signed, ok := store.(cloud.SignedStore)
+	if !ok {
+		return
+	}
+
+	storageRouter.GET("", downloadHandler(signed))
+	storageRouter.PUT("", uploadHandler(signed))
+}
+
+func verify(c *gin.Context, store cloud.SignedStore) (string, bool) {
+	err := store.VerifyURL(c.Request.Method, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, err.Error())
+		return "", false
+	}
+	return c.Query("key"), true
+}
+
+func downloadHandler(store cloud.SignedStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		key, ok := verify(c, store)
+		if !ok {
+			return
+		}
+
+		data, err := store.Get(key)
+		if err == cloud.ErrObjectNotFound {
+			c.JSON(http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
+			log.Printf("Failed to get object %s: %v\n", key, err)
+			c.JSON(http.StatusBadGateway, err)
+			return
+		}
+
+		c.Data(http.StatusOK, store.ContentType(key), data)
+	}
+}
+
+func uploadHandler(store cloud.SignedStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		key, ok := verify(c, store)
+		if !ok {
+			return
+		}
+
+		// hold uploads to what the url was signed for, the same way s3 would
+		contentType := c.Query("content_type")
+		if contentType != "" && c.ContentType() != contentType {
+			c.JSON(http.StatusBadRequest, "content type must be " + contentType)
+			return
+		}
+
+		var size int64 = -1
+		if c.Query("size") != "" {
+			size, _ = strconv.ParseInt(c.Query("size"), 10, 64)
+		}
+
+		body := c.Request.Body
+		if size >= 0 {
+			body = http.MaxBytesReader(c.Writer, body, size)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err.Error())
+			return
+		}
+		if size >= 0 && int64(len(data)) != size {
+			c.JSON(http.StatusBadRequest, "upload must be " + c.Query("size") + " bytes")
+			return
+		}
+
+		err = store.Put(key, data, c.ContentType())
+		if err != nil {
+			log.Printf("Failed to put object %s: %v\n", key, err)
+			c.JSON(http.StatusBadGateway, err)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
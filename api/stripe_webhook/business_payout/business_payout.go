@@ -3,7 +3,10 @@ package sw_payout
 import (
 	"database/sql"
 	"errors"
+	"log"
+	"time"
 
+	"github.com/johnyeocx/usual/server/api/notification"
 	busdb "github.com/johnyeocx/usual/server/db/bus_db"
 	"github.com/johnyeocx/usual/server/db/models"
 	"github.com/johnyeocx/usual/server/errors/bus_errors"
@@ -37,6 +40,11 @@ func InsertPayout(sqlDB *sql.DB, sp stripe.Payout) (*models.RequestError) {
 		return bus_errors.InsertPayoutFailedErr(err)
 	}
 
+	n := notification.PayoutPaid(bus.ID, sp.ID, int(sp.Amount), time.Unix(sp.ArrivalDate, 0))
+	if err := notification.NotifyBusiness(sqlDB, n); err != nil {
+		log.Printf("Failed to notify business %d of payout: %v\n", bus.ID, err)
+	}
+
 	return nil
 }
 
@@ -6,19 +6,22 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 
 	firebase "firebase.google.com/go"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/c/gift"
+	"github.com/johnyeocx/usual/server/api/dunning"
 	sw_payout "github.com/johnyeocx/usual/server/api/stripe_webhook/business_payout"
 	constants "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/external/cloud"
 	"github.com/stripe/stripe-go/v74"
 )
 
 func Routes(
 	stripeWRouter *gin.RouterGroup, 
 	sqlDB *sql.DB, 
-	s3Sess *session.Session, 
+	store cloud.ObjectStore, 
 	firebaseApp *firebase.App,
 ) {
 	stripeWRouter.POST("", stripeWebhookHandler(sqlDB, firebaseApp))
@@ -66,15 +69,72 @@ func stripeWebhookHandler(sqlDB *sql.DB, firebaseApp *firebase.App) gin.HandlerF
 				return
 			}
 		case "invoice.payment_failed": 
-			_, err := InsertInvoice(sqlDB, firebaseApp, event.Data.Object, constants.PMIPaymentFailed)
-				
-
+			invoice, err := InsertInvoice(sqlDB, firebaseApp, event.Data.Object, constants.PMIPaymentFailed)
 			if err != nil {
 				log.Println("Failed to insert invoice payment failed:", err)
 				c.JSON(http.StatusBadGateway, err)
 				return
 			}
+
+			err = dunning.StartDunning(sqlDB, firebaseApp, invoice)
+			if err != nil {
+				log.Println("Failed to start dunning:", err)
+				c.JSON(http.StatusBadGateway, err)
+				return
+			}
+			c.JSON(200, nil)
 		
+		case "invoice.upcoming":
+			err := NotifyRenewalUpcoming(sqlDB, firebaseApp, event.Data.Object)
+			if err != nil {
+				log.Println("Failed to notify upcoming renewal:", err)
+				c.JSON(http.StatusBadGateway, err)
+				return
+			}
+			c.JSON(200, nil)
+
+		case "payment_intent.succeeded":
+			var paymentIntent stripe.PaymentIntent
+			err := json.Unmarshal(event.Data.Raw, &paymentIntent)
+			if err != nil {
+				log.Println("Error parsing JSON:", err)
+				c.JSON(http.StatusBadRequest, err)
+				return
+			}
+
+			if giftId, ok := paymentIntent.Metadata["gift_id"]; ok {
+				giftIdInt, err := strconv.Atoi(giftId)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, err)
+					return
+				}
+
+				err = gift.CompleteGiftPurchase(sqlDB, giftIdInt)
+				if err != nil {
+					log.Println("Failed to complete gift purchase:", err)
+					c.JSON(http.StatusBadGateway, err)
+					return
+				}
+			}
+			c.JSON(200, nil)
+
+		case "charge.refunded":
+			var charge stripe.Charge
+			err := json.Unmarshal(event.Data.Raw, &charge)
+			if err != nil {
+				log.Println("Error parsing JSON:", err)
+				c.JSON(http.StatusBadRequest, err)
+				return
+			}
+
+			err = RecordRefunds(sqlDB, charge)
+			if err != nil {
+				log.Println("Failed to record refunds:", err)
+				c.JSON(http.StatusBadGateway, err)
+				return
+			}
+			c.JSON(200, nil)
+
 		case "account.updated":
 			var updatedAccount stripe.Account
 			err := json.Unmarshal(event.Data.Raw, &updatedAccount)
@@ -9,20 +9,23 @@ import (
 	"time"
 
 	firebase "firebase.google.com/go"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	"github.com/johnyeocx/usual/server/api/c/cus_email"
 	"github.com/johnyeocx/usual/server/api/c/subscription"
+	"github.com/johnyeocx/usual/server/api/dunning"
+	"github.com/johnyeocx/usual/server/api/notification"
 	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/db"
 	busdb "github.com/johnyeocx/usual/server/db/bus_db"
-	cusdb "github.com/johnyeocx/usual/server/db/cus_db"
 	"github.com/johnyeocx/usual/server/db/models"
-	"github.com/johnyeocx/usual/server/utils/fcm"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+	"github.com/johnyeocx/usual/server/external/my_stripe"
 	"github.com/stripe/stripe-go/v74"
 )
 
 func VoidedInvoice(sqlDB *sql.DB, fbApp *firebase.App, data map[string]interface{}) (error) {
 	invoice := ParseInvoicePaid(data)
 	i := db.InvoiceDB{DB: sqlDB}
-	c := cusdb.CustomerDB{DB: sqlDB}
 
 	if !invoice.SubStripeID.Valid {
 		return errors.New("no subscription stripe id")
@@ -38,28 +41,28 @@ func VoidedInvoice(sqlDB *sql.DB, fbApp *firebase.App, data map[string]interface
 	if err != nil {
 		return err
 	}
+
+	err = dunning.ResolveDunning(sqlDB, invoice.InStripeID, my_enums.DunningLost)
+	if err != nil {
+		return err
+	}
 	
 	if (sub.Cancelled) {
 		return nil
 	}
 	
-	_, reqErr := subscription.CancelSubscription(sqlDB, sub.CustomerID, sub.ID)
+	_, reqErr := subscription.EndSubscription(sqlDB, sub.CustomerID, sub.ID)
 	if reqErr != nil {
 		log.Println("Failed to cancel subscription")
 		return reqErr.Err
 	}
 
 
-	// SEND PUSH NOTIFICATION
-	fcmToken, err := c.GetCusFCMToken(sub.CustomerID)
-		if err == sql.ErrNoRows {
-			// handle no fcm token
-		} else if err != nil {
-			// do something else
-			return  err
-		} else {
-			fcm.SendPaymentVoidedNotification(fbApp, *fcmToken, sub.ID, sub.SubProduct.Product.Name, *sub.BusinessName)
-		}
+	n := notification.SubCancelled(sub.CustomerID, sub.ID, sub.SubProduct.Product.Name, *sub.BusinessName, true)
+	n.Email = func() error { return cus_email.SendCancellation(sqlDB, sub.ID, nil) }
+	if err := notification.Notify(sqlDB, fbApp, n); err != nil {
+		log.Printf("Failed to notify customer %d of cancelled sub: %v\n", sub.CustomerID, err)
+	}
 
 	return nil
 }
@@ -73,38 +76,118 @@ func InsertInvoice(
 	invoice := ParseInvoicePaid(data)
 	invoice.PaymentIntentStatus = paymentStatus
 	i := db.InvoiceDB{DB: sqlDB}
-	c := cusdb.CustomerDB{DB: sqlDB}
 
+	var sub *models.Subscription
 	if (invoice.SubStripeID.Valid) {
-		sub, err := i.GetSubFromStripeID(invoice.SubStripeID.String)
+		var err error
+		sub, err = i.GetSubFromStripeID(invoice.SubStripeID.String)
 		if err != nil {
 			return nil, err
 		}
 		invoice.SubID = sub.ID
 		invoice.CardID = sub.CardID
-		
-
-		// SEND PUSH NOTIFICATION
-		fcmToken, err := c.GetCusFCMToken(sub.CustomerID)
-		if err == sql.ErrNoRows {
-			// handle no fcm token
-		} else if err != nil {
-			// do something else
-			return nil, err
-		} else {
-			if paymentStatus == my_enums.PMIPaymentFailed {
-				fcm.SendPaymentFailedNotification(fbApp, *fcmToken, sub.ID, sub.SubProduct.Product.Name, *sub.BusinessName)
-			} else if paymentStatus == my_enums.PMIPaymentSucceeded {
-				fcm.SendPaymentSucceededNotification(fbApp, *fcmToken, sub.ID, sub.SubProduct.SubPlan.UnitAmount, sub.SubProduct.Product.Name, *sub.BusinessName)
-			}
-		}
 	}
 
 	
 	err := i.InsertInvoice(invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	if paymentStatus == my_enums.PMIPaymentSucceeded {
+		err = dunning.ResolveDunning(sqlDB, invoice.InStripeID, my_enums.DunningRecovered)
+
+		// failed payments are followed up by dunning
+		if sub != nil {
+			go func() {
+				n := notification.PaymentSucceeded(
+					sub.CustomerID, sub.ID, invoice.Total, sub.SubProduct.Product.Name, *sub.BusinessName)
+				n.Email = func() error { return cus_email.SendReceipt(sqlDB, invoice) }
+
+				if err := notification.Notify(sqlDB, fbApp, n); err != nil {
+					log.Printf("Failed to notify payment for invoice %s: %v\n", invoice.InStripeID, err)
+				}
+			}()
+
+			// a new period resets what's left on the pass
+			go apple_pass.PassesChanged(sqlDB, sub.ID)
+		}
+	}
 	return invoice, err
 }
 
+// NotifyRenewalUpcoming warns the customer ahead of a renewal, stripe sends
+// invoice.upcoming as many days before as the account's billing settings say
+func NotifyRenewalUpcoming(sqlDB *sql.DB, fbApp *firebase.App, data map[string]interface{}) (error) {
+	i := db.InvoiceDB{DB: sqlDB}
+
+	subStripeId, ok := data["subscription"].(string)
+	if !ok {
+		return nil
+	}
+
+	sub, err := i.GetSubFromStripeID(subStripeId)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if sub.Cancelled {
+		return nil
+	}
+
+	amount := int(data["amount_due"].(float64))
+	renews := time.Now()
+	if nextAttempt, ok := data["next_payment_attempt"].(float64); ok {
+		renews = time.Unix(int64(nextAttempt), 0)
+	}
+
+	return notification.Notify(sqlDB, fbApp, notification.RenewalUpcoming(
+		sub.CustomerID, sub.ID, amount, sub.SubProduct.Product.Name, *sub.BusinessName, renews,
+	))
+}
+
+// RecordRefunds attributes a charge's refunds to the business that was paid
+// so they show up on statements
+func RecordRefunds(sqlDB *sql.DB, charge stripe.Charge) (error) {
+	if charge.PaymentIntent == nil {
+		return nil
+	}
+
+	s := busdb.StatementDB{DB: sqlDB}
+	businessId, err := s.GetPaymentBusinessID(charge.PaymentIntent.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	refunds, err := my_stripe.GetChargeRefunds(charge.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range refunds {
+		if r.Status != stripe.RefundStatusSucceeded {
+			continue
+		}
+
+		err = s.InsertRefund(bus_models.Refund{
+			StripeRefundID: r.ID,
+			BusinessID: businessId,
+			Amount: int(r.Amount),
+			Currency: strings.ToUpper(string(r.Currency)),
+			Created: time.Unix(r.Created, 0),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func ParseInvoicePaid(data map[string]interface{})(*models.Invoice) {
 
 	var subStripeId models.JsonNullString
@@ -128,7 +211,7 @@ func ParseInvoicePaid(data map[string]interface{})(*models.Invoice) {
 	if data["application_fee_amount"] == nil {
 		appFeeAmt.Valid = false;
 	} else {
-		appFeeAmt.Int64 = data["subscription"].(int64)
+		appFeeAmt.Int64 = int64(data["application_fee_amount"].(float64))
 		appFeeAmt.Valid = true
 	}
 
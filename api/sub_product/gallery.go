@@ -0,0 +1,241 @@
+package sub_product
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/johnyeocx/usual/server/api/business"
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/external/cloud"
+)
+
+var errImageNotFound = errors.New("image not in product gallery")
+
+func checkOwnsProduct(sqlDB *sql.DB, businessId int, productId int) (*models.RequestError) {
+	b := db.BusinessDB{DB: sqlDB}
+
+	_, err := b.BusinessOwnsProduct(businessId, productId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errors.New("product not found"),
+			StatusCode: http.StatusForbidden,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+// GetProductGallery returns the gallery in order with urls in place of image keys
+func GetProductGallery(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	productId int,
+) ([]models.ProductImage, *models.RequestError) {
+	if reqErr := checkOwnsProduct(sqlDB, businessId, productId); reqErr != nil {
+		return nil, reqErr
+	}
+
+	i := db.ImageDB{DB: sqlDB}
+	gallery, err := i.GetProductGallery(productId)
+	if err != nil {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	for j := range gallery {
+		cloud.PresignImages(store, &gallery[j].Images)
+	}
+	return gallery, nil
+}
+
+// StartGalleryUpload opens an upload session for a new gallery image
+func StartGalleryUpload(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	productId int,
+	contentType string,
+	size int64,
+) (map[string]interface{}, *models.RequestError) {
+	return business.StartImageUpload(
+		sqlDB, store, businessId, models.ImageTargetGallery, &productId, contentType, size,
+	)
+}
+
+// ConfirmGalleryUpload adds the uploaded image to the end of the gallery
+func ConfirmGalleryUpload(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	productId int,
+	uploadId string,
+	altText string,
+) ([]models.ProductImage, *models.RequestError) {
+	i := db.ImageDB{DB: sqlDB}
+
+	// 1. check the upload is for this product's gallery
+	upload, err := i.GetImageUpload(uploadId, businessId)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	if err == sql.ErrNoRows || upload.Target != models.ImageTargetGallery || *upload.ProductID != productId {
+		return nil, &models.RequestError{
+			Err: errors.New("upload not found"),
+			StatusCode: http.StatusNotFound,
+		}
+	}
+
+	// 2. validate, resize and add it
+	if _, reqErr := business.ConfirmImageUpload(sqlDB, store, businessId, uploadId, altText); reqErr != nil {
+		return nil, reqErr
+	}
+
+	return GetProductGallery(sqlDB, store, businessId, productId)
+}
+
+func UpdateGalleryImageAlt(
+	sqlDB *sql.DB,
+	businessId int,
+	productId int,
+	imageId int,
+	altText string,
+) (*models.RequestError) {
+	if reqErr := checkOwnsProduct(sqlDB, businessId, productId); reqErr != nil {
+		return reqErr
+	}
+
+	i := db.ImageDB{DB: sqlDB}
+	err := i.SetProductImageAlt(productId, imageId, altText)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errImageNotFound,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+func SetGalleryCover(
+	sqlDB *sql.DB,
+	businessId int,
+	productId int,
+	imageId int,
+) (*models.RequestError) {
+	if reqErr := checkOwnsProduct(sqlDB, businessId, productId); reqErr != nil {
+		return reqErr
+	}
+
+	i := db.ImageDB{DB: sqlDB}
+	err := i.SetProductCover(productId, imageId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errImageNotFound,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+// ReorderGallery takes every image id in the gallery in its new order
+func ReorderGallery(
+	sqlDB *sql.DB,
+	businessId int,
+	productId int,
+	imageIds []int,
+) (*models.RequestError) {
+	if reqErr := checkOwnsProduct(sqlDB, businessId, productId); reqErr != nil {
+		return reqErr
+	}
+
+	i := db.ImageDB{DB: sqlDB}
+	gallery, err := i.GetProductGallery(productId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 1. the ids must be the gallery exactly, each once
+	inGallery := map[int]bool{}
+	for _, img := range gallery {
+		inGallery[img.ImageID] = true
+	}
+
+	seen := map[int]bool{}
+	for _, id := range imageIds {
+		if !inGallery[id] || seen[id] {
+			return &models.RequestError{
+				Err: errors.New("image ids must list every gallery image once"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		seen[id] = true
+	}
+	if len(seen) != len(gallery) {
+		return &models.RequestError{
+			Err: errors.New("image ids must list every gallery image once"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	if err := i.SetProductGalleryOrder(productId, imageIds); err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+func DeleteGalleryImage(
+	sqlDB *sql.DB,
+	store cloud.ObjectStore,
+	businessId int,
+	productId int,
+	imageId int,
+) (*models.RequestError) {
+	if reqErr := checkOwnsProduct(sqlDB, businessId, productId); reqErr != nil {
+		return reqErr
+	}
+
+	i := db.ImageDB{DB: sqlDB}
+	img, err := i.DeleteProductImage(productId, imageId)
+	if err == sql.ErrNoRows {
+		return &models.RequestError{
+			Err: errImageNotFound,
+			StatusCode: http.StatusNotFound,
+		}
+	} else if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	business.DeleteImageVariants(store, &img.Images)
+	return nil
+}
@@ -0,0 +1,224 @@
+package sub_product
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/utils/middleware"
+)
+
+func galleryRoutes(subProductRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	subProductRouter.GET("/:productId/gallery", getGalleryHandler(sqlDB, store))
+	subProductRouter.POST("/:productId/gallery/upload", startGalleryUploadHandler(sqlDB, store))
+	subProductRouter.POST("/:productId/gallery/:uploadId/confirm", confirmGalleryUploadHandler(sqlDB, store))
+
+	subProductRouter.PATCH("/:productId/gallery/order", reorderGalleryHandler(sqlDB))
+	subProductRouter.PATCH("/:productId/gallery/:imageId/alt_text", updateGalleryAltHandler(sqlDB))
+	subProductRouter.PATCH("/:productId/gallery/:imageId/cover", setGalleryCoverHandler(sqlDB))
+
+	subProductRouter.DELETE("/:productId/gallery/:imageId", deleteGalleryImageHandler(sqlDB, store))
+}
+
+// galleryParams authenticates the business and reads the product id, and the image
+// id when the route has one
+func galleryParams(c *gin.Context, sqlDB *sql.DB) (int, int, int, bool) {
+	businessId, err := middleware.AuthenticateBId(c, sqlDB)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, err)
+		return 0, 0, 0, false
+	}
+
+	productId, err := strconv.Atoi(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err)
+		return 0, 0, 0, false
+	}
+
+	imageId := 0
+	if c.Param("imageId") != "" {
+		imageId, err = strconv.Atoi(c.Param("imageId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, err)
+			return 0, 0, 0, false
+		}
+	}
+
+	return *businessId, productId, imageId, true
+}
+
+func getGalleryHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, _, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		gallery, reqErr := GetProductGallery(sqlDB, store, businessId, productId)
+		if reqErr != nil {
+			log.Println("Failed to get product gallery:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"gallery": gallery,
+		})
+	}
+}
+
+func startGalleryUploadHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, _, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		reqBody := struct {
+			ContentType 	string 	`json:"content_type"`
+			Size 			int64 	`json:"size"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		res, reqErr := StartGalleryUpload(sqlDB, store, businessId, productId, reqBody.ContentType, reqBody.Size)
+		if reqErr != nil {
+			log.Println("Failed to start gallery upload:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+func confirmGalleryUploadHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, _, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		reqBody := struct {
+			AltText 	string 	`json:"alt_text"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		gallery, reqErr := ConfirmGalleryUpload(
+			sqlDB, store, businessId, productId, c.Param("uploadId"), reqBody.AltText,
+		)
+		if reqErr != nil {
+			log.Println("Failed to confirm gallery upload:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, map[string]interface{}{
+			"gallery": gallery,
+		})
+	}
+}
+
+func reorderGalleryHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, _, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		reqBody := struct {
+			ImageIDs 	[]int 	`json:"image_ids"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := ReorderGallery(sqlDB, businessId, productId, reqBody.ImageIDs)
+		if reqErr != nil {
+			log.Println("Failed to reorder product gallery:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func updateGalleryAltHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, imageId, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		reqBody := struct {
+			AltText 	string 	`json:"alt_text"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(http.StatusBadRequest, err)
+			return
+		}
+
+		reqErr := UpdateGalleryImageAlt(sqlDB, businessId, productId, imageId, reqBody.AltText)
+		if reqErr != nil {
+			log.Println("Failed to update gallery alt text:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func setGalleryCoverHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, imageId, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		reqErr := SetGalleryCover(sqlDB, businessId, productId, imageId)
+		if reqErr != nil {
+			log.Println("Failed to set gallery cover:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
+
+func deleteGalleryImageHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
+	return func (c *gin.Context) {
+		businessId, productId, imageId, ok := galleryParams(c, sqlDB)
+		if !ok {
+			return
+		}
+
+		reqErr := DeleteGalleryImage(sqlDB, store, businessId, productId, imageId)
+		if reqErr != nil {
+			log.Println("Failed to delete gallery image:", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err.Error())
+			return
+		}
+
+		c.JSON(http.StatusOK, nil)
+	}
+}
@@ -2,16 +2,23 @@ package sub_product
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/johnyeocx/usual/server/api/business"
+	"github.com/johnyeocx/usual/server/api/c/waitlist"
+	"github.com/johnyeocx/usual/server/api/search"
 	"github.com/johnyeocx/usual/server/db"
 	"github.com/johnyeocx/usual/server/db/models"
 	"github.com/johnyeocx/usual/server/external/cloud"
 	"github.com/johnyeocx/usual/server/external/my_stripe"
 )
 
+var (
+	maxPlanSeats = 10
+)
 
 func createSubProduct (
 	sqlDB *sql.DB,
@@ -69,6 +76,8 @@ func createSubProduct (
 	if err != nil {
 		return nil, nil, err
 	}
+
+	search.ProductChanged(sqlDB, insertedProduct.ProductID)
 	
 
 	return newCatId, &models.SubscriptionProduct{
@@ -181,6 +190,8 @@ func UpdateProductName(
 		}
 	}
 
+	search.ProductChanged(sqlDB, productId)
+
 	return nil
 }
 
@@ -211,6 +222,7 @@ func UpdateProductCategory(
 	}
 
 	b.DeleteCategoryIfEmpty(*prevCatId)
+	search.ProductChanged(sqlDB, productId)
 
 	if catId != nil {
 		return catId, nil
@@ -278,9 +290,81 @@ func AddProductUsage(
 
 
 
+func UpdatePlanSeats(
+	sqlDB *sql.DB,
+	businessId int,
+	planId int,
+	seats int,
+	sharedUsage bool,
+) (*models.RequestError) {
+	b := db.BusinessDB{DB: sqlDB}
+
+	if seats < 1 || seats > maxPlanSeats {
+		return &models.RequestError{
+			Err: fmt.Errorf("seats must be between 1 and %d", maxPlanSeats),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 1. Business owns plan
+	_, err := b.BusinessOwnsPlan(businessId, planId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	err = b.SetPlanSeats(planId, seats, sharedUsage)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+	return nil
+}
+
+func UpdatePlanCapacity(
+	sqlDB *sql.DB,
+	businessId int,
+	planId int,
+	maxSubscribers *int,
+) (*models.RequestError) {
+	b := db.BusinessDB{DB: sqlDB}
+
+	if maxSubscribers != nil && *maxSubscribers < 1 {
+		return &models.RequestError{
+			Err: errors.New("max subscribers must be at least 1"),
+			StatusCode: http.StatusBadRequest,
+		}
+	}
+
+	// 1. Business owns plan
+	_, err := b.BusinessOwnsPlan(businessId, planId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusUnauthorized,
+		}
+	}
+
+	err = b.SetPlanMaxSubscribers(planId, maxSubscribers)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	// 2. a raised cap frees spots for anyone waiting
+	waitlist.InviteFromWaitlist(sqlDB, planId)
+	return nil
+}
+
 func DeleteSubProduct(
 	sqlDB *sql.DB,
-	s3Sess *session.Session,
+	store cloud.ObjectStore,
 	businessId int,
 	productId int,
 ) (*models.RequestError) {
@@ -340,6 +424,23 @@ func DeleteSubProduct(
 		}
 	}
 
+	i := db.ImageDB{DB: sqlDB}
+	images, err := i.GetProductImages(productId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
+	gallery, err := i.GetProductGallery(productId)
+	if err != nil {
+		return &models.RequestError{
+			Err: err,
+			StatusCode: http.StatusBadGateway,
+		}
+	}
+
 	// 5. Delete product from DB
 	err = b.DeleteSubProduct(productId, (*data)["plan_id"].(int))
 	if err != nil {
@@ -349,8 +450,14 @@ func DeleteSubProduct(
 		}
 	}
 
+	search.ProductDeleted(sqlDB, productId, businessId)
+
 	key := "./business/product_image/" + strconv.Itoa(productId)
-	cloud.DeleteImage(s3Sess, key)
+	store.Delete(key)
+	business.DeleteImageVariants(store, images)
+	for _, img := range gallery {
+		business.DeleteImageVariants(store, &img.Images)
+	}
 
 	// 6. Delete category
 	catId := (*data)["category_id"].(int)
@@ -6,8 +6,8 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
 	"github.com/johnyeocx/usual/server/db/models"
 	"github.com/johnyeocx/usual/server/external/cloud"
@@ -15,21 +15,25 @@ import (
 )
 
 
-func Routes(subProductRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
-	subProductRouter.POST("/create", createSubProductHandler(sqlDB, s3Sess))
+func Routes(subProductRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore) {
+	subProductRouter.POST("/create", createSubProductHandler(sqlDB, store))
 	subProductRouter.POST("/product_stats", getSubProductStatsHandler(sqlDB))
 	subProductRouter.POST("/usage", addProductUsageHandler(sqlDB))
 
 	subProductRouter.PATCH("/name", updateProductNameHandler(sqlDB))
 	subProductRouter.PATCH("/category", updateProductCategoryHandler(sqlDB))
 	subProductRouter.PATCH("/usage", updateProductUsageHandler(sqlDB))
+	subProductRouter.PATCH("/seats", updatePlanSeatsHandler(sqlDB))
+	subProductRouter.PATCH("/capacity", updatePlanCapacityHandler(sqlDB))
 
 
-	subProductRouter.DELETE("/:productId", deleteSubProductHandler(sqlDB, s3Sess))
+	subProductRouter.DELETE("/:productId", deleteSubProductHandler(sqlDB, store))
+
+	galleryRoutes(subProductRouter, sqlDB, store)
 }
 
 
-func createSubProductHandler(sqlDB *sql.DB, s3Sess *session.Session)  gin.HandlerFunc {
+func createSubProductHandler(sqlDB *sql.DB, store cloud.ObjectStore)  gin.HandlerFunc {
 	return func (c *gin.Context) {
 		businessId, err := middleware.AuthenticateBId(c, sqlDB)
 		if err != nil {
@@ -69,7 +73,7 @@ func createSubProductHandler(sqlDB *sql.DB, s3Sess *session.Session)  gin.Handle
 		}
 
 		key := "./business/product_image/" + strconv.Itoa(subProduct.Product.ProductID)
-		url, err := cloud.GetImageUploadUrl(s3Sess, key)
+		url, err := store.PresignPut(key, time.Hour, "", 0)
 		if err != nil {
 			log.Printf("Failed to decode req body for register business details: %v\n", err)
 			c.JSON(http.StatusBadGateway, err)
@@ -255,7 +259,68 @@ func addProductUsageHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func deleteSubProductHandler(sqlDB *sql.DB, s3Sess *session.Session) gin.HandlerFunc {
+func updatePlanSeatsHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func  (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			PlanID			int 	`json:"plan_id"`
+			Seats 			int 	`json:"seats"`
+			SharedUsage		bool 	`json:"shared_usage"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(400, err)
+			return
+		}
+
+		reqErr := UpdatePlanSeats(sqlDB, *businessId, reqBody.PlanID, reqBody.Seats, reqBody.SharedUsage)
+		if reqErr != nil {
+			log.Printf("Failed to update plan seats: %v\n", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(200, nil)
+	}
+}
+
+func updatePlanCapacityHandler(sqlDB *sql.DB) gin.HandlerFunc {
+	return func  (c *gin.Context) {
+		businessId, err := middleware.AuthenticateBId(c, sqlDB)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, err)
+			return
+		}
+
+		reqBody := struct {
+			PlanID			int 	`json:"plan_id"`
+			MaxSubscribers 	*int 	`json:"max_subscribers"`
+		}{}
+
+		if err := c.BindJSON(&reqBody); err != nil {
+			log.Printf("Failed to decode req body: %v\n", err)
+			c.JSON(400, err)
+			return
+		}
+
+		reqErr := UpdatePlanCapacity(sqlDB, *businessId, reqBody.PlanID, reqBody.MaxSubscribers)
+		if reqErr != nil {
+			log.Printf("Failed to update plan capacity: %v\n", reqErr.Err)
+			c.JSON(reqErr.StatusCode, reqErr.Err)
+			return
+		}
+
+		c.JSON(200, nil)
+	}
+}
+
+func deleteSubProductHandler(sqlDB *sql.DB, store cloud.ObjectStore) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		businessId, err := middleware.AuthenticateBId(c, sqlDB)
 		if err != nil {
@@ -270,7 +335,7 @@ func deleteSubProductHandler(sqlDB *sql.DB, s3Sess *session.Session) gin.Handler
 			return
 		}
 
-		reqErr := DeleteSubProduct(sqlDB, s3Sess, *businessId, productIdInt)
+		reqErr := DeleteSubProduct(sqlDB, store, *businessId, productIdInt)
 		if reqErr != nil {
 			log.Println(reqErr.Err)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
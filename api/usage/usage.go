@@ -2,9 +2,13 @@ package usage
 
 import (
 	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
+	firebase "firebase.google.com/go"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
+	"github.com/johnyeocx/usual/server/api/notification"
 	"github.com/johnyeocx/usual/server/db"
 	"github.com/johnyeocx/usual/server/db/models"
 )
@@ -18,6 +22,7 @@ import (
 
 func InsertCusUsage(
 	sqlDB *sql.DB,
+	fbApp *firebase.App,
 	cusUuid string,
 	businessId int,
 	subUsageId int,
@@ -26,7 +31,7 @@ func InsertCusUsage(
 	u := db.UsageDB{DB: sqlDB}
 
 	// check that business owns sub usage id
-	subUsage, usageCount,  err := u.InsertCusUsageValid(cusUuid, subUsageId, businessId)
+	subUsage, usageCount, subId, err := u.InsertCusUsageValid(cusUuid, subUsageId, businessId)
 	if err == sql.ErrNoRows {
 		return nil, &models.RequestError{
 			Err: err,
@@ -51,6 +56,7 @@ func InsertCusUsage(
 		CusUUID: cusUuid,
 		Created: time.Now(),
 		SubUsageID: subUsageId,
+		SubID: *subId,
 	})
 
 	if err != nil {
@@ -59,6 +65,9 @@ func InsertCusUsage(
 			StatusCode: http.StatusBadGateway,
 		}
 	}
+
+	go notifyRedeemed(sqlDB, fbApp, cusUuid, *subId, subUsageId)
+	go apple_pass.PassesChanged(sqlDB, *subId)
 	
 	// return true
 	return map[string]interface{} {
@@ -69,6 +78,7 @@ func InsertCusUsage(
 
 func ScanCusQR(
 	sqlDB *sql.DB,
+	fbApp *firebase.App,
 	cusUuid string,
 	businessId int,
 ) (map[string]interface{}, *models.RequestError) {
@@ -90,6 +100,7 @@ func ScanCusQR(
 				CusUUID: cusUuid,
 				Created: time.Now(),
 				SubUsageID: info.SubUsage.ID,
+				SubID: info.SubID,
 			})
 
 			if err != nil {
@@ -98,6 +109,9 @@ func ScanCusQR(
 					StatusCode: http.StatusBadGateway,
 				}
 			}
+
+			go notifyRedeemed(sqlDB, fbApp, cusUuid, info.SubID, info.SubUsage.ID)
+			go apple_pass.PassesChanged(sqlDB, info.SubID)
 			
 			// return true
 			return map[string]interface{} {
@@ -122,4 +136,19 @@ func ScanCusQR(
 		"cus_usage": nil,
 		"usage_infos": usageInfos,
 	}, nil
-}
\ No newline at end of file
+}
+
+func notifyRedeemed(sqlDB *sql.DB, fbApp *firebase.App, cusUuid string, subId int, subUsageId int) {
+	u := db.UsageDB{DB: sqlDB}
+
+	cusId, title, businessName, err := u.GetRedemptionDetails(cusUuid, subUsageId)
+	if err != nil {
+		log.Printf("Failed to get redemption details for usage %d: %v\n", subUsageId, err)
+		return
+	}
+
+	err = notification.Notify(sqlDB, fbApp, notification.UsageRedeemed(cusId, subId, title, businessName))
+	if err != nil {
+		log.Printf("Failed to notify customer %d of redemption: %v\n", cusId, err)
+	}
+}
@@ -5,18 +5,19 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/aws/aws-sdk-go/aws/session"
+	firebase "firebase.google.com/go"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/external/cloud"
 	"github.com/johnyeocx/usual/server/utils/middleware"
 )
 
 
-func Routes(usageRouter *gin.RouterGroup, sqlDB *sql.DB, s3Sess *session.Session) {
-	usageRouter.POST("/scan", scanCusQRHandler(sqlDB))
-	usageRouter.POST("/insert_usage", insertCusUsageHandler(sqlDB))
+func Routes(usageRouter *gin.RouterGroup, sqlDB *sql.DB, store cloud.ObjectStore, fbApp *firebase.App) {
+	usageRouter.POST("/scan", scanCusQRHandler(sqlDB, fbApp))
+	usageRouter.POST("/insert_usage", insertCusUsageHandler(sqlDB, fbApp))
 }
 
-func scanCusQRHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func scanCusQRHandler(sqlDB *sql.DB, fbApp *firebase.App) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		businessId, err := middleware.AuthenticateBId(c, sqlDB)
 
@@ -34,7 +35,7 @@ func scanCusQRHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		res, reqErr := ScanCusQR(sqlDB, reqBody.CusUUID, *businessId)
+		res, reqErr := ScanCusQR(sqlDB, fbApp, reqBody.CusUUID, *businessId)
 		if reqErr != nil {
 			log.Println("Failed to scan cus QR: ", reqErr)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
@@ -46,7 +47,7 @@ func scanCusQRHandler(sqlDB *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func insertCusUsageHandler(sqlDB *sql.DB) gin.HandlerFunc {
+func insertCusUsageHandler(sqlDB *sql.DB, fbApp *firebase.App) gin.HandlerFunc {
 	return func (c *gin.Context) {
 		businessId, err := middleware.AuthenticateBId(c, sqlDB)
 
@@ -65,7 +66,7 @@ func insertCusUsageHandler(sqlDB *sql.DB) gin.HandlerFunc {
 			return
 		}
 		
-		res, reqErr := InsertCusUsage(sqlDB,  reqBody.CusUUID, *businessId, reqBody.SubUsageID)
+		res, reqErr := InsertCusUsage(sqlDB, fbApp, reqBody.CusUUID, *businessId, reqBody.SubUsageID)
 		if reqErr != nil {
 			log.Println("Failed to insert cus usage: ", reqErr)
 			c.JSON(reqErr.StatusCode, reqErr.Err)
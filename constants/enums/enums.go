@@ -12,6 +12,9 @@ const (
 
 
 	PNSubCancelled	             	PushNotificationType = "subscription_cancelled"
+	PNPaymentReminder				PushNotificationType = "payment_reminder"
+	PNUsageRedeemed				PushNotificationType = "usage_redeemed"
+	PNRenewalUpcoming			PushNotificationType = "renewal_upcoming"
 )
 
 
@@ -39,4 +42,121 @@ const (
 	Google    	CusSignInProvider = "google.com"
 	Apple		CusSignInProvider = "apple.com"
 	Custom		CusSignInProvider = "custom"
-)
\ No newline at end of file
+)
+type CancellationReason string
+const (
+	CRTooExpensive		CancellationReason = "too_expensive"
+	CRNotUsingEnough	CancellationReason = "not_using_enough"
+	CRMovedAway			CancellationReason = "moved_away"
+	CRQualityIssues		CancellationReason = "quality_issues"
+	CRSwitchedBusiness	CancellationReason = "switched_business"
+	CROther				CancellationReason = "other"
+)
+
+func ValidCancellationReason(reason CancellationReason) (bool) {
+	switch reason {
+	case CRTooExpensive, CRNotUsingEnough, CRMovedAway, CRQualityIssues, CRSwitchedBusiness, CROther:
+		return true
+	}
+	return false
+}
+
+type GiftStatus string
+const (
+	GiftPendingPayment	GiftStatus = "pending_payment"
+	GiftPurchased		GiftStatus = "purchased"
+	GiftClaimed			GiftStatus = "claimed"
+	GiftRefunded		GiftStatus = "refunded"
+)
+
+type SubMemberStatus string
+const (
+	SubMemberInvited	SubMemberStatus = "invited"
+	SubMemberActive		SubMemberStatus = "active"
+	SubMemberRemoved	SubMemberStatus = "removed"
+)
+
+type WaitlistStatus string
+const (
+	WaitlistWaiting		WaitlistStatus = "waiting"
+	WaitlistInvited		WaitlistStatus = "invited"
+	WaitlistClaimed		WaitlistStatus = "claimed"
+	WaitlistExpired		WaitlistStatus = "expired"
+	WaitlistLeft		WaitlistStatus = "left"
+)
+
+type DunningStatus string
+const (
+	DunningOpen			DunningStatus = "open"
+	DunningRecovered	DunningStatus = "recovered"
+	DunningLost			DunningStatus = "lost"
+)
+
+type EmailStatus string
+const (
+	EmailQueued		EmailStatus = "queued"
+	EmailSending	EmailStatus = "sending"
+	EmailSent		EmailStatus = "sent"
+	EmailFailed		EmailStatus = "failed"
+)
+
+type NotificationEvent string
+const (
+	NEPaymentSucceeded	NotificationEvent = "payment_succeeded"
+	NEPaymentFailed		NotificationEvent = "payment_failed"
+	NESubCancelled		NotificationEvent = "subscription_cancelled"
+	NEUsageRedeemed		NotificationEvent = "usage_redeemed"
+	NERenewalUpcoming	NotificationEvent = "renewal_upcoming"
+
+	// business only
+	NENewSubscriber		NotificationEvent = "new_subscriber"
+	NEPayoutPaid		NotificationEvent = "payout_paid"
+)
+
+func ValidNotificationEvent(event NotificationEvent) (bool) {
+	switch event {
+	case NEPaymentSucceeded, NEPaymentFailed, NESubCancelled, NEUsageRedeemed, NERenewalUpcoming:
+		return true
+	}
+	return false
+}
+
+type NotificationChannel string
+const (
+	NCPush		NotificationChannel = "push"
+	NCEmail		NotificationChannel = "email"
+	NCSMS		NotificationChannel = "sms"
+)
+
+type NotificationStatus string
+const (
+	NotificationSent		NotificationStatus = "sent"
+	NotificationFailed		NotificationStatus = "failed"
+	NotificationHeld		NotificationStatus = "held"
+	NotificationSending		NotificationStatus = "sending"
+	NotificationSkipped		NotificationStatus = "skipped"
+)
+
+// flagged reviews stay public until a moderator hides or restores them
+type ReviewStatus string
+const (
+	ReviewVisible	ReviewStatus = "visible"
+	ReviewFlagged	ReviewStatus = "flagged"
+	ReviewHidden	ReviewStatus = "hidden"
+)
+
+type ReviewFlagReason string
+const (
+	RFSpam			ReviewFlagReason = "spam"
+	RFOffensive		ReviewFlagReason = "offensive"
+	RFOffTopic		ReviewFlagReason = "off_topic"
+	RFOther			ReviewFlagReason = "other"
+)
+
+func ValidReviewFlagReason(reason ReviewFlagReason) (bool) {
+	switch reason {
+	case RFSpam, RFOffensive, RFOffTopic, RFOther:
+		return true
+	}
+	return false
+}
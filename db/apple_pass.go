@@ -0,0 +1,177 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/lib/pq"
+)
+
+type PassDB struct {
+	DB *sql.DB
+}
+
+func (p *PassDB) GetApplePass(serialNumber string) (*models.ApplePass, error) {
+	query := `SELECT serial_number, customer_id, sub_id, auth_token, updated FROM apple_pass
+		WHERE serial_number=$1`
+
+	var pass models.ApplePass
+	err := p.DB.QueryRow(query, serialNumber).Scan(
+		&pass.SerialNumber, &pass.CustomerID, &pass.SubID, &pass.AuthToken, &pass.Updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pass, nil
+}
+
+// UpsertApplePass keeps the existing auth token if the pass was already issued, so
+// devices that registered with it keep working
+func (p *PassDB) UpsertApplePass(pass models.ApplePass) (*models.ApplePass, error) {
+	query := `INSERT into apple_pass (serial_number, customer_id, sub_id, auth_token, updated)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (serial_number) DO UPDATE SET updated=$5
+		RETURNING serial_number, customer_id, sub_id, auth_token, updated`
+
+	var saved models.ApplePass
+	err := p.DB.QueryRow(
+		query, pass.SerialNumber, pass.CustomerID, pass.SubID, pass.AuthToken, pass.Updated,
+	).Scan(
+		&saved.SerialNumber, &saved.CustomerID, &saved.SubID, &saved.AuthToken, &saved.Updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// RegisterPassDevice returns false if the device was already registered for the pass
+func (p *PassDB) RegisterPassDevice(
+	deviceLibraryId string,
+	passTypeId string,
+	serialNumber string,
+	pushToken string,
+) (bool, error) {
+	query := `INSERT into pass_registration 
+		(device_library_id, pass_type_id, serial_number, push_token, created)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (device_library_id, pass_type_id, serial_number) DO UPDATE SET push_token=$4
+		RETURNING (xmax = 0)`
+
+	var created bool
+	err := p.DB.QueryRow(
+		query, deviceLibraryId, passTypeId, serialNumber, pushToken, time.Now(),
+	).Scan(&created)
+	return created, err
+}
+
+// UnregisterPassDevice returns sql.ErrNoRows if the device wasn't registered
+func (p *PassDB) UnregisterPassDevice(
+	deviceLibraryId string,
+	passTypeId string,
+	serialNumber string,
+) (error) {
+	query := `DELETE FROM pass_registration 
+		WHERE device_library_id=$1 AND pass_type_id=$2 AND serial_number=$3`
+
+	res, err := p.DB.Exec(query, deviceLibraryId, passTypeId, serialNumber)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetDevicePassSerials lists the passes on a device changed after since, along with
+// the latest change among them
+func (p *PassDB) GetDevicePassSerials(
+	deviceLibraryId string,
+	passTypeId string,
+	since *time.Time,
+) ([]string, *time.Time, error) {
+	query := `SELECT ap.serial_number, ap.updated FROM pass_registration as pr
+		JOIN apple_pass as ap ON ap.serial_number=pr.serial_number
+		WHERE pr.device_library_id=$1 AND pr.pass_type_id=$2 AND ($3::timestamptz IS NULL OR ap.updated > $3)`
+
+	rows, err := p.DB.Query(query, deviceLibraryId, passTypeId, since)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	serials := []string{}
+	var lastUpdated *time.Time
+	for rows.Next() {
+		var serial string
+		var updated time.Time
+		if err := rows.Scan(&serial, &updated); err != nil {
+			return nil, nil, err
+		}
+
+		serials = append(serials, serial)
+		if lastUpdated == nil || updated.After(*lastUpdated) {
+			lastUpdated = &updated
+		}
+	}
+	return serials, lastUpdated, rows.Err()
+}
+
+func (p *PassDB) GetPassPushTokens(serialNumbers []string) ([]string, error) {
+	query := `SELECT DISTINCT push_token FROM pass_registration WHERE serial_number = ANY($1)`
+
+	rows, err := p.DB.Query(query, pq.Array(serialNumbers))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []string{}
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (p *PassDB) DeletePassPushTokens(pushTokens []string) (error) {
+	_, err := p.DB.Exec(`DELETE FROM pass_registration WHERE push_token = ANY($1)`, pq.Array(pushTokens))
+	return err
+}
+
+// TouchSubPasses marks the membership cards and passes for the subscription of its
+// owner and members as changed, returning their serial numbers. Removed members are
+// included since they lose access
+func (p *PassDB) TouchSubPasses(subId int) ([]string, error) {
+	query := `UPDATE apple_pass SET updated=$2 WHERE (sub_id IS NULL OR sub_id=$1) AND customer_id IN (
+			SELECT customer_id FROM subscription WHERE sub_id=$1
+			UNION
+			SELECT customer_id FROM subscription_member WHERE sub_id=$1 AND customer_id IS NOT NULL
+		) RETURNING serial_number`
+
+	rows, err := p.DB.Query(query, subId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	serials := []string{}
+	for rows.Next() {
+		var serial string
+		if err := rows.Scan(&serial); err != nil {
+			return nil, err
+		}
+		serials = append(serials, serial)
+	}
+	return serials, rows.Err()
+}
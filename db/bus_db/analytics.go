@@ -0,0 +1,285 @@
+package busdb
+
+import (
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+)
+
+// GetAnalyticsSubs returns every subscription started before the given time,
+// gift subscriptions are the ones without a card
+func (b *BusinessDB) GetAnalyticsSubs(businessId int, before time.Time) ([]bus_models.AnalyticsSub, error) {
+	stmt := `SELECT 
+	s.sub_id, s.customer_id, p.product_id, sp.plan_id, s.start_date, s.cancelled, s.cancelled_date, s.expires,
+	sp.unit_amount, sp.recurring_interval, sp.recurring_interval_count, s.card_id IS NULL
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription as s on s.plan_id=sp.plan_id
+	WHERE p.business_id=$1 AND s.start_date < $2
+	ORDER BY s.start_date`
+
+	rows, err := b.DB.Query(stmt, businessId, before)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subs := []bus_models.AnalyticsSub{}
+	for rows.Next() {
+		var sub bus_models.AnalyticsSub
+		var cancelled bool
+		var cancelledDate, expires models.JsonNullTime
+		var unitAmount int
+		var recurring models.TimeFrame
+
+		if err := rows.Scan(
+			&sub.SubID,
+			&sub.CustomerID,
+			&sub.ProductID,
+			&sub.PlanID,
+			&sub.Start,
+			&cancelled,
+			&cancelledDate,
+			&expires,
+			&unitAmount,
+			&recurring.Interval,
+			&recurring.IntervalCount,
+			&sub.Gift,
+		); err != nil {
+			return nil, err
+		}
+
+		if cancelled && expires.Valid {
+			sub.End = &expires.Time
+		} else if cancelled && cancelledDate.Valid {
+			sub.End = &cancelledDate.Time
+		}
+		sub.MonthlyAmount = monthlyAmount(unitAmount, recurring)
+
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+func (b *BusinessDB) GetAnalyticsInvoices(
+	businessId int, 
+	from time.Time, 
+	to time.Time,
+) ([]bus_models.AnalyticsInvoice, error) {
+	stmt := `SELECT 
+	COALESCE(s.customer_id, 0), i.total, i.created
+	FROM product as p
+	JOIN invoice as i on i.stripe_prod_id=p.stripe_product_id
+	LEFT JOIN subscription as s on s.sub_id=i.sub_id
+	WHERE p.business_id=$1 AND i.paid=TRUE AND i.created >= $2 AND i.created < $3
+	ORDER BY i.created`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invoices := []bus_models.AnalyticsInvoice{}
+	for rows.Next() {
+		var invoice bus_models.AnalyticsInvoice
+		if err := rows.Scan(
+			&invoice.CustomerID,
+			&invoice.Total,
+			&invoice.Created,
+		); err != nil {
+			return nil, err
+		}
+
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices, nil
+}
+
+// monthlyAmount normalises a plan's price to what it brings in per month
+func monthlyAmount(unitAmount int, recurring models.TimeFrame) (int) {
+	count := int(recurring.IntervalCount.Int16)
+	if count < 1 {
+		count = 1
+	}
+
+	switch recurring.Interval.String {
+	case "day":
+		return unitAmount * 30 / count
+	case "week":
+		return unitAmount * 52 / 12 / count
+	case "year":
+		return unitAmount / 12 / count
+	default:
+		return unitAmount / count
+	}
+}
+
+func (b *BusinessDB) GetUsageTimes(businessId int, from time.Time, to time.Time) ([]time.Time, error) {
+	stmt := `SELECT cu.created
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription_usage as su on su.plan_id=sp.plan_id
+	JOIN customer_usage as cu on cu.sub_usage_id=su.sub_usage_id
+	WHERE p.business_id=$1 AND cu.created >= $2 AND cu.created < $3`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	times := []time.Time{}
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+
+	return times, nil
+}
+
+// GetSubUsageRedemptions returns every entitlement on the business's plans with
+// how many times it was redeemed in the range
+func (b *BusinessDB) GetSubUsageRedemptions(
+	businessId int, 
+	from time.Time, 
+	to time.Time,
+) ([]bus_models.UsageUtilisation, map[int]int, error) {
+	stmt := `SELECT 
+	su.sub_usage_id, sp.plan_id, p.product_id, p.name, su.title, su.unlimited, su.interval, su.amount,
+	COUNT(cu.usage_id)
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription_usage as su on su.plan_id=sp.plan_id
+	LEFT JOIN customer_usage as cu on cu.sub_usage_id=su.sub_usage_id 
+		AND cu.created >= $2 AND cu.created < $3
+	WHERE p.business_id=$1
+	GROUP BY su.sub_usage_id, sp.plan_id, p.product_id
+	ORDER BY p.product_id, su.sub_usage_id`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	usages := []bus_models.UsageUtilisation{}
+	planIds := map[int]int{}
+	for rows.Next() {
+		var u bus_models.UsageUtilisation
+		var planId int
+		var interval models.JsonNullString
+		var amount models.JsonNullInt16
+		if err := rows.Scan(
+			&u.SubUsageID,
+			&planId,
+			&u.ProductID,
+			&u.ProductName,
+			&u.Title,
+			&u.Unlimited,
+			&interval,
+			&amount,
+			&u.Redeemed,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		u.Interval = interval.String
+		u.Amount = int(amount.Int16)
+		planIds[u.SubUsageID] = planId
+		usages = append(usages, u)
+	}
+
+	return usages, planIds, nil
+}
+
+func (b *BusinessDB) GetTopSubscribers(
+	businessId int, 
+	from time.Time, 
+	to time.Time, 
+	limit int,
+) ([]bus_models.SubscriberActivity, error) {
+	stmt := `SELECT 
+	c.customer_id, c.first_name, c.last_name, c.email, COUNT(cu.usage_id) as redemptions, MAX(cu.created)
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription_usage as su on su.plan_id=sp.plan_id
+	JOIN customer_usage as cu on cu.sub_usage_id=su.sub_usage_id
+	JOIN customer as c on c.uuid=cu.customer_uuid
+	WHERE p.business_id=$1 AND cu.created >= $2 AND cu.created < $3
+	GROUP BY c.customer_id
+	ORDER BY redemptions DESC
+	LIMIT $4`
+
+	return b.getSubscriberActivity(stmt, businessId, from, to, limit)
+}
+
+// GetDormantSubscribers returns live subscribers who haven't redeemed anything since the given time
+func (b *BusinessDB) GetDormantSubscribers(
+	businessId int, 
+	since time.Time, 
+	limit int,
+) ([]bus_models.SubscriberActivity, error) {
+	stmt := `SELECT 
+	c.customer_id, c.first_name, c.last_name, c.email, 0, last_usage.created
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription as s on s.plan_id=sp.plan_id
+	JOIN customer as c on c.customer_id=s.customer_id
+	LEFT JOIN LATERAL (
+		SELECT MAX(cu.created) as created FROM customer_usage as cu
+		JOIN subscription_usage as su on su.sub_usage_id=cu.sub_usage_id
+		JOIN subscription_plan as usp on usp.plan_id=su.plan_id
+		JOIN product as up on up.product_id=usp.product_id
+		WHERE cu.customer_uuid=c.uuid AND up.business_id=$1
+	) as last_usage ON TRUE
+	WHERE p.business_id=$1 AND (s.cancelled=FALSE OR s.expires > now()) 
+	AND (last_usage.created IS NULL OR last_usage.created < $2)
+	GROUP BY c.customer_id, last_usage.created
+	ORDER BY last_usage.created NULLS FIRST
+	LIMIT $3`
+
+	return b.getSubscriberActivity(stmt, businessId, since, limit)
+}
+
+func (b *BusinessDB) getSubscriberActivity(stmt string, args ...interface{}) ([]bus_models.SubscriberActivity, error) {
+	rows, err := b.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	subscribers := []bus_models.SubscriberActivity{}
+	for rows.Next() {
+		var s bus_models.SubscriberActivity
+		var lastUsed models.JsonNullTime
+		if err := rows.Scan(
+			&s.CustomerID,
+			&s.FirstName,
+			&s.LastName,
+			&s.Email,
+			&s.Redemptions,
+			&lastUsed,
+		); err != nil {
+			return nil, err
+		}
+
+		if lastUsed.Valid {
+			s.LastUsed = &lastUsed.Time
+		}
+		subscribers = append(subscribers, s)
+	}
+
+	return subscribers, nil
+}
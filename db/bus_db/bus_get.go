@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/db/models"
 	"github.com/johnyeocx/usual/server/db/models/bus_models"
 )
@@ -72,10 +73,13 @@ func (b *BusinessDB) GetBusinessAndTotal(bId int) (*models.Business, *models.Jso
 func (b *BusinessDB) GetBusinessByID(businessId int) (*models.Business, error) {
 
 	selectStatement := `SELECT 
-	business_id, name, email, country, business_category, business_url, individual_id, stripe_id, description
+	business_id, name, email, country, business_category, business_url, individual_id, stripe_id, description,
+	address_line1, address_line2, postal_code, city, latitude, longitude
 	from business WHERE business_id=$1`
 
 	var business models.Business
+	var line1, line2, postalCode, city *string
+	var latitude, longitude *float64
 	if err := b.DB.QueryRow(selectStatement, businessId).Scan(
 		&business.ID,
 		&business.Name,
@@ -86,10 +90,30 @@ func (b *BusinessDB) GetBusinessByID(businessId int) (*models.Business, error) {
 		&business.IndividualID,
 		&business.StripeID,
 		&business.Description,
+		&line1,
+		&line2,
+		&postalCode,
+		&city,
+		&latitude,
+		&longitude,
 	); err != nil {
 		return nil, err
 	}
 
+	// the address is only ever saved once it has geocoded
+	if line1 != nil && latitude != nil && longitude != nil {
+		business.Address = &models.Address{
+			Line1: *line1,
+			PostalCode: *postalCode,
+			City: *city,
+			Country: &business.Country,
+		}
+		if line2 != nil {
+			business.Address.Line2 = *line2
+		}
+		business.Location = &models.Location{Latitude: *latitude, Longitude: *longitude}
+	}
+
 	return &business, nil
 }
 
@@ -297,168 +321,131 @@ func (b *BusinessDB) GetBusinessBankAccounts(businessId int) ([]models.BankAccou
 }
 
 func (b *BusinessDB) GetBusinessInvoices(businessId int, limit int) ([]models.InvoiceData, error) {
-	stmt := fmt.Sprintf(`SELECT 
-	c.customer_id, c.first_name, c.last_name, 
-	i.invoice_id, i.total, i.invoice_url, i.status, i.attempted, i.app_fee_amt,
-	p.name, p.product_id, i.payment_intent_status, i.created
-	FROM business as b
-	JOIN product as p ON b.business_id=p.business_id
-	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	invoices, _, err := b.ListBusinessInvoices(businessId, nil, &models.PageParams{Limit: limit})
+	return invoices, err
+}
+
+func (b *BusinessDB) GetBusinessPayouts(businessId int, limit int) ([]bus_models.BusinessPayout, error) {
+	payouts, _, err := b.ListBusinessPayouts(businessId, nil, &models.PageParams{Limit: limit})
+	return payouts, err
+}
+
+func (b *BusinessDB) GetBusinessUsages(businessId int, limit int) ([]models.UsageInfo, error) {
+	usageInfos, _, err := b.ListBusinessUsages(businessId, nil, &models.PageParams{Limit: limit})
+	return usageInfos, err
+}
+
+func (b *BusinessDB) GetBusinessTotalReceived(businessId int) (*int, error) {
+	stmt := `
+	SELECT SUM(total) from 
+	business as b
+	JOIN product as p on p.business_id=b.business_id
 	JOIN invoice as i on i.stripe_prod_id=p.stripe_product_id
-	JOIN customer as c on i.stripe_cus_id=c.stripe_id
 	WHERE b.business_id=$1
-	ORDER BY i.created DESC
-	LIMIT %d`, limit)
+	`
+
+	var total sql.NullInt64
+	err := b.DB.QueryRow(stmt, businessId).Scan(&total)
 
-	rows, err := b.DB.Query(stmt, businessId)
 	if err != nil {
 		return nil, err
+	} else if !total.Valid {
+		zero := 0
+		return &zero, nil
+	} else {
+		intTotal := int(total.Int64)
+		return &intTotal, nil
 	}
-	
-
-	defer rows.Close()
-
-	invoices := []models.InvoiceData{}
-	for rows.Next() {
-		var invoice models.InvoiceData
-		
-        if err := rows.Scan(
-			&invoice.CustomerID,
-			&invoice.CusFirstName,
-			&invoice.CusLastName,
-			&invoice.InvoiceID,
-			&invoice.Total,
-			&invoice.InvoiceURL,
-			&invoice.Status,
-			&invoice.Attempted,
-			&invoice.ApplicationFeeAmt,
-			&invoice.ProductName,
-			&invoice.ProductID,
-			&invoice.PaymentIntentStatus,
-			&invoice.Created,
-		); err != nil {
-			fmt.Println(err)
-			continue
-        }
-		
-		invoices = append(invoices, invoice)
-    }
-	
-	return invoices, nil
 }
 
-func (b *BusinessDB) GetBusinessPayouts(businessId int, limit int) ([]bus_models.BusinessPayout, error) {
-	stmt := fmt.Sprintf(`SELECT 
-	bp.payout_id, bp.amount, bp.currency, bp.status, bp.arrival_date, bp.stripe_dest_id, bp.type, bp.external_account_id
+func (b *BusinessDB) GetBusinessChurnReasons(businessId int) ([]models.ChurnReason, error) {
+	stmt := `SELECT 
+	sc.reason, p.product_id, p.name, COUNT(sc.sub_id) as reason_count
 	FROM business as b
-	JOIN business_payout as bp on b.business_id=bp.business_id
-	WHERE b.business_id=$1
-	LIMIT %d`, limit)
+	JOIN product as p on p.business_id=b.business_id
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription as s on s.plan_id=sp.plan_id
+	JOIN subscription_cancellation as sc on sc.sub_id=s.sub_id
+	WHERE b.business_id=$1 AND s.cancelled=TRUE
+	GROUP BY sc.reason, p.product_id
+	ORDER BY reason_count DESC`
 
 	rows, err := b.DB.Query(stmt, businessId)
 	if err != nil {
 		return nil, err
 	}
-	
 
 	defer rows.Close()
 
-	payouts := []bus_models.BusinessPayout{}
+	reasons := []models.ChurnReason{}
 	for rows.Next() {
-		var p bus_models.BusinessPayout
-		
-        if err := rows.Scan(
-			&p.ID,
-			&p.Amount,
-			&p.Currency,
-			&p.Status,
-			&p.ArrivalDate,
-			&p.StripeDestID,
-			&p.Type,
-			&p.ExternalAccountID,
+		var r models.ChurnReason
+		if err := rows.Scan(
+			&r.Reason,
+			&r.ProductID,
+			&r.ProductName,
+			&r.Count,
 		); err != nil {
-			fmt.Println(err)
-			continue
-        }
-		
-		payouts = append(payouts, p)
-    }
-	
-	return payouts, nil
+			return nil, err
+		}
+
+		reasons = append(reasons, r)
+	}
+
+	return reasons, nil
 }
 
-func (b *BusinessDB) GetBusinessUsages(businessId int, limit int) ([]models.UsageInfo, error) {
-	stmt := fmt.Sprintf(`SELECT 
-		c.uuid, c.first_name, c.last_name, 
-		cu.created, 
-		su.title, su.sub_usage_id, su.unlimited, su.interval, su.amount, 
-		p.product_id, p.name 
-		FROM
-		business as b
-		JOIN product as p ON b.business_id=p.business_id
-		JOIN subscription_plan as sp on sp.product_id=p.product_id
-		JOIN subscription_usage as su on su.plan_id=sp.plan_id
-		JOIN customer_usage as cu on cu.sub_usage_id=su.sub_usage_id
-		JOIN customer as c on c.uuid=cu.customer_uuid
-		WHERE b.business_id=$1
-		LIMIT %d  
-	`, limit)
+func (b *BusinessDB) GetBusinessPlanCapacities(businessId int) ([]models.PlanCapacity, error) {
+	stmt := `SELECT 
+	sp.plan_id, p.product_id, p.name, sp.max_subscribers,
+	(SELECT COUNT(*) FROM subscription as s 
+		WHERE s.plan_id=sp.plan_id AND (s.cancelled=FALSE OR s.expires > now())),
+	(SELECT COUNT(*) FROM plan_waitlist as w 
+		WHERE w.plan_id=sp.plan_id AND w.status=$2 AND w.claim_by > now()),
+	(SELECT COUNT(*) FROM plan_waitlist as w WHERE w.plan_id=sp.plan_id AND w.status=$3)
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	WHERE p.business_id=$1 AND sp.max_subscribers IS NOT NULL
+	ORDER BY p.product_id`
 
-	rows, err := b.DB.Query(stmt, businessId)
+	rows, err := b.DB.Query(stmt, businessId, my_enums.WaitlistInvited, my_enums.WaitlistWaiting)
 	if err != nil {
 		return nil, err
 	}
-	
 
 	defer rows.Close()
 
-	usageInfos := []models.UsageInfo{}
+	capacities := []models.PlanCapacity{}
 	for rows.Next() {
-		u := models.UsageInfo{}
-
-        if err := rows.Scan(
-			&u.CusUUID,
-			&u.CusFirstName,
-			&u.CusLastName,
-			&u.Created,
-
-			&u.SubUsage.Title,
-			&u.SubUsage.ID,
-			&u.SubUsage.Unlimited,
-			&u.SubUsage.Interval,
-			&u.SubUsage.Amount,
-			&u.ProductID,
-			&u.ProductName,
+		var c models.PlanCapacity
+		if err := rows.Scan(
+			&c.PlanID,
+			&c.ProductID,
+			&c.ProductName,
+			&c.MaxSubscribers,
+			&c.Subscribers,
+			&c.HeldSpots,
+			&c.Waiting,
 		); err != nil {
-			fmt.Println(err)
-			continue
-        }
-		
-		usageInfos = append(usageInfos, u)
-    }
-	
-	return usageInfos, nil
-}
+			return nil, err
+		}
 
-func (b *BusinessDB) GetBusinessTotalReceived(businessId int) (*int, error) {
-	stmt := `
-	SELECT SUM(total) from 
-	business as b
-	JOIN product as p on p.business_id=b.business_id
-	JOIN invoice as i on i.stripe_prod_id=p.stripe_product_id
-	WHERE b.business_id=$1
-	`
+		capacities = append(capacities, c)
+	}
 
-	var total sql.NullInt64
-	err := b.DB.QueryRow(stmt, businessId).Scan(&total)
+	return capacities, nil
+}
 
+func (b *BusinessDB) GetBusinessTimeZone(businessId int) (*models.JsonNullString, *string, error) {
+	var timeZone models.JsonNullString
+	var country string
+	err := b.DB.QueryRow(`SELECT time_zone, country FROM business WHERE business_id=$1`, businessId).Scan(
+		&timeZone,
+		&country,
+	)
 	if err != nil {
-		return nil, err
-	} else if !total.Valid {
-		zero := 0
-		return &zero, nil
-	} else {
-		intTotal := int(total.Int64)
-		return &intTotal, nil
+		return nil, nil, err
 	}
-}
\ No newline at end of file
+
+	return &timeZone, &country, nil
+}
@@ -0,0 +1,271 @@
+package busdb
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// the List functions page newest first on (created, id), one extra row is read
+// to know whether there's a next page
+
+func (b *BusinessDB) ListBusinessInvoices(
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) ([]models.InvoiceData, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("p.business_id=?", businessId)
+	q.Filter(filter, "i.created", "i.status", "p.product_id")
+	q.After(page.After, "i.created", "i.invoice_id")
+
+	stmt := fmt.Sprintf(`SELECT 
+	i.invoice_id, c.customer_id, c.first_name, c.last_name, 
+	i.total, i.invoice_url, i.status, i.attempted, i.app_fee_amt,
+	p.name, p.product_id, i.payment_intent_status, i.created
+	FROM product as p
+	JOIN invoice as i on i.stripe_prod_id=p.stripe_product_id
+	JOIN customer as c on i.stripe_cus_id=c.stripe_id
+	WHERE %s
+	ORDER BY i.created DESC, i.invoice_id DESC
+	LIMIT %d`, q.String(), page.Limit + 1)
+
+	rows, err := b.DB.Query(stmt, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	invoices := []models.InvoiceData{}
+	var next *models.Cursor
+	var lastId int
+	for rows.Next() {
+		var invoice models.InvoiceData
+		var id int
+		if err := rows.Scan(
+			&id,
+			&invoice.CustomerID,
+			&invoice.CusFirstName,
+			&invoice.CusLastName,
+			&invoice.Total,
+			&invoice.InvoiceURL,
+			&invoice.Status,
+			&invoice.Attempted,
+			&invoice.ApplicationFeeAmt,
+			&invoice.ProductName,
+			&invoice.ProductID,
+			&invoice.PaymentIntentStatus,
+			&invoice.Created,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if len(invoices) == page.Limit {
+			next = &models.Cursor{Created: invoices[len(invoices) - 1].Created, ID: lastId}
+			break
+		}
+
+		lastId = id
+		invoice.InvoiceID = strconv.Itoa(id)
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices, next, rows.Err()
+}
+
+// ListBusinessSubs filters status on active or cancelled
+func (b *BusinessDB) ListBusinessSubs(
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) ([]models.SubInfo, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("p.business_id=?", businessId)
+	q.Filter(filter, "s.start_date", "", "p.product_id")
+	q.After(page.After, "s.start_date", "s.sub_id")
+
+	if filter != nil {
+		switch filter.Status {
+		case "active":
+			q.Where("s.cancelled=?", false)
+		case "cancelled":
+			q.Where("s.cancelled=?", true)
+		}
+	}
+
+	stmt := fmt.Sprintf(`SELECT 
+	c.customer_id, c.first_name, c.last_name, c.email, p.product_id, p."name", 
+	s.start_date, s.sub_id, s.plan_id, s.cancelled, s.cancelled_date, s.expires
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription as s on sp.plan_id=s.plan_id
+	JOIN customer as c on c.customer_id=s.customer_id
+	WHERE %s
+	ORDER BY s.start_date DESC, s.sub_id DESC
+	LIMIT %d`, q.String(), page.Limit + 1)
+
+	rows, err := b.DB.Query(stmt, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	subInfos := []models.SubInfo{}
+	var next *models.Cursor
+	for rows.Next() {
+		var info models.SubInfo
+		if err := rows.Scan(
+			&info.Subscription.CustomerID,
+			&info.FirstName,
+			&info.LastName,
+			&info.Email,
+			&info.ProductID,
+			&info.ProductName,
+			&info.Subscription.StartDate,
+			&info.Subscription.ID,
+			&info.Subscription.PlanID,
+			&info.Subscription.Cancelled,
+			&info.Subscription.CancelledDate,
+			&info.Subscription.Expires,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if len(subInfos) == page.Limit {
+			last := subInfos[len(subInfos) - 1].Subscription
+			next = &models.Cursor{Created: last.StartDate, ID: last.ID}
+			break
+		}
+
+		subInfos = append(subInfos, info)
+	}
+
+	return subInfos, next, rows.Err()
+}
+
+func (b *BusinessDB) ListBusinessUsages(
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) ([]models.UsageInfo, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("p.business_id=?", businessId)
+	q.Filter(filter, "cu.created", "", "p.product_id")
+	q.After(page.After, "cu.created", "cu.usage_id")
+
+	stmt := fmt.Sprintf(`SELECT 
+	cu.usage_id, c.uuid, c.first_name, c.last_name, 
+	cu.created, COALESCE(cu.sub_id, 0), sp.plan_id,
+	su.title, su.sub_usage_id, su.unlimited, su.interval, su.amount, 
+	p.product_id, p.name 
+	FROM product as p
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription_usage as su on su.plan_id=sp.plan_id
+	JOIN customer_usage as cu on cu.sub_usage_id=su.sub_usage_id
+	JOIN customer as c on c.uuid=cu.customer_uuid
+	WHERE %s
+	ORDER BY cu.created DESC, cu.usage_id DESC
+	LIMIT %d`, q.String(), page.Limit + 1)
+
+	rows, err := b.DB.Query(stmt, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	usageInfos := []models.UsageInfo{}
+	var next *models.Cursor
+	var lastId int
+	for rows.Next() {
+		var u models.UsageInfo
+		var id int
+		if err := rows.Scan(
+			&id,
+			&u.CusUUID,
+			&u.CusFirstName,
+			&u.CusLastName,
+			&u.Created,
+			&u.SubID,
+			&u.PlanID,
+			&u.SubUsage.Title,
+			&u.SubUsage.ID,
+			&u.SubUsage.Unlimited,
+			&u.SubUsage.Interval,
+			&u.SubUsage.Amount,
+			&u.ProductID,
+			&u.ProductName,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if len(usageInfos) == page.Limit {
+			next = &models.Cursor{Created: usageInfos[len(usageInfos) - 1].Created, ID: lastId}
+			break
+		}
+
+		lastId = id
+		usageInfos = append(usageInfos, u)
+	}
+
+	return usageInfos, next, rows.Err()
+}
+
+// ListBusinessPayouts pages on arrival date, there's no product to filter on
+func (b *BusinessDB) ListBusinessPayouts(
+	businessId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) ([]bus_models.BusinessPayout, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("bp.business_id=?", businessId)
+	q.Filter(filter, "bp.arrival_date", "bp.status", "")
+	q.After(page.After, "bp.arrival_date", "bp.payout_id")
+
+	stmt := fmt.Sprintf(`SELECT 
+	bp.payout_id, bp.amount, bp.currency, bp.status, bp.arrival_date, bp.stripe_dest_id, bp.type, bp.external_account_id
+	FROM business_payout as bp
+	WHERE %s
+	ORDER BY bp.arrival_date DESC, bp.payout_id DESC
+	LIMIT %d`, q.String(), page.Limit + 1)
+
+	rows, err := b.DB.Query(stmt, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	payouts := []bus_models.BusinessPayout{}
+	var next *models.Cursor
+	for rows.Next() {
+		var p bus_models.BusinessPayout
+		if err := rows.Scan(
+			&p.ID,
+			&p.Amount,
+			&p.Currency,
+			&p.Status,
+			&p.ArrivalDate,
+			&p.StripeDestID,
+			&p.Type,
+			&p.ExternalAccountID,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if len(payouts) == page.Limit {
+			last := payouts[len(payouts) - 1]
+			next = &models.Cursor{Created: last.ArrivalDate, ID: last.ID}
+			break
+		}
+
+		payouts = append(payouts, p)
+	}
+
+	return payouts, next, rows.Err()
+}
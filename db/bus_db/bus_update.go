@@ -133,4 +133,29 @@ func (b *BusinessDB) UpdateBusinessBankAccount(
 		Last4: bankAccount.Last4,
 		RoutingNumber: bankAccount.RoutingNumber,
 	}, err
-}
\ No newline at end of file
+}
+func (b *BusinessDB) SetBusinessTimeZone(
+	businessId int,
+	timeZone string,
+) (error) {
+	_, err := b.DB.Exec(`UPDATE business SET time_zone=$1 WHERE business_id=$2`, 
+		timeZone, businessId,
+	)
+
+	return err
+}
+
+// SetBusinessAddress saves where the business is along with the point it geocoded to
+func (b *BusinessDB) SetBusinessAddress(
+	businessId int,
+	address models.Address,
+	location models.Location,
+) (error) {
+	_, err := b.DB.Exec(`UPDATE business SET address_line1=$1, address_line2=$2, postal_code=$3, city=$4,
+		latitude=$5, longitude=$6, geocoded=now() WHERE business_id=$7`,
+		address.Line1, address.Line2, address.PostalCode, address.City,
+		location.Latitude, location.Longitude, businessId,
+	)
+
+	return err
+}
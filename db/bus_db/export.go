@@ -0,0 +1,211 @@
+package busdb
+
+import (
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+)
+
+// the Stream functions hand each row to fn as it's read so exports of any size
+// never sit in memory, returning an error from fn stops the stream
+
+func (b *BusinessDB) StreamBusinessInvoices(
+	businessId int,
+	from time.Time,
+	to time.Time,
+	fn func(models.InvoiceData) error,
+) (error) {
+	stmt := `SELECT 
+	c.customer_id, c.first_name, c.last_name, 
+	i.stripe_in_id, i.total, i.invoice_url, i.status, i.attempted, i.app_fee_amt,
+	p.name, p.product_id, i.payment_intent_status, i.created
+	FROM business as b
+	JOIN product as p ON b.business_id=p.business_id
+	JOIN invoice as i on i.stripe_prod_id=p.stripe_product_id
+	JOIN customer as c on i.stripe_cus_id=c.stripe_id
+	WHERE b.business_id=$1 AND i.created >= $2 AND i.created < $3
+	ORDER BY i.created`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var invoice models.InvoiceData
+		if err := rows.Scan(
+			&invoice.CustomerID,
+			&invoice.CusFirstName,
+			&invoice.CusLastName,
+			&invoice.InvoiceID,
+			&invoice.Total,
+			&invoice.InvoiceURL,
+			&invoice.Status,
+			&invoice.Attempted,
+			&invoice.ApplicationFeeAmt,
+			&invoice.ProductName,
+			&invoice.ProductID,
+			&invoice.PaymentIntentStatus,
+			&invoice.Created,
+		); err != nil {
+			return err
+		}
+
+		if err := fn(invoice); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (b *BusinessDB) StreamBusinessSubs(
+	businessId int,
+	from time.Time,
+	to time.Time,
+	fn func(models.SubInfo) error,
+) (error) {
+	stmt := `
+	SELECT c.customer_id, c.first_name, c.last_name, c.email, p.product_id, p."name", 
+	s.start_date, s.sub_id, s.cancelled, s.cancelled_date, s.expires
+	FROM
+	business as b 
+	JOIN product as p on b.business_id=p.business_id
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription as s on sp.plan_id=s.plan_id
+	JOIN customer as c on c.customer_id=s.customer_id
+	WHERE b.business_id=$1 AND s.start_date >= $2 AND s.start_date < $3
+	ORDER BY s.start_date`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var info models.SubInfo
+		if err := rows.Scan(
+			&info.Subscription.CustomerID,
+			&info.FirstName,
+			&info.LastName,
+			&info.Email,
+			&info.ProductID,
+			&info.ProductName,
+			&info.Subscription.StartDate,
+			&info.Subscription.ID,
+			&info.Subscription.Cancelled,
+			&info.Subscription.CancelledDate,
+			&info.Subscription.Expires,
+		); err != nil {
+			return err
+		}
+
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (b *BusinessDB) StreamBusinessUsages(
+	businessId int,
+	from time.Time,
+	to time.Time,
+	fn func(models.UsageInfo) error,
+) (error) {
+	stmt := `SELECT 
+	c.uuid, c.first_name, c.last_name, 
+	cu.created, COALESCE(cu.sub_id, 0),
+	su.title, su.sub_usage_id, su.unlimited, su.interval, su.amount, 
+	p.product_id, p.name 
+	FROM
+	business as b
+	JOIN product as p ON b.business_id=p.business_id
+	JOIN subscription_plan as sp on sp.product_id=p.product_id
+	JOIN subscription_usage as su on su.plan_id=sp.plan_id
+	JOIN customer_usage as cu on cu.sub_usage_id=su.sub_usage_id
+	JOIN customer as c on c.uuid=cu.customer_uuid
+	WHERE b.business_id=$1 AND cu.created >= $2 AND cu.created < $3
+	ORDER BY cu.created`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var u models.UsageInfo
+		if err := rows.Scan(
+			&u.CusUUID,
+			&u.CusFirstName,
+			&u.CusLastName,
+			&u.Created,
+			&u.SubID,
+			&u.SubUsage.Title,
+			&u.SubUsage.ID,
+			&u.SubUsage.Unlimited,
+			&u.SubUsage.Interval,
+			&u.SubUsage.Amount,
+			&u.ProductID,
+			&u.ProductName,
+		); err != nil {
+			return err
+		}
+
+		if err := fn(u); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (b *BusinessDB) StreamBusinessPayouts(
+	businessId int,
+	from time.Time,
+	to time.Time,
+	fn func(bus_models.BusinessPayout) error,
+) (error) {
+	stmt := `SELECT 
+	bp.payout_id, bp.amount, bp.currency, bp.status, bp.arrival_date, bp.stripe_dest_id, bp.type, bp.external_account_id
+	FROM business_payout as bp
+	WHERE bp.business_id=$1 AND bp.arrival_date >= $2 AND bp.arrival_date < $3
+	ORDER BY bp.arrival_date`
+
+	rows, err := b.DB.Query(stmt, businessId, from, to)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var p bus_models.BusinessPayout
+		if err := rows.Scan(
+			&p.ID,
+			&p.Amount,
+			&p.Currency,
+			&p.Status,
+			&p.ArrivalDate,
+			&p.StripeDestID,
+			&p.Type,
+			&p.ExternalAccountID,
+		); err != nil {
+			return err
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
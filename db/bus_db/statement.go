@@ -0,0 +1,242 @@
+package busdb
+
+import (
+	"database/sql"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models/bus_models"
+)
+
+type StatementDB struct {
+	DB	*sql.DB
+}
+
+// GetStatementTotals sums succeeded invoices and paid gifts against refunds
+// and payouts over [from, to)
+func (s *StatementDB) GetStatementTotals(
+	businessId int,
+	from time.Time,
+	to time.Time,
+) (*bus_models.StatementTotals, error) {
+	query := `
+	SELECT
+	COALESCE((
+		SELECT SUM(i.total) FROM invoice as i
+		JOIN product as p on p.stripe_product_id=i.stripe_prod_id
+		WHERE p.business_id=$1 AND i.payment_intent_status=$4 AND i.created >= $2 AND i.created < $3
+	), 0) + COALESCE((
+		SELECT SUM(g.amount) FROM gift as g
+		JOIN subscription_plan as sp on sp.plan_id=g.plan_id
+		JOIN product as p on p.product_id=sp.product_id
+		WHERE p.business_id=$1 AND g.status != $5 AND g.created >= $2 AND g.created < $3
+	), 0),
+	COALESCE((
+		SELECT SUM(i.app_fee_amt) FROM invoice as i
+		JOIN product as p on p.stripe_product_id=i.stripe_prod_id
+		WHERE p.business_id=$1 AND i.payment_intent_status=$4 AND i.created >= $2 AND i.created < $3
+	), 0),
+	COALESCE((
+		SELECT SUM(r.amount) FROM refund as r
+		WHERE r.business_id=$1 AND r.created >= $2 AND r.created < $3
+	), 0),
+	COALESCE((
+		SELECT SUM(bp.amount) FROM business_payout as bp
+		WHERE bp.business_id=$1 AND bp.status='paid' AND bp.arrival_date >= $2 AND bp.arrival_date < $3
+	), 0)
+	`
+
+	var totals bus_models.StatementTotals
+	err := s.DB.QueryRow(
+		query, businessId, from, to, my_enums.PMIPaymentSucceeded, my_enums.GiftPendingPayment,
+	).Scan(
+		&totals.Gross,
+		&totals.Fees,
+		&totals.Refunds,
+		&totals.Payouts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &totals, nil
+}
+
+func (s *StatementDB) GetStatementProducts(
+	businessId int,
+	from time.Time,
+	to time.Time,
+) ([]bus_models.StatementProduct, error) {
+	query := `
+	SELECT p.product_id, p.name, COUNT(i.invoice_id), SUM(i.total), COALESCE(SUM(i.app_fee_amt), 0)
+	FROM invoice as i
+	JOIN product as p on p.stripe_product_id=i.stripe_prod_id
+	WHERE p.business_id=$1 AND i.payment_intent_status=$4 AND i.created >= $2 AND i.created < $3
+	GROUP BY p.product_id
+	ORDER BY SUM(i.total) DESC
+	`
+
+	rows, err := s.DB.Query(query, businessId, from, to, my_enums.PMIPaymentSucceeded)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	products := []bus_models.StatementProduct{}
+	for rows.Next() {
+		var p bus_models.StatementProduct
+		if err := rows.Scan(
+			&p.ProductID,
+			&p.Name,
+			&p.Payments,
+			&p.Gross,
+			&p.Fees,
+		); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+func (s *StatementDB) UpsertStatement(st *bus_models.Statement) (error) {
+	query := `
+	INSERT into business_statement 
+	(
+		business_id, month, currency, gross, fees, refunds, payouts, 
+		opening_balance, closing_balance, s3_key, created
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	ON CONFLICT (business_id, month) DO UPDATE
+	SET gross=$4, fees=$5, refunds=$6, payouts=$7, opening_balance=$8, closing_balance=$9, s3_key=$10, created=$11
+	RETURNING statement_id
+	`
+
+	return s.DB.QueryRow(
+		query, st.BusinessID, st.Month.Format("2006-01-02"), st.Currency, st.Gross, st.Fees, st.Refunds, st.Payouts,
+		st.OpeningBalance, st.ClosingBalance, st.S3Key, st.Created,
+	).Scan(&st.ID)
+}
+
+const statementCols = `statement_id, business_id, month, currency, gross, fees, refunds, payouts, 
+	opening_balance, closing_balance, s3_key, created, emailed_at`
+
+func scanStatement(row interface{ Scan(...interface{}) error }) (*bus_models.Statement, error) {
+	var st bus_models.Statement
+	err := row.Scan(
+		&st.ID,
+		&st.BusinessID,
+		&st.Month,
+		&st.Currency,
+		&st.Gross,
+		&st.Fees,
+		&st.Refunds,
+		&st.Payouts,
+		&st.OpeningBalance,
+		&st.ClosingBalance,
+		&st.S3Key,
+		&st.Created,
+		&st.EmailedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// months are dates so they're passed as such, a timestamp would shift with the session's zone
+func (s *StatementDB) GetStatement(businessId int, month time.Time) (*bus_models.Statement, error) {
+	query := `SELECT ` + statementCols + ` FROM business_statement WHERE business_id=$1 AND month=$2`
+	return scanStatement(s.DB.QueryRow(query, businessId, month.Format("2006-01-02")))
+}
+
+func (s *StatementDB) GetBusinessStatements(businessId int) ([]bus_models.Statement, error) {
+	query := `SELECT ` + statementCols + ` FROM business_statement WHERE business_id=$1 ORDER BY month DESC`
+
+	rows, err := s.DB.Query(query, businessId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	statements := []bus_models.Statement{}
+	for rows.Next() {
+		st, err := scanStatement(rows)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, *st)
+	}
+
+	return statements, rows.Err()
+}
+
+func (s *StatementDB) SetStatementEmailed(statementId int) (error) {
+	_, err := s.DB.Exec(`UPDATE business_statement SET emailed_at=$1 WHERE statement_id=$2`, time.Now(), statementId)
+	return err
+}
+
+// GetStatementBusinessIDs is every business that had any money movement since from
+func (s *StatementDB) GetStatementBusinessIDs(from time.Time) ([]int, error) {
+	query := `
+	SELECT p.business_id FROM invoice as i
+	JOIN product as p on p.stripe_product_id=i.stripe_prod_id
+	WHERE i.created >= $1
+	UNION
+	SELECT bp.business_id FROM business_payout as bp WHERE bp.arrival_date >= $1
+	UNION
+	SELECT r.business_id FROM refund as r WHERE r.created >= $1
+	`
+
+	rows, err := s.DB.Query(query, from)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// InsertRefund is safe to repeat for the same stripe refund
+func (s *StatementDB) InsertRefund(r bus_models.Refund) (error) {
+	query := `
+	INSERT into refund (stripe_refund_id, business_id, amount, currency, created)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (stripe_refund_id) DO NOTHING
+	`
+
+	_, err := s.DB.Exec(query, r.StripeRefundID, r.BusinessID, r.Amount, r.Currency, r.Created)
+	return err
+}
+
+// GetPaymentBusinessID finds whose payment a stripe payment intent was, either
+// a subscription invoice or a gift
+func (s *StatementDB) GetPaymentBusinessID(pmiId string) (int, error) {
+	query := `
+	SELECT p.business_id FROM invoice as i
+	JOIN product as p on p.stripe_product_id=i.stripe_prod_id
+	WHERE i.stripe_pmi_id=$1
+	UNION
+	SELECT p.business_id FROM gift as g
+	JOIN subscription_plan as sp on sp.plan_id=g.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	WHERE g.stripe_pmi_id=$1
+	`
+
+	var businessId int
+	err := s.DB.QueryRow(query, pmiId).Scan(&businessId)
+	return businessId, err
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/johnyeocx/usual/server/db/models"
 )
@@ -10,9 +11,17 @@ type BusinessDB struct {
 	DB *sql.DB
 }
 
+// GetBusinessWithTopSubbedProduct pages by offset since the ranking shifts as people subscribe.
+// With near, closer businesses rank higher and come back with their distance
+// GetBusinessWithTopSubbedProduct is the global explore feed. With exceptRecommendedTo,
+// products already in that customer's recommended feed are left out
 func (b *BusinessDB) GetBusinessWithTopSubbedProduct(
+	offset int,
+	limit int,
+	near *models.Location,
+	exceptRecommendedTo *int,
 ) ([]models.ExploreResult, error){
-	query := `
+	query := fmt.Sprintf(`
 	WITH ranked_table AS (
 
 		SELECT b.business_id, sp.plan_id, COUNT(s.plan_id) as sub_count, ROW_NUMBER() OVER 
@@ -26,19 +35,27 @@ func (b *BusinessDB) GetBusinessWithTopSubbedProduct(
 	)
 	
 	SELECT 
-	b.business_id, b.name, b.business_category, b.description, b.business_url,
-	p.product_id, p.name, p.description,
+	b.business_id, b.name, b.business_category, b.description, b.business_url, b.images,
+	p.product_id, p.name, p.description, p.images,
 	sp.plan_id, recurring_interval, recurring_interval_count, unit_amount, sub_count,
-	pc.title
+	pc.title, %s,
+	%s,
+	%s
 	FROM ranked_table as r 
 	JOIN business as b ON r.business_id=b.business_id
 	JOIN subscription_plan as sp ON r.plan_id=sp.plan_id
 	JOIN product as p on sp.product_id=p.product_id
 	JOIN product_category as pc on p.category_id=pc.category_id
-	WHERE rank = 1
-	`
-
-	rows, err := b.DB.Query(query)
+	%s
+	WHERE rank = 1 AND ($3::int IS NULL OR p.product_id NOT IN 
+		(SELECT rr.product_id FROM (%s) as rr WHERE rr.rank = 1))
+	ORDER BY (sub_count + 1) * %s DESC, sp.plan_id ASC
+	OFFSET %d LIMIT %d
+	`, knownDistance, businessRating, productRating, distanceJoin(1, 2), recommendedRanked(3),
+		distanceFactor, offset, limit)
+
+	lat, lng := nearArgs(near)
+	rows, err := b.DB.Query(query, lat, lng, exceptRecommendedTo)
 	if err != nil {
 		return nil, err
 	}
@@ -48,8 +65,8 @@ func (b *BusinessDB) GetBusinessWithTopSubbedProduct(
 	results := []models.ExploreResult{}
 
 	for rows.Next() {
-		var product models.Product
-		var business models.Business
+		product := models.Product{Rating: &models.RatingSummary{}}
+		business := models.Business{Rating: &models.RatingSummary{}}
 		var plan models.SubscriptionPlan
 
         if err := rows.Scan(
@@ -58,15 +75,22 @@ func (b *BusinessDB) GetBusinessWithTopSubbedProduct(
 			&business.BusinessCategory,
 			&business.Description,
 			&business.BusinessUrl,
+			&business.Images,
 			&product.ProductID,
 			&product.Name,
 			&product.Description,
+			&product.Images,
 			&plan.PlanID,
 			&plan.RecurringDuration.Interval,
 			&plan.RecurringDuration.IntervalCount,
 			&plan.UnitAmount,
 			&product.SubCount,
 			&product.CatTitle,
+			&business.DistanceM,
+			&business.Rating.Average,
+			&business.Rating.Count,
+			&product.Rating.Average,
+			&product.Rating.Count,
 		); err != nil {
 			continue
         }
@@ -83,137 +107,12 @@ func (b *BusinessDB) GetBusinessWithTopSubbedProduct(
 	return results, nil
 }
 
-func (b *BusinessDB) SearchSubProducts(q string) ([]models.ExploreResult, error){
-		search := "%" + q + "%"
-		query := `
-		SELECT 
-		b.business_id, b.name, b.business_category, b.description, b.business_url,
-		p.product_id, p.name, p.description, pc.title,
-		sp.plan_id, recurring_interval, recurring_interval_count, unit_amount
-				
-		FROM product as p 
-		JOIN subscription_plan as sp on sp.product_id=p.product_id 
-		JOIN product_category as pc on p.category_id=pc.category_id
-		JOIN business as b on b.business_id=p.business_id 
-		
-		WHERE
-		LOWER(pc.title) LIKE $1 OR
-		LOWER(p.name) LIKE $1 OR
-		LOWER(p.description) LIKE $1
-		
-		GROUP BY p.product_id, b.business_id, pc.category_id, sp.plan_id
-		
-		order by
-		case
-			WHEN LOWER(p."name") LIKE $1 then 0
-			WHEN LOWER(pc.title) LIKE $1 then 1
-			WHEN LOWER(p.description) LIKE $1 then 2
-			else 3
-		end asc;
-		`
-	
-		rows, err := b.DB.Query(query, search)
-		if err != nil {
-			return nil, err
-		}
-	
-		defer rows.Close()
-	
-		results := []models.ExploreResult{}
-	
-		for rows.Next() {
-			var product models.Product
-			var business models.Business
-			var plan models.SubscriptionPlan
-	
-			if err := rows.Scan(
-				&business.ID,
-				&business.Name,
-				&business.BusinessCategory,
-				&business.Description,
-				&business.BusinessUrl,
-				&product.ProductID,
-				&product.Name,
-				&product.Description,
-				&product.CatTitle,
-				&plan.PlanID,
-				&plan.RecurringDuration.Interval,
-				&plan.RecurringDuration.IntervalCount,
-				&plan.UnitAmount,
-			); err != nil {
-				continue
-			}
-	
-			results = append(results, models.ExploreResult{
-				Business: business,
-				SubProduct: models.SubscriptionProduct{
-					Product: product,
-					SubPlan: plan,
-				},
-			})
-		}
-	
-		return results, nil
-	}
-
-func (b *BusinessDB) SearchAccounts(
-	q string,	
-) ([]models.Business, error) {
-	search := "%" + q + "%"
-	query := `
-	SELECT b.business_id, b.name, b.business_category, b.description, b.business_url from business as b 
-	JOIN product_category as pc on b.business_id=pc.business_id
-	JOIN product as p on p.business_id=b.business_id WHERE
-	LOWER(b.name) LIKE $1 OR
-	LOWER(b.business_category) LIKE $1 OR
-	LOWER(pc.title) LIKE $1 OR
-	LOWER(p.name) LIKE $1 OR
-	LOWER(p.description) LIKE $1
-	
-	GROUP BY b.business_id
-	
-	order by
-		case
-			WHEN LOWER(b.name) LIKE $1 then 0
-			WHEN LOWER(b.business_category) LIKE $1 then 1
-			else 2
-		end asc;
-	`
-
-	rows, err := b.DB.Query(query, search)
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-
-	accounts := []models.Business{}
-
-	for rows.Next() {
-		var business models.Business
-
-        if err := rows.Scan(
-			&business.ID,
-			&business.Name,
-			&business.BusinessCategory,
-			&business.Description,
-			&business.BusinessUrl,
-		); err != nil {
-			continue
-        }
-
-		accounts = append(accounts, business)
-    }
-
-	return accounts, nil
-}
-
 func (s *BusinessDB) GetCBusinessSubProducts(
 	businessId int,
 ) (*[]models.SubscriptionProduct, error) {
 
 	selectStatement := `SELECT 
-	p.product_id, p.name, p.description, p.category_id, sp.plan_id, sp.currency, 
+	p.product_id, p.name, p.description, p.category_id, p.images, sp.plan_id, sp.currency, 
 	recurring_interval, recurring_interval_count, unit_amount, COUNT(DISTINCT c.customer_id) as sub_count
 
 	from product as p
@@ -243,6 +142,7 @@ func (s *BusinessDB) GetCBusinessSubProducts(
 			&product.Name,
 			&product.Description,
 			&product.CategoryID,
+			&product.Images,
 			&subPlan.PlanID,
 			&subPlan.Currency,
 			&subPlan.RecurringDuration.Interval,
@@ -268,8 +168,8 @@ func (s *BusinessDB) GetCSubProduct(
 ) (*models.SubscriptionProduct, error) {
 
 	stmt := `SELECT 
-	p.product_id, p.name, p.description, p.category_id, p.business_id, sp.plan_id, sp.currency, 
-	recurring_interval, recurring_interval_count, unit_amount, pc.title
+	p.product_id, p.name, p.description, p.category_id, p.business_id, p.images, sp.plan_id, sp.currency, 
+	recurring_interval, recurring_interval_count, unit_amount, pc.title, sp.seats, sp.shared_usage, sp.max_subscribers
 	
 	from product as p
 	JOIN subscription_plan as sp on p.product_id = sp.product_id
@@ -287,12 +187,16 @@ func (s *BusinessDB) GetCSubProduct(
 		&product.Description,
 		&product.CategoryID,
 		&product.BusinessID,
+		&product.Images,
 		&plan.PlanID,
 		&plan.Currency,
 		&plan.RecurringDuration.Interval,
 		&plan.RecurringDuration.IntervalCount,
 		&plan.UnitAmount,
 		&product.CatTitle,
+		&plan.Seats,
+		&plan.SharedUsage,
+		&plan.MaxSubscribers,
 	)
 	if err != nil {
 		return nil, err
@@ -308,7 +212,8 @@ func (s *BusinessDB) GetCSubProduct(
 func (businessDB *BusinessDB) GetBusinessByIDWithSubCount(businessId int) (*models.Business, error) {
 
 	selectStatement := `
-	SELECT COUNT (DISTINCT c.customer_id) as sub_count, b.business_id, b.name, b.email, b.country, b.business_category, b.business_url, b.description
+	SELECT COUNT (DISTINCT c.customer_id) as sub_count, b.business_id, b.name, b.email, b.country, b.business_category, b.business_url, b.description, b.images,
+	` + businessRating + `
 	FROM business as b 
 	JOIN product as p on b.business_id=p.business_id
 	JOIN subscription_plan as sp on sp.product_id=p.product_id
@@ -318,7 +223,7 @@ func (businessDB *BusinessDB) GetBusinessByIDWithSubCount(businessId int) (*mode
 	
 	GROUP BY b.business_id`
 
-	var business models.Business
+	business := models.Business{Rating: &models.RatingSummary{}}
 	if err := businessDB.DB.QueryRow(selectStatement, businessId).Scan(
 		&business.SubCount,
 		&business.ID,
@@ -328,6 +233,9 @@ func (businessDB *BusinessDB) GetBusinessByIDWithSubCount(businessId int) (*mode
 		&business.BusinessCategory,
 		&business.BusinessUrl,
 		&business.Description,
+		&business.Images,
+		&business.Rating.Average,
+		&business.Rating.Count,
 	); err != nil {
 		return nil, err
 	}
@@ -385,49 +385,6 @@ func (c *CustomerDB) CusHasPaidSubBefore(
 	return invoices, nil
 }
 
-func (c *CustomerDB) GetSubInvoices(
-	cusId int,
-	productId int, 
-	limit int,
-) ([]models.Invoice, error) {
-	query := fmt.Sprintf(`
-	SELECT 
-	i.invoice_id, i.paid, i.attempted, i.status, i.total, i.created, i.invoice_url, 
-	i.sub_id, i.card_id, i.payment_intent_status
-	FROM customer as c 
-	JOIN subscription as s on s.customer_id=c.customer_id
-	JOIN subscription_plan as sp on sp.plan_id=s.plan_id
-	JOIN product as p on p.product_id=sp.product_id
-	JOIN invoice as i ON i.sub_id=s.sub_id
-	WHERE c.customer_id=$1 AND p.product_id=$2
-	ORDER BY i.created DESC
-	LIMIT %d
-	`, limit)
-
-
-	rows, err := c.DB.Query(query, cusId, productId)
-	if err != nil {
-		return nil, err
-	}
-
-	invoices := []models.Invoice{}
-	for rows.Next() {
-		var in models.Invoice
-		in.Subscription = &models.Subscription{}
-		in.CardInfo = &models.CardInfo{}
-		if err := rows.Scan(
-			&in.ID, &in.Paid, &in.Attempted, &in.Status, &in.Total, &in.Created, &in.InvoiceURL,
-			&in.SubID,
-			&in.CardID, &in.PaymentIntentStatus,
-		); err != nil {
-			return nil, err
-		}
-		invoices = append(invoices, in)
-	}
-
-	return invoices, nil
-}
-
 func (c *CustomerDB) GetSubscriptionUsages(
 	cusId int,
 	productId int, 
@@ -523,18 +480,24 @@ func (c *CustomerDB) GetCusCard(cusId int, cardId int) (*models.CardInfo, error)
 	return &card, nil
 }
 
-func (c *CustomerDB) GetCusFCMToken(cusId int) (*string, error) {
+func (c *CustomerDB) GetCusFCMTokens(cusId int) ([]string, error) {
 	query := `
-		SELECT token FROM customer_fcm_token WHERE customer_id=$1
+		SELECT token FROM customer_device WHERE customer_id=$1 ORDER BY last_seen DESC
 	`
-	
-	var token string
-	err := c.DB.QueryRow(query, 
-		cusId,
-	).Scan(&token)
 
+	rows, err := c.DB.Query(query, cusId)
 	if err != nil {
 		return nil, err
 	}
-	return &token, nil
-}
\ No newline at end of file
+	defer rows.Close()
+
+	tokens := []string{}
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
@@ -0,0 +1,116 @@
+package cusdb
+
+import (
+	"fmt"
+
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// ListCusInvoices pages newest first, gift purchases have no subscription so
+// products are joined loosely
+func (c *CustomerDB) ListCusInvoices(
+	cusId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) ([]models.Invoice, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("c.customer_id=?", cusId)
+	q.Filter(filter, "i.created", "i.status", "p.product_id")
+	q.After(page.After, "i.created", "i.invoice_id")
+
+	query := fmt.Sprintf(`
+	SELECT 
+	i.invoice_id, i.paid, i.attempted, i.status, i.total, i.created, i.invoice_url, 
+	COALESCE(i.sub_id, 0), COALESCE(i.card_id, 0), i.payment_intent_status
+	FROM invoice as i
+	JOIN customer as c ON i.stripe_cus_id=c.stripe_id
+	LEFT JOIN product as p ON p.stripe_product_id=i.stripe_prod_id
+	WHERE %s
+	ORDER BY i.created DESC, i.invoice_id DESC
+	LIMIT %d
+	`, q.String(), page.Limit + 1)
+
+	rows, err := c.DB.Query(query, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	invoices := []models.Invoice{}
+	var next *models.Cursor
+	for rows.Next() {
+		var in models.Invoice
+		if err := rows.Scan(
+			&in.ID, &in.Paid, &in.Attempted, &in.Status, &in.Total, &in.Created, &in.InvoiceURL,
+			&in.SubID,
+			&in.CardID, &in.PaymentIntentStatus,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if len(invoices) == page.Limit {
+			last := invoices[len(invoices) - 1]
+			next = &models.Cursor{Created: last.Created, ID: last.ID}
+			break
+		}
+
+		invoices = append(invoices, in)
+	}
+
+	return invoices, next, rows.Err()
+}
+
+func (c *CustomerDB) ListCusUsages(
+	cusId int,
+	filter *models.ListFilter,
+	page *models.PageParams,
+) ([]models.CusUsage, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("c.customer_id=?", cusId)
+	q.Filter(filter, "cu.created", "", "p.product_id")
+	q.After(page.After, "cu.created", "cu.usage_id")
+
+	query := fmt.Sprintf(`
+	SELECT cu.usage_id, cu.customer_uuid, cu.created, cu.sub_usage_id, COALESCE(cu.sub_id, 0),
+	su.title, p.product_id, p."name"
+	FROM customer as c 
+	JOIN customer_usage as cu on cu.customer_uuid=c.uuid
+	JOIN subscription_usage as su on su.sub_usage_id=cu.sub_usage_id
+	JOIN subscription_plan as sp on sp.plan_id=su.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	WHERE %s
+	ORDER BY cu.created DESC, cu.usage_id DESC
+	LIMIT %d
+	`, q.String(), page.Limit + 1)
+
+	rows, err := c.DB.Query(query, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	usages := []models.CusUsage{}
+	var next *models.Cursor
+	for rows.Next() {
+		var usage models.CusUsage
+		if err := rows.Scan(
+			&usage.ID, &usage.CusUUID, &usage.Created, &usage.SubUsageID, &usage.SubID,
+			&usage.SubUsageTitle, &usage.ProductID, &usage.ProductName,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if len(usages) == page.Limit {
+			last := usages[len(usages) - 1]
+			next = &models.Cursor{Created: last.Created, ID: last.ID}
+			break
+		}
+
+		usages = append(usages, usage)
+	}
+
+	return usages, next, rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/lib/pq"
 )
 
 func (c *CustomerDB) UpdateCusDefaultCard(
@@ -73,9 +74,22 @@ func (c *CustomerDB) UpdateCusPassword(cusId int, passwordHash string) (error){
 	return err
 }
 
-func (c *CustomerDB) InsertOrUpdateCusFCMToken(cusId int, fcmToken string) (error) {
-	_, err := c.DB.Exec(`INSERT into customer_fcm_token 
-	(token, customer_id, last_updated) VALUES($1, $2, $3)
-	ON CONFLICT (customer_id) DO UPDATE SET token=$1, last_updated=$3`, fcmToken, cusId, time.Now())
+// UpsertCusDevice registers a device, a token seen again moves to whoever is now signed in on it
+func (c *CustomerDB) UpsertCusDevice(cusId int, device models.CusDevice) (error) {
+	now := time.Now()
+	_, err := c.DB.Exec(`INSERT into customer_device 
+	(customer_id, token, platform, app_version, created, last_seen) VALUES($1, $2, $3, $4, $5, $5)
+	ON CONFLICT (token) DO UPDATE SET customer_id=$1, platform=$3, app_version=$4, last_seen=$5`,
+	cusId, device.Token, device.Platform, device.AppVersion, now)
+	return err
+}
+
+func (c *CustomerDB) DeleteCusDevice(cusId int, fcmToken string) (error) {
+	_, err := c.DB.Exec(`DELETE FROM customer_device WHERE customer_id=$1 AND token=$2`, cusId, fcmToken)
+	return err
+}
+
+func (c *CustomerDB) DeleteFCMTokens(fcmTokens []string) (error) {
+	_, err := c.DB.Exec(`DELETE FROM customer_device WHERE token=ANY($1)`, pq.Array(fcmTokens))
 	return err
 }
\ No newline at end of file
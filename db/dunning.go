@@ -0,0 +1,195 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/lib/pq"
+)
+
+type DunningDB struct {
+	DB *sql.DB
+}
+
+const dunningSelect = `SELECT 
+	d.dunning_id, d.sub_id, d.stripe_in_id, d.amount, d.status, d.started, d.reminders_sent, 
+	d.last_reminder, d.suspended, d.resolved,
+	c.customer_id, c.email, c.first_name, b.business_id, b.name, p.name
+	FROM dunning as d
+	JOIN subscription as s on s.sub_id=d.sub_id
+	JOIN customer as c on c.customer_id=s.customer_id
+	JOIN subscription_plan as sp on sp.plan_id=s.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	JOIN business as b on b.business_id=p.business_id
+`
+
+func scanDunningCase(row interface{ Scan(dest ...interface{}) error }) (*models.DunningCase, error) {
+	var d models.DunningCase
+	err := row.Scan(
+		&d.ID, &d.SubID, &d.InStripeID, &d.Amount, &d.Status, &d.Started, &d.RemindersSent,
+		&d.LastReminder, &d.Suspended, &d.Resolved,
+		&d.CustomerID, &d.CusEmail, &d.CusFirstName, &d.BusinessID, &d.BusinessName, &d.ProductName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (d *DunningDB) getDunningCases(where string, args ...interface{}) ([]models.DunningCase, error) {
+	rows, err := d.DB.Query(dunningSelect + where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	cases := []models.DunningCase{}
+	for rows.Next() {
+		dunning, err := scanDunningCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, *dunning)
+	}
+
+	return cases, nil
+}
+
+func (d *DunningDB) GetDunningCase(dunningId int) (*models.DunningCase, error) {
+	return scanDunningCase(d.DB.QueryRow(dunningSelect + ` WHERE d.dunning_id=$1`, dunningId))
+}
+
+func (d *DunningDB) GetOpenDunningCases() ([]models.DunningCase, error) {
+	return d.getDunningCases(` WHERE d.status=$1 ORDER BY d.started`, my_enums.DunningOpen)
+}
+
+func (d *DunningDB) GetCusOpenDunningCases(cusId int) ([]models.DunningCase, error) {
+	return d.getDunningCases(
+		` WHERE c.customer_id=$1 AND d.status=$2 ORDER BY d.started`, 
+		cusId, my_enums.DunningOpen,
+	)
+}
+
+// InsertDunningCase opens a case for a failed invoice, stripe retries fire
+// payment_failed again for the same invoice so ErrNoRows means it's already open
+func (d *DunningDB) InsertDunningCase(subId int, inStripeId string, amount int) (*int, error) {
+	query := `INSERT into dunning (sub_id, stripe_in_id, amount, status, started) 
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (stripe_in_id) DO NOTHING
+	RETURNING dunning_id`
+
+	var dunningId int
+	err := d.DB.QueryRow(query, subId, inStripeId, amount, my_enums.DunningOpen, time.Now()).Scan(&dunningId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dunningId, nil
+}
+
+// SetDunningReminderSent claims the next reminder on an open case, returns false if
+// remindersSent is stale because another run already sent it
+func (d *DunningDB) SetDunningReminderSent(dunningId int, remindersSent int) (bool, error) {
+	res, err := d.DB.Exec(`UPDATE dunning SET reminders_sent=reminders_sent+1, last_reminder=$1 
+		WHERE dunning_id=$2 AND reminders_sent=$3 AND status=$4`, 
+		time.Now(), dunningId, remindersSent, my_enums.DunningOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SetDunningSuspended returns false if the case was already suspended or is no longer open
+func (d *DunningDB) SetDunningSuspended(dunningId int) (bool, error) {
+	res, err := d.DB.Exec(`UPDATE dunning SET suspended=TRUE 
+		WHERE dunning_id=$1 AND suspended=FALSE AND status=$2`, 
+		dunningId, my_enums.DunningOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReopenDunningCase puts back a case that was closed as lost but couldn't be cancelled
+func (d *DunningDB) ReopenDunningCase(dunningId int) (error) {
+	_, err := d.DB.Exec(`UPDATE dunning SET status=$1, resolved=NULL, suspended=TRUE 
+		WHERE dunning_id=$2`, my_enums.DunningOpen, dunningId,
+	)
+	return err
+}
+
+// ResolveDunningCase closes the open case on an invoice, returns false if there wasn't one
+func (d *DunningDB) ResolveDunningCase(inStripeId string, status my_enums.DunningStatus) (bool, error) {
+	res, err := d.DB.Exec(`UPDATE dunning SET status=$1, resolved=$2, suspended=FALSE 
+		WHERE stripe_in_id=$3 AND status=$4`, 
+		status, time.Now(), inStripeId, my_enums.DunningOpen,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (d *DunningDB) GetDunningSettings(businessId int) (*models.DunningSettings, error) {
+	settings := models.DunningSettings{BusinessID: businessId}
+	err := d.DB.QueryRow(`SELECT reminder_days, suspend_after_days, cancel_after_days 
+		FROM dunning_settings WHERE business_id=$1`, businessId,
+	).Scan(
+		(*pq.Int64Array)(&settings.ReminderDays),
+		&settings.SuspendAfterDays,
+		&settings.CancelAfterDays,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (d *DunningDB) SetDunningSettings(settings models.DunningSettings) (error) {
+	_, err := d.DB.Exec(`INSERT into dunning_settings 
+		(business_id, reminder_days, suspend_after_days, cancel_after_days) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (business_id) DO UPDATE 
+		SET reminder_days=$2, suspend_after_days=$3, cancel_after_days=$4`,
+		settings.BusinessID, pq.Int64Array(settings.ReminderDays), 
+		settings.SuspendAfterDays, settings.CancelAfterDays,
+	)
+	return err
+}
+
+func (d *DunningDB) GetBusinessDunningStats(businessId int) (*models.DunningStats, error) {
+	query := `SELECT 
+	COALESCE(SUM(d.amount) FILTER (WHERE d.status=$2), 0), COUNT(*) FILTER (WHERE d.status=$2),
+	COALESCE(SUM(d.amount) FILTER (WHERE d.status=$3), 0), COUNT(*) FILTER (WHERE d.status=$3),
+	COALESCE(SUM(d.amount) FILTER (WHERE d.status=$4), 0), COUNT(*) FILTER (WHERE d.status=$4)
+	FROM dunning as d
+	JOIN subscription as s on s.sub_id=d.sub_id
+	JOIN subscription_plan as sp on sp.plan_id=s.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	WHERE p.business_id=$1`
+
+	var stats models.DunningStats
+	err := d.DB.QueryRow(query, 
+		businessId, my_enums.DunningRecovered, my_enums.DunningOpen, my_enums.DunningLost,
+	).Scan(
+		&stats.RecoveredRevenue, &stats.RecoveredCount,
+		&stats.OpenAmount, &stats.OpenCount,
+		&stats.LostAmount, &stats.LostCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
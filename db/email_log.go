@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/external/media"
+)
+
+// EmailLogDB backs media.EmailLog with the sent_email table
+type EmailLogDB struct {
+	DB *sql.DB
+}
+
+// InsertEmailLog leaves out inline attachments, which are the same logo on every email,
+// and the body of secret emails so one time codes aren't kept
+func (e *EmailLogDB) InsertEmailLog(email *media.Email, provider string) (int, error) {
+	kept := []media.Attachment{}
+	for _, a := range email.Attachments {
+		if !a.Inline {
+			kept = append(kept, a)
+		}
+	}
+
+	attachments, err := json.Marshal(kept)
+	if err != nil {
+		return 0, err
+	}
+
+	html := email.HTML
+	if email.Secret {
+		html = nil
+	}
+
+	query := `INSERT into sent_email 
+		(to_email, from_email, subject, template, html, attachments, provider, status, attempts, created) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, $9) RETURNING email_id
+	`
+
+	var id int
+	err = e.DB.QueryRow(
+		query, email.To, email.From, email.Subject, email.Template, html, attachments,
+		provider, my_enums.EmailQueued, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// the body and attachments are only kept while the email might need resending
+func (e *EmailLogDB) SetEmailSent(logId int, attempts int) (error) {
+	query := `UPDATE sent_email SET status=$2, attempts=$3, sent=$4, html=NULL, attachments=NULL 
+		WHERE email_id=$1`
+	_, err := e.DB.Exec(query, logId, my_enums.EmailSent, attempts, time.Now())
+	return err
+}
+
+func (e *EmailLogDB) SetEmailFailed(logId int, attempts int, sendErr error) (error) {
+	query := `UPDATE sent_email SET status=$2, attempts=$3, error=$4, html=NULL, attachments=NULL 
+		WHERE email_id=$1`
+	_, err := e.DB.Exec(query, logId, my_enums.EmailFailed, attempts, sendErr.Error())
+	return err
+}
+
+// ClaimQueuedEmails moves emails logged before before that were never sent or given up on
+// to sending and returns them, so no other server resends them too. Secret emails have
+// no body to resend and are left for DeleteOldEmailLogs
+func (e *EmailLogDB) ClaimQueuedEmails(before time.Time) ([]media.LoggedEmail, error) {
+	query := `UPDATE sent_email SET status=$1 
+		WHERE email_id IN (
+			SELECT email_id FROM sent_email 
+			WHERE status=$2 AND created < $3 AND html IS NOT NULL
+			ORDER BY created ASC FOR UPDATE SKIP LOCKED
+		) RETURNING email_id, to_email, from_email, subject, template, html, attachments, attempts`
+
+	rows, err := e.DB.Query(query, my_enums.EmailSending, my_enums.EmailQueued, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []media.LoggedEmail{}
+	for rows.Next() {
+		var le media.LoggedEmail
+		var email media.Email
+		var attachments []byte
+		if err := rows.Scan(
+			&le.ID, &email.To, &email.From, &email.Subject, &email.Template, &email.HTML, 
+			&attachments, &le.Attempts,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(attachments, &email.Attachments); err != nil {
+			return nil, err
+		}
+		le.Email = &email
+		emails = append(emails, le)
+	}
+	return emails, rows.Err()
+}
+
+// DeleteOldEmailLogs purges the log of emails created before before, whatever happened to them
+func (e *EmailLogDB) DeleteOldEmailLogs(before time.Time) (error) {
+	_, err := e.DB.Exec(`DELETE FROM sent_email WHERE created < $1`, before)
+	return err
+}
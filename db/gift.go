@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+type GiftDB struct {
+	DB *sql.DB
+}
+
+const giftSelect = `SELECT 
+	g.gift_id, g.code, g.purchaser_id, g.recipient_email, g.recipient_id, g.plan_id, g.periods,
+	g.amount, g.currency, g.message, g.stripe_pmi_id, g.status, g.created, g.claim_by, g.claimed_date,
+	g.sub_id, g.reminder_sent,
+	c.first_name, b.name,
+	p.product_id, p.name, p.description,
+	sp.recurring_interval, sp.recurring_interval_count, sp.unit_amount
+	FROM gift as g
+	JOIN customer as c on c.customer_id=g.purchaser_id
+	JOIN subscription_plan as sp on sp.plan_id=g.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	JOIN business as b on b.business_id=p.business_id
+`
+
+func scanGift(row interface{ Scan(dest ...interface{}) error }) (*models.Gift, error) {
+	var g models.Gift
+	var product models.Product
+	var plan models.SubscriptionPlan
+
+	err := row.Scan(
+		&g.ID, &g.Code, &g.PurchaserID, &g.RecipientEmail, &g.RecipientID, &g.PlanID, &g.Periods,
+		&g.Amount, &g.Currency, &g.Message, &g.StripePMIID, &g.Status, &g.Created, &g.ClaimBy, &g.ClaimedDate,
+		&g.SubID, &g.ReminderSent,
+		&g.PurchaserName, &g.BusinessName,
+		&product.ProductID, &product.Name, &product.Description,
+		&plan.RecurringDuration.Interval, &plan.RecurringDuration.IntervalCount, &plan.UnitAmount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.PlanID = g.PlanID
+	plan.ProductID = product.ProductID
+	g.SubProduct = &models.SubscriptionProduct{
+		Product: product,
+		SubPlan: plan,
+	}
+	return &g, nil
+}
+
+func (g *GiftDB) InsertGift(gift models.Gift) (*int, error) {
+	query := `INSERT into gift 
+	(code, purchaser_id, recipient_email, plan_id, periods, amount, currency, message, status, created, claim_by) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING gift_id`
+
+	var giftId int
+	err := g.DB.QueryRow(query,
+		gift.Code, gift.PurchaserID, gift.RecipientEmail, gift.PlanID, gift.Periods, 
+		gift.Amount, gift.Currency, gift.Message, gift.Status, gift.Created, gift.ClaimBy,
+	).Scan(&giftId)
+
+	if err != nil {
+		return nil, err
+	}
+	return &giftId, nil
+}
+
+func (g *GiftDB) GetGiftByID(giftId int) (*models.Gift, error) {
+	return scanGift(g.DB.QueryRow(giftSelect + ` WHERE g.gift_id=$1`, giftId))
+}
+
+func (g *GiftDB) GetGiftByCode(code string) (*models.Gift, error) {
+	return scanGift(g.DB.QueryRow(giftSelect + ` WHERE g.code=$1`, code))
+}
+
+func (g *GiftDB) getGifts(where string, args ...interface{}) ([]models.Gift, error) {
+	rows, err := g.DB.Query(giftSelect + where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	gifts := []models.Gift{}
+	for rows.Next() {
+		gift, err := scanGift(rows)
+		if err != nil {
+			return nil, err
+		}
+		gifts = append(gifts, *gift)
+	}
+
+	return gifts, nil
+}
+
+func (g *GiftDB) GetCusPurchasedGifts(cusId int) ([]models.Gift, error) {
+	return g.getGifts(` WHERE g.purchaser_id=$1 ORDER BY g.created DESC`, cusId)
+}
+
+func (g *GiftDB) GetCusReceivedGifts(cusId int) ([]models.Gift, error) {
+	return g.getGifts(` WHERE g.recipient_id=$1 ORDER BY g.claimed_date DESC`, cusId)
+}
+
+func (g *GiftDB) GetUnclaimedGiftsBefore(claimBy time.Time) ([]models.Gift, error) {
+	return g.getGifts(` WHERE g.status=$1 AND g.claim_by < $2`, my_enums.GiftPurchased, claimBy)
+}
+
+func (g *GiftDB) GetGiftsNeedingReminder(claimBy time.Time) ([]models.Gift, error) {
+	return g.getGifts(
+		` WHERE g.status=$1 AND g.claim_by < $2 AND g.reminder_sent=FALSE`, 
+		my_enums.GiftPurchased, claimBy,
+	)
+}
+
+func (g *GiftDB) SetGiftPaymentIntent(giftId int, pmiStripeId string) (error) {
+	_, err := g.DB.Exec(`UPDATE gift SET stripe_pmi_id=$1 WHERE gift_id=$2`, pmiStripeId, giftId)
+	return err
+}
+
+// SetGiftPurchased only moves pending gifts forward so repeated webhooks are no-ops
+func (g *GiftDB) SetGiftPurchased(giftId int) (bool, error) {
+	res, err := g.DB.Exec(`UPDATE gift SET status=$1 WHERE gift_id=$2 AND status=$3`, 
+		my_enums.GiftPurchased, giftId, my_enums.GiftPendingPayment,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ClaimGift marks a purchased gift as claimed by sub's customer and creates the gift
+// subscription in the same transaction. Gift subscriptions have no card or stripe sub
+// behind them, they are cancelled from the start so they simply lapse at expires.
+// Returns false if someone else got there first, ErrPlanFull if the plan has no spot left
+func (g *GiftDB) ClaimGift(giftId int, sub *models.Subscription) (bool, error) {
+	tx, err := g.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE gift SET status=$1, recipient_id=$2, claimed_date=$3 
+		WHERE gift_id=$4 AND status=$5`, 
+		my_enums.GiftClaimed, sub.CustomerID, time.Now(), giftId, my_enums.GiftPurchased,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return false, err
+	}
+
+	if err := lockPlanCapacity(tx, sub.PlanID, sub.CustomerID); err != nil {
+		return false, err
+	}
+
+	err = tx.QueryRow(`INSERT into subscription 
+		(customer_id, plan_id, start_date, cancelled, cancelled_date, expires) 
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING sub_id`, 
+		sub.CustomerID, sub.PlanID, sub.StartDate, true, sub.StartDate, sub.Expires,
+	).Scan(&sub.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`UPDATE gift SET sub_id=$1 WHERE gift_id=$2`, sub.ID, giftId); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (g *GiftDB) SetGiftRefunded(giftId int) (error) {
+	_, err := g.DB.Exec(`UPDATE gift SET status=$1 WHERE gift_id=$2`, my_enums.GiftRefunded, giftId)
+	return err
+}
+
+func (g *GiftDB) SetGiftReminderSent(giftId int) (error) {
+	_, err := g.DB.Exec(`UPDATE gift SET reminder_sent=TRUE WHERE gift_id=$1`, giftId)
+	return err
+}
+
+func (g *GiftDB) DeleteGift(giftId int) (error) {
+	_, err := g.DB.Exec(`DELETE FROM gift WHERE gift_id=$1`, giftId)
+	return err
+}
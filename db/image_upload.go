@@ -0,0 +1,227 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/lib/pq"
+)
+
+type ImageDB struct {
+	DB *sql.DB
+}
+
+func (i *ImageDB) InsertImageUpload(upload models.ImageUpload) (error) {
+	query := `INSERT into image_upload 
+		(upload_id, business_id, target, product_id, content_type, size, staging_key, status, expires, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())`
+
+	_, err := i.DB.Exec(query,
+		upload.UploadID, upload.BusinessID, upload.Target, upload.ProductID, upload.ContentType,
+		upload.Size, upload.StagingKey, upload.Status, upload.Expires,
+	)
+	return err
+}
+
+const imageUploadColumns = `upload_id, business_id, target, product_id, content_type, size, staging_key, status, expires`
+
+// GetImageUpload only finds uploads started by businessId
+func (i *ImageDB) GetImageUpload(uploadId string, businessId int) (*models.ImageUpload, error) {
+	query := `SELECT ` + imageUploadColumns + ` FROM image_upload WHERE upload_id=$1 AND business_id=$2`
+	return scanImageUpload(i.DB.QueryRow(query, uploadId, businessId))
+}
+
+// ClaimImageUpload moves a pending upload started by businessId to processing so only
+// one confirm goes ahead, ErrNoRows means it isn't there or isn't pending any more
+func (i *ImageDB) ClaimImageUpload(uploadId string, businessId int) (*models.ImageUpload, error) {
+	query := `UPDATE image_upload SET status=$3 
+		WHERE upload_id=$1 AND business_id=$2 AND status=$4 
+		RETURNING ` + imageUploadColumns
+
+	return scanImageUpload(i.DB.QueryRow(query, 
+		uploadId, businessId, models.ImageUploadProcessing, models.ImageUploadPending,
+	))
+}
+
+func scanImageUpload(row interface{ Scan(dest ...interface{}) error }) (*models.ImageUpload, error) {
+	var upload models.ImageUpload
+	err := row.Scan(
+		&upload.UploadID,
+		&upload.BusinessID,
+		&upload.Target,
+		&upload.ProductID,
+		&upload.ContentType,
+		&upload.Size,
+		&upload.StagingKey,
+		&upload.Status,
+		&upload.Expires,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (i *ImageDB) SetImageUploadStatus(uploadId string, status models.ImageUploadStatus) (error) {
+	_, err := i.DB.Exec(`UPDATE image_upload SET status=$2 WHERE upload_id=$1`, uploadId, status)
+	return err
+}
+
+// SetBusinessImages returns the variants it replaced, if any
+func (i *ImageDB) SetBusinessImages(businessId int, images models.ImageVariants) (*models.ImageVariants, error) {
+	query := `UPDATE business as b SET images=$2 
+		FROM business as old WHERE b.business_id=$1 AND old.business_id=b.business_id
+		RETURNING old.images`
+
+	var prev *models.ImageVariants
+	err := i.DB.QueryRow(query, businessId, images).Scan(&prev)
+	if err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+// SetProductImages returns sql.ErrNoRows if the product isn't the business's
+func (i *ImageDB) SetProductImages(
+	businessId int,
+	productId int,
+	images models.ImageVariants,
+) (*models.ImageVariants, error) {
+	query := `UPDATE product as p SET images=$3 
+		FROM product as old WHERE p.product_id=$2 AND p.business_id=$1 AND old.product_id=p.product_id
+		RETURNING old.images`
+
+	var prev *models.ImageVariants
+	err := i.DB.QueryRow(query, businessId, productId, images).Scan(&prev)
+	if err != nil {
+		return nil, err
+	}
+	return prev, nil
+}
+
+func (i *ImageDB) GetProductImages(productId int) (*models.ImageVariants, error) {
+	var images *models.ImageVariants
+	err := i.DB.QueryRow(`SELECT images FROM product WHERE product_id=$1`, productId).Scan(&images)
+	return images, err
+}
+
+const productImageColumns = `image_id, product_id, position, alt_text, cover, images`
+
+func scanProductImage(row interface{ Scan(dest ...interface{}) error }) (models.ProductImage, error) {
+	var img models.ProductImage
+	err := row.Scan(&img.ImageID, &img.ProductID, &img.Position, &img.AltText, &img.Cover, &img.Images)
+	return img, err
+}
+
+// InsertProductImage adds an image to the end of a product's gallery. The first image
+// in a gallery becomes its cover
+func (i *ImageDB) InsertProductImage(
+	productId int,
+	images models.ImageVariants,
+	altText string,
+) (*models.ProductImage, error) {
+	query := `INSERT into product_image (product_id, position, alt_text, cover, images, created)
+		SELECT $1, COALESCE(MAX(position) + 1, 0), $2, COUNT(*) = 0, $3, now()
+		FROM product_image WHERE product_id=$1
+		RETURNING ` + productImageColumns
+
+	img, err := scanProductImage(i.DB.QueryRow(query, productId, altText, images))
+	if err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+func (i *ImageDB) GetProductGallery(productId int) ([]models.ProductImage, error) {
+	galleries, err := i.GetProductGalleries([]int{productId})
+	if err != nil {
+		return nil, err
+	}
+	return galleries[productId], nil
+}
+
+// GetProductGalleries returns the ordered gallery of each product, keyed by product id
+func (i *ImageDB) GetProductGalleries(productIds []int) (map[int][]models.ProductImage, error) {
+	query := `SELECT ` + productImageColumns + ` FROM product_image 
+		WHERE product_id=ANY($1) ORDER BY product_id, position`
+
+	rows, err := i.DB.Query(query, pq.Array(productIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	galleries := map[int][]models.ProductImage{}
+	for rows.Next() {
+		img, err := scanProductImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		galleries[img.ProductID] = append(galleries[img.ProductID], img)
+	}
+	return galleries, rows.Err()
+}
+
+// SetProductImageAlt returns sql.ErrNoRows if the image isn't in the product's gallery
+func (i *ImageDB) SetProductImageAlt(productId int, imageId int, altText string) (error) {
+	res, err := i.DB.Exec(`UPDATE product_image SET alt_text=$3 WHERE product_id=$1 AND image_id=$2`,
+		productId, imageId, altText)
+	if err != nil {
+		return err
+	}
+	return noRowsIfUnaffected(res)
+}
+
+// SetProductGalleryOrder positions each image by its index in imageIds, which
+// should hold the whole gallery
+func (i *ImageDB) SetProductGalleryOrder(productId int, imageIds []int) (error) {
+	query := `UPDATE product_image SET position=array_position($2::int[], image_id) - 1
+		WHERE product_id=$1 AND image_id=ANY($2)`
+
+	_, err := i.DB.Exec(query, productId, pq.Array(imageIds))
+	return err
+}
+
+// SetProductCover returns sql.ErrNoRows if the image isn't in the product's gallery
+func (i *ImageDB) SetProductCover(productId int, imageId int) (error) {
+	query := `UPDATE product_image SET cover=(image_id=$2) 
+		WHERE product_id=$1 AND EXISTS (
+			SELECT 1 FROM product_image WHERE product_id=$1 AND image_id=$2
+		)`
+
+	res, err := i.DB.Exec(query, productId, imageId)
+	if err != nil {
+		return err
+	}
+	return noRowsIfUnaffected(res)
+}
+
+// DeleteProductImage returns the removed image. If it was the cover, the first
+// image left in the gallery takes over
+func (i *ImageDB) DeleteProductImage(productId int, imageId int) (*models.ProductImage, error) {
+	query := `DELETE FROM product_image WHERE product_id=$1 AND image_id=$2 
+		RETURNING ` + productImageColumns
+
+	img, err := scanProductImage(i.DB.QueryRow(query, productId, imageId))
+	if err != nil {
+		return nil, err
+	}
+
+	if img.Cover {
+		_, err = i.DB.Exec(`UPDATE product_image SET cover=TRUE WHERE image_id=(
+			SELECT image_id FROM product_image WHERE product_id=$1 ORDER BY position LIMIT 1
+		)`, productId)
+	}
+	return &img, err
+}
+
+func noRowsIfUnaffected(res sql.Result) (error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
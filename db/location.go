@@ -0,0 +1,111 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+// distances use the earthdistance extension, business has a gist index on
+// ll_to_earth(latitude, longitude) so earth_box can use it
+
+const (
+	// when ranking by distance a business this far away counts half as much
+	distanceDecayM = 5000
+	// businesses without a location rank as if they were this far away
+	unlocatedDistanceM = 100000
+)
+
+// distanceJoin adds d.distance, the metres from the point in params latParam and
+// lngParam to b. It is null when no point is given
+func distanceJoin(latParam int, lngParam int) (string) {
+	return fmt.Sprintf(`CROSS JOIN LATERAL (SELECT CASE
+		WHEN $%[1]d::float8 IS NULL THEN NULL
+		WHEN b.latitude IS NULL THEN %[3]d
+		ELSE earth_distance(ll_to_earth($%[1]d, $%[2]d), ll_to_earth(b.latitude, b.longitude))
+	END as distance) as d`, latParam, lngParam, unlocatedDistanceM)
+}
+
+// distanceFactor scales a score down the further away b is, 1 with no point
+var distanceFactor = fmt.Sprintf(`(1 / (1 + COALESCE(d.distance, 0) / %d))`, distanceDecayM)
+
+// knownDistance is d.distance for businesses that have a location
+const knownDistance = `CASE WHEN b.latitude IS NULL THEN NULL ELSE d.distance END`
+
+// nearArgs is the latitude and longitude params for distanceJoin, nil if near is
+func nearArgs(near *models.Location) (interface{}, interface{}) {
+	if near == nil {
+		return nil, nil
+	}
+	return near.Latitude, near.Longitude
+}
+
+// GetNearbyBusinesses returns located businesses matching q, closest first
+func (b *BusinessDB) GetNearbyBusinesses(
+	q models.GeoQuery,
+	offset int,
+	limit int,
+) ([]models.Business, error) {
+	args := []interface{}{q.Center.Latitude, q.Center.Longitude}
+
+	where := `earth_box(ll_to_earth($1, $2), $3) @> ll_to_earth(b.latitude, b.longitude)
+		AND d.distance <= $3`
+	args = append(args, q.RadiusM)
+	if q.Box != nil {
+		where = `b.latitude BETWEEN $3 AND $4 AND b.longitude BETWEEN $5 AND $6`
+		args = []interface{}{
+			q.Center.Latitude, q.Center.Longitude,
+			q.Box.Min.Latitude, q.Box.Max.Latitude, q.Box.Min.Longitude, q.Box.Max.Longitude,
+		}
+	}
+
+	query := fmt.Sprintf(`SELECT 
+	b.business_id, b.name, b.business_category, b.description, b.business_url, b.images,
+	COALESCE(b.address_line1, ''), COALESCE(b.address_line2, ''), COALESCE(b.postal_code, ''), 
+	COALESCE(b.city, ''), b.country, b.latitude, b.longitude, d.distance
+	FROM business as b
+	%s
+	WHERE b.latitude IS NOT NULL AND %s
+	ORDER BY d.distance ASC, b.business_id ASC
+	OFFSET %d LIMIT %d`, distanceJoin(1, 2), where, offset, limit)
+
+	rows, err := b.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	businesses := []models.Business{}
+	for rows.Next() {
+		var business models.Business
+		var address models.Address
+		var location models.Location
+		var distance float64
+
+		if err := rows.Scan(
+			&business.ID,
+			&business.Name,
+			&business.BusinessCategory,
+			&business.Description,
+			&business.BusinessUrl,
+			&business.Images,
+			&address.Line1,
+			&address.Line2,
+			&address.PostalCode,
+			&address.City,
+			&address.Country,
+			&location.Latitude,
+			&location.Longitude,
+			&distance,
+		); err != nil {
+			return nil, err
+		}
+
+		business.Address = &address
+		business.Location = &location
+		business.DistanceM = &distance
+		businesses = append(businesses, business)
+	}
+
+	return businesses, rows.Err()
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ApplePass is a customer's wallet pass, either their membership card or, when SubID
+// is set, the pass for one subscription. Updated is bumped whenever anything shown
+// on the pass changes so devices know to fetch it again
+type ApplePass struct {
+	SerialNumber 	string 		`json:"serial_number"`
+	CustomerID 		int 		`json:"customer_id"`
+	SubID 			*int 		`json:"sub_id"`
+	AuthToken 		string 		`json:"-"`
+	Updated 		time.Time 	`json:"updated"`
+}
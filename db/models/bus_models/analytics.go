@@ -0,0 +1,74 @@
+package bus_models
+
+import "time"
+
+type AnalyticsSub struct {
+	SubID			int 		`json:"sub_id"`
+	CustomerID		int 		`json:"customer_id"`
+	ProductID		int 		`json:"product_id"`
+	PlanID			int 		`json:"plan_id"`
+	Start			time.Time 	`json:"start"`
+	End				*time.Time 	`json:"end"`
+	MonthlyAmount	int 		`json:"monthly_amount"`
+	Gift			bool 		`json:"gift"`
+}
+
+type AnalyticsInvoice struct {
+	CustomerID		int 		`json:"customer_id"`
+	Total			int 		`json:"total"`
+	Created			time.Time 	`json:"created"`
+}
+
+type RevenuePoint struct {
+	PeriodStart			time.Time 	`json:"period_start"`
+	MRR					int 		`json:"mrr"`
+	ARR					int 		`json:"arr"`
+	ActiveSubscribers	int 		`json:"active_subscribers"`
+	NewSubscribers		int 		`json:"new_subscribers"`
+	ChurnedSubscribers	int 		`json:"churned_subscribers"`
+	Revenue				int 		`json:"revenue"`
+	ARPU				int 		`json:"arpu"`
+}
+
+type RevenueSummary struct {
+	MRR					int 		`json:"mrr"`
+	ARR					int 		`json:"arr"`
+	ARPU				int 		`json:"arpu"`
+	ActiveSubscribers	int 		`json:"active_subscribers"`
+	NewSubscribers		int 		`json:"new_subscribers"`
+	ChurnedSubscribers	int 		`json:"churned_subscribers"`
+	ChurnRate			float64 	`json:"churn_rate"`
+	NetRevenueRetention	*float64 	`json:"net_revenue_retention"`
+	Revenue				int 		`json:"revenue"`
+}
+
+type Cohort struct {
+	Month		time.Time 	`json:"month"`
+	Size		int 		`json:"size"`
+	Retention	[]float64 	`json:"retention"`
+}
+
+// UsageHeatmap is redemptions by [weekday][hour] in the business's time zone, monday first
+type UsageHeatmap [7][24]int
+
+type UsageUtilisation struct {
+	SubUsageID		int 		`json:"sub_usage_id"`
+	ProductID		int 		`json:"product_id"`
+	ProductName		string 		`json:"product_name"`
+	Title			string 		`json:"title"`
+	Unlimited		bool 		`json:"unlimited"`
+	Interval		string 		`json:"interval"`
+	Amount			int 		`json:"amount"`
+	Redeemed		int 		`json:"redeemed"`
+	Allowed			*int 		`json:"allowed"`
+	Utilisation		*float64 	`json:"utilisation"`
+}
+
+type SubscriberActivity struct {
+	CustomerID		int 		`json:"customer_id"`
+	FirstName		string 		`json:"first_name"`
+	LastName		string 		`json:"last_name"`
+	Email			string 		`json:"email"`
+	Redemptions		int 		`json:"redemptions"`
+	LastUsed		*time.Time 	`json:"last_used"`
+}
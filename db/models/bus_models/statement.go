@@ -0,0 +1,48 @@
+package bus_models
+
+import (
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+// Statement is a business's month, amounts are in minor units and balance is
+// what's been earned but not yet paid out
+type Statement struct {
+	ID					int 					`json:"statement_id"`
+	BusinessID			int 					`json:"business_id"`
+	Month				time.Time 				`json:"month"`
+	Currency			string 					`json:"currency"`
+	Gross				int 					`json:"gross"`
+	Fees				int 					`json:"fees"`
+	Refunds				int 					`json:"refunds"`
+	Payouts				int 					`json:"payouts"`
+	OpeningBalance		int 					`json:"opening_balance"`
+	ClosingBalance		int 					`json:"closing_balance"`
+	S3Key				string 					`json:"-"`
+	Created				time.Time 				`json:"created"`
+	EmailedAt			models.JsonNullTime 	`json:"emailed_at"`
+}
+
+type StatementTotals struct {
+	Gross				int
+	Fees				int
+	Refunds				int
+	Payouts				int
+}
+
+type StatementProduct struct {
+	ProductID			int 		`json:"product_id"`
+	Name				string 		`json:"name"`
+	Payments			int 		`json:"payments"`
+	Gross				int 		`json:"gross"`
+	Fees				int 		`json:"fees"`
+}
+
+type Refund struct {
+	StripeRefundID		string 		`json:"stripe_refund_id"`
+	BusinessID			int 		`json:"business_id"`
+	Amount				int 		`json:"amount"`
+	Currency			string 		`json:"currency"`
+	Created				time.Time 	`json:"created"`
+}
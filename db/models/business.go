@@ -14,6 +14,12 @@ type Business struct {
 	EmailVerified 	*bool 	`json:"email_verified"`
 	ExternalAccountID JsonNullInt16 	`json:"external_account_id"`
 	ExternalAccountType JsonNullString 	`json:"external_account_type"`
+	Images 			*ImageVariants 	`json:"images,omitempty"`
+	Address 		*Address 		`json:"address,omitempty"`
+	Location 		*Location 		`json:"location,omitempty"`
+	// metres from wherever the customer searched from
+	DistanceM 		*float64 		`json:"distance_m,omitempty"`
+	Rating 			*RatingSummary 	`json:"rating,omitempty"`
 }
 
 type BankAccount struct {
@@ -18,8 +18,10 @@ type UsageInfo struct {
 	Created 		time.Time	`json:"created"`
 
 	PlanID			int			`json:"plan_id"`
+	SubID			int			`json:"sub_id"`
 	SubUsage		SubUsage	`json:"sub_usage"`
 	ProductID 		int 		`json:"product_id"`
 	ProductName 	string 		`json:"product_name"`
+	BusinessName 	string 		`json:"business_name,omitempty"`
 	UsageCount		int			`json:"usage_count"`	
 }
\ No newline at end of file
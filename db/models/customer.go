@@ -63,6 +63,18 @@ type Invoice struct {
 type ExploreResult struct {
 	Business 	Business			`json:"business"`
 	SubProduct 	SubscriptionProduct	`json:"sub_product"`
+	// only set on search results, highlight marks matches with <b></b>
+	Highlight 	string 				`json:"highlight,omitempty"`
+	Rank 		float64 			`json:"rank,omitempty"`
+	// only set on personalised explore results
+	Reason 		RecommendationReason 	`json:"reason,omitempty"`
+}
+
+// AccountResult is a business found by search
+type AccountResult struct {
+	Business
+	Highlight 	string 		`json:"highlight"`
+	Rank 		float64 	`json:"rank"`
 }
 
 
@@ -72,4 +84,13 @@ type CusAddress struct {
 	PostalCode 		JsonNullString 		`json:"postal_code"`
 	City 			JsonNullString 		`json:"city"`
 	Country 		JsonNullString 		`json:"country"`
-}
\ No newline at end of file
+}
+// CusDevice is an app install that can receive push notifications
+type CusDevice struct {
+	ID 				int 			`json:"device_id"`
+	Token 			string 			`json:"fcm_token"`
+	Platform 		string 			`json:"platform"`
+	AppVersion 		string 			`json:"app_version"`
+	Created 		time.Time 		`json:"created"`
+	LastSeen 		time.Time 		`json:"last_seen"`
+}
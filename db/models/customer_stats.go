@@ -8,6 +8,7 @@ type CusUsage struct {
 	CusUUID			string 			`json:"customer_uuid"`
 	Created 		time.Time 		`json:"created"`
 	SubUsageID 		int 			`json:"sub_usage_id"`
+	SubID			int 			`json:"sub_id"`
 	
 
 	// FOR CUSTOMER
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+)
+
+// DunningSettings are in days from the first failed payment
+type DunningSettings struct {
+	BusinessID			int 		`json:"business_id"`
+	ReminderDays		[]int64 	`json:"reminder_days"`
+	SuspendAfterDays	int 		`json:"suspend_after_days"`
+	CancelAfterDays		int 		`json:"cancel_after_days"`
+}
+
+type DunningCase struct {
+	ID 				int 					`json:"dunning_id"`
+	SubID			int 					`json:"sub_id"`
+	InStripeID		string 					`json:"stripe_in_id"`
+	Amount			int 					`json:"amount"`
+	Status			my_enums.DunningStatus 	`json:"status"`
+	Started			time.Time 				`json:"started"`
+	RemindersSent	int 					`json:"reminders_sent"`
+	LastReminder	JsonNullTime 			`json:"last_reminder"`
+	Suspended		bool 					`json:"suspended"`
+	Resolved		JsonNullTime 			`json:"resolved"`
+
+	// additional for display
+	CustomerID		*int 					`json:"customer_id"`
+	CusEmail		*string 				`json:"customer_email"`
+	CusFirstName	*string 				`json:"customer_first_name"`
+	BusinessID		*int 					`json:"business_id"`
+	BusinessName	*string 				`json:"business_name"`
+	ProductName		*string 				`json:"product_name"`
+	UpdateCardLink	string 					`json:"update_card_link"`
+}
+
+type DunningStats struct {
+	RecoveredRevenue	int 	`json:"recovered_revenue"`
+	RecoveredCount		int 	`json:"recovered_count"`
+	OpenAmount			int 	`json:"open_amount"`
+	OpenCount			int 	`json:"open_count"`
+	LostAmount			int 	`json:"lost_amount"`
+	LostCount			int 	`json:"lost_count"`
+}
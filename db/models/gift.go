@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+)
+
+type Gift struct {
+	ID 				int 					`json:"gift_id"`
+	Code			string 					`json:"code"`
+	PurchaserID		int 					`json:"purchaser_id"`
+	RecipientEmail	string 					`json:"recipient_email"`
+	RecipientID		JsonNullInt64 			`json:"recipient_id"`
+	PlanID			int 					`json:"plan_id"`
+	Periods			int 					`json:"periods"`
+	Amount			int 					`json:"amount"`
+	Currency		string 					`json:"currency"`
+	Message			JsonNullString 			`json:"message"`
+	StripePMIID		JsonNullString 			`json:"stripe_pmi_id"`
+	Status			my_enums.GiftStatus 	`json:"status"`
+	Created			time.Time 				`json:"created"`
+	ClaimBy			time.Time 				`json:"claim_by"`
+	ClaimedDate		JsonNullTime 			`json:"claimed_date"`
+	SubID			JsonNullInt64 			`json:"sub_id"`
+	ReminderSent	bool 					`json:"reminder_sent"`
+
+	// additional for display
+	PurchaserName	*string 				`json:"purchaser_name"`
+	BusinessName	*string 				`json:"business_name"`
+	SubProduct		*SubscriptionProduct 	`json:"sub_product"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type ImageUploadStatus string
+
+const (
+	ImageUploadPending		ImageUploadStatus = "pending"
+	ImageUploadProcessing	ImageUploadStatus = "processing"
+	ImageUploadConfirmed	ImageUploadStatus = "confirmed"
+	ImageUploadFailed		ImageUploadStatus = "failed"
+)
+
+// ImageTarget is what an uploaded image is for
+type ImageTarget string
+
+const (
+	ImageTargetBusiness		ImageTarget = "business_profile"
+	ImageTargetProduct		ImageTarget = "product"
+	ImageTargetGallery		ImageTarget = "product_gallery"
+)
+
+// ImageVariants are the resized copies of an image, stored as jsonb. In the db they
+// hold object keys, in responses they're swapped for presigned urls
+type ImageVariants struct {
+	Thumbnail 	string 	`json:"thumbnail"`
+	Medium 		string 	`json:"medium"`
+	Large 		string 	`json:"large"`
+}
+
+func (v ImageVariants) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+func (v *ImageVariants) Scan(src interface{}) (error) {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("image variants must be jsonb")
+	}
+	return json.Unmarshal(b, v)
+}
+
+type ImageUpload struct {
+	UploadID 		string 				`json:"upload_id"`
+	BusinessID 		int 				`json:"business_id"`
+	Target 			ImageTarget 		`json:"target"`
+	ProductID 		*int 				`json:"product_id"`
+	ContentType 	string 				`json:"content_type"`
+	Size 			int64 				`json:"size"`
+	StagingKey 		string 				`json:"-"`
+	Status 			ImageUploadStatus 	`json:"status"`
+	Expires 		time.Time 			`json:"expires"`
+}
+
+// ProductImage is one image in a product's gallery. Exactly one image in a gallery
+// is the cover
+type ProductImage struct {
+	ImageID 		int 			`json:"image_id"`
+	ProductID 		int 			`json:"product_id"`
+	Position 		int 			`json:"position"`
+	AltText 		string 			`json:"alt_text"`
+	Cover 			bool 			`json:"cover"`
+	Images 			ImageVariants 	`json:"images"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// Location is a point in degrees
+type Location struct {
+	Latitude 	float64 	`json:"latitude"`
+	Longitude 	float64 	`json:"longitude"`
+}
+
+func (l Location) Valid() (bool) {
+	return l.Latitude >= -90 && l.Latitude <= 90 && l.Longitude >= -180 && l.Longitude <= 180
+}
+
+// BoundingBox is the area between two corners, it doesn't wrap the antimeridian
+type BoundingBox struct {
+	Min 	Location
+	Max 	Location
+}
+
+func (b BoundingBox) Center() (Location) {
+	return Location{
+		Latitude: (b.Min.Latitude + b.Max.Latitude) / 2,
+		Longitude: (b.Min.Longitude + b.Max.Longitude) / 2,
+	}
+}
+
+// GeoQuery finds businesses either within RadiusM metres of Center or inside Box,
+// closest to Center first
+type GeoQuery struct {
+	Center 		Location
+	RadiusM 	float64
+	Box 		*BoundingBox
+}
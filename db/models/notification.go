@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+)
+
+type NotificationPreference struct {
+	Event 	my_enums.NotificationEvent 	`json:"event"`
+	Push 	bool 						`json:"push"`
+	Email 	bool 						`json:"email"`
+	SMS 	bool 						`json:"sms"`
+}
+
+// NotificationSettings quiet hours are minutes after local midnight, and may wrap
+// past midnight (eg. 1320 to 420 for 22:00 to 07:00)
+type NotificationSettings struct {
+	QuietStart 	*int 		`json:"quiet_start"`
+	QuietEnd 	*int 		`json:"quiet_end"`
+	TimeZone 	string 		`json:"time_zone"`
+	Mobile 		*string 	`json:"mobile"`
+}
+
+type NotificationLog struct {
+	ID 			int 							`json:"notification_id"`
+	CustomerID 	int 							`json:"customer_id"`
+	Event 		my_enums.NotificationEvent 		`json:"event"`
+	Channel 	my_enums.NotificationChannel 	`json:"channel"`
+	Status 		my_enums.NotificationStatus 	`json:"status"`
+	Title 		string 							`json:"title"`
+	Body 		string 							`json:"body"`
+	Data 		map[string]string 				`json:"data"`
+	Error 		JsonNullString 					`json:"error"`
+	Created 	time.Time 						`json:"created"`
+	SendAfter 	JsonNullTime 					`json:"send_after"`
+	Sent 		JsonNullTime 					`json:"sent"`
+}
+
+// Notification is an entry in a customer's or business's in-app inbox
+type Notification struct {
+	ID 			int 							`json:"notification_id"`
+	Event 		my_enums.NotificationEvent 		`json:"event"`
+	Title 		string 							`json:"title"`
+	Body 		string 							`json:"body"`
+	Data 		map[string]string 				`json:"data"`
+	ReadAt 		JsonNullTime 					`json:"read_at"`
+	Created 	time.Time 						`json:"created"`
+}
+
+// Recipient owns an inbox, exactly one of the ids is set
+type Recipient struct {
+	CustomerID 	*int
+	BusinessID 	*int
+}
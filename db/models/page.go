@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Cursor marks where the previous page ended, clients only ever see it encoded
+type Cursor struct {
+	Created		time.Time	`json:"t,omitempty"`
+	ID			int			`json:"id,omitempty"`
+	Offset		int			`json:"o,omitempty"`
+}
+
+type PageParams struct {
+	Limit		int
+	After		*Cursor
+}
+
+// ListFilter is a half open [From, To) range plus optional status and product
+type ListFilter struct {
+	From		*time.Time
+	To			*time.Time
+	Status		string
+	ProductID	*int
+}
+
+type Page struct {
+	Items		interface{}		`json:"items"`
+	NextCursor	*string			`json:"next_cursor"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// RecommendationReason is the signal that contributed most to a recommendation
+type RecommendationReason string
+
+const (
+	RecommendedSubscribedTogether	RecommendationReason = "subscribed_together"
+	RecommendedCategory				RecommendationReason = "category"
+	RecommendedPopular				RecommendationReason = "popular"
+)
+
+// SubSignal is one product a customer has an active subscription to, as owner or
+// member, with how often they've used it recently
+type SubSignal struct {
+	CustomerID 			int
+	ProductID 			int
+	BusinessCategory 	string
+	UsageCount 			int
+}
+
+// ProductProfile is what the recommender knows about a product it could suggest
+type ProductProfile struct {
+	ProductID 			int
+	BusinessID 			int
+	BusinessCategory 	string
+	SubCount 			int
+}
+
+type Recommendation struct {
+	CustomerID 	int
+	ProductID 	int
+	Score 		float64
+	Reason 		RecommendationReason
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+)
+
+// Review is a subscriber's rating of a product, with the business's public reply
+type Review struct {
+	ID 				int 					`json:"review_id"`
+	ProductID 		int 					`json:"product_id"`
+	BusinessID 		int 					`json:"business_id"`
+	Rating 			int 					`json:"rating"`
+	Body 			string 					`json:"body"`
+	Status 			my_enums.ReviewStatus 	`json:"status"`
+	FlagCount 		int 					`json:"flag_count"`
+	Reply 			JsonNullString 			`json:"reply"`
+	Replied 		JsonNullTime 			`json:"replied"`
+	Created 		time.Time 				`json:"created"`
+	Updated 		time.Time 				`json:"updated"`
+
+	// additional for display
+	CusFirstName 	string 					`json:"customer_first_name"`
+	ProductName 	string 					`json:"product_name"`
+}
+
+// RatingSummary is the average star rating over Count visible reviews, 0 if there are none
+type RatingSummary struct {
+	Average 	float64 	`json:"average"`
+	Count 		int 		`json:"count"`
+}
+
+// SearchSort is how search results are ordered
+type SearchSort string
+
+const (
+	SortRelevance	SearchSort = "relevance"
+	SortRating		SearchSort = "rating"
+)
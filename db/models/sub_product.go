@@ -16,6 +16,9 @@ type Product struct {
 	StripeProductID	*string	`json:"stripe_product_id"`
 	SubCount		*int	`json:"sub_count"`
 	CatTitle		*string `json:"category_title"`
+	Images			*ImageVariants `json:"images,omitempty"`
+	Gallery			[]ProductImage `json:"gallery,omitempty"`
+	Rating			*RatingSummary `json:"rating,omitempty"`
 }
 
 type SubscriptionPlan struct {
@@ -26,6 +29,9 @@ type SubscriptionPlan struct {
 	Currency			string			`json:"currency"`
 	StripePriceID 		*string 		`json:"stripe_price_id"`
 	Usages				*[]SubUsage		`json:"usages"`
+	Seats				*int 			`json:"seats"`
+	SharedUsage			*bool 			`json:"shared_usage"`
+	MaxSubscribers		*int 			`json:"max_subscribers"`
 }
 
 type SubUsage struct {
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/stripe/stripe-go/v74"
 )
 
@@ -36,4 +37,49 @@ type ResumeSubReturn struct {
 	Status			stripe.PaymentIntentStatus 		`json:"status"`
 	PaymentIntent 	*stripe.PaymentIntent 			`json:"payment_intent"`
 	LastInvoice 	*Invoice 						`json:"last_invoice"`
-}
\ No newline at end of file
+}
+type CancellationSurvey struct {
+	SubID		int 							`json:"sub_id"`
+	Reason		my_enums.CancellationReason 	`json:"reason"`
+	Comment		JsonNullString 					`json:"comment"`
+	Created		time.Time 						`json:"created"`
+}
+
+type ChurnReason struct {
+	Reason			my_enums.CancellationReason 	`json:"reason"`
+	Count			int 							`json:"count"`
+	ProductID		int 							`json:"product_id"`
+	ProductName		string 							`json:"product_name"`
+}
+
+
+type SubMember struct {
+	ID 				int 						`json:"member_id"`
+	SubID			int 						`json:"sub_id"`
+	CustomerID		JsonNullInt64 				`json:"customer_id"`
+	Email			string 						`json:"email"`
+	Status			my_enums.SubMemberStatus 	`json:"status"`
+	Invited			time.Time 					`json:"invited"`
+	Joined			JsonNullTime 				`json:"joined"`
+
+	// additional for display
+	FirstName		JsonNullString 				`json:"first_name"`
+	LastName		JsonNullString 				`json:"last_name"`
+	OwnerID			*int 						`json:"owner_id"`
+	OwnerName		*string 					`json:"owner_name"`
+	ProductName		*string 					`json:"product_name"`
+	BusinessName	*string 					`json:"business_name"`
+}
+
+// SubContact is who to email about a subscription and what to call it
+type SubContact struct {
+	SubID			int 			`json:"sub_id"`
+	CustomerID		int 			`json:"customer_id"`
+	CusEmail		string 			`json:"email"`
+	CusFirstName	string 			`json:"first_name"`
+	CusLastName		string 			`json:"last_name"`
+	CardID			JsonNullInt64 	`json:"card_id"`
+	ProductName		string 			`json:"product_name"`
+	BusinessID		int 			`json:"business_id"`
+	BusinessName	string 			`json:"business_name"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+)
+
+type WaitlistEntry struct {
+	ID 				int 						`json:"waitlist_id"`
+	PlanID			int 						`json:"plan_id"`
+	CustomerID		int 						`json:"customer_id"`
+	Status			my_enums.WaitlistStatus 	`json:"status"`
+	Created			time.Time 					`json:"created"`
+	Invited			JsonNullTime 				`json:"invited"`
+	ClaimBy			JsonNullTime 				`json:"claim_by"`
+
+	// additional for display
+	Position		*int 						`json:"position"`
+	CusEmail		*string 					`json:"customer_email"`
+	CusFirstName	*string 					`json:"customer_first_name"`
+	ProductID		*int 						`json:"product_id"`
+	ProductName		*string 					`json:"product_name"`
+	BusinessName	*string 					`json:"business_name"`
+}
+
+type PlanCapacity struct {
+	PlanID			int 		`json:"plan_id"`
+	ProductID		int 		`json:"product_id"`
+	ProductName		string 		`json:"product_name"`
+	MaxSubscribers	int 		`json:"max_subscribers"`
+	Subscribers		int 		`json:"subscribers"`
+	HeldSpots		int 		`json:"held_spots"`
+	Waiting			int 		`json:"waiting"`
+}
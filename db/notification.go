@@ -0,0 +1,278 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+type NotificationDB struct {
+	DB *sql.DB
+}
+
+// GetNotificationPreferences returns only the events the customer has changed
+func (n *NotificationDB) GetNotificationPreferences(cusId int) ([]models.NotificationPreference, error) {
+	query := `SELECT event, push, email, sms FROM notification_preference WHERE customer_id=$1`
+
+	rows, err := n.DB.Query(query, cusId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := []models.NotificationPreference{}
+	for rows.Next() {
+		var p models.NotificationPreference
+		if err := rows.Scan(&p.Event, &p.Push, &p.Email, &p.SMS); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+	return prefs, rows.Err()
+}
+
+func (n *NotificationDB) GetNotificationPreference(
+	cusId int,
+	event my_enums.NotificationEvent,
+) (*models.NotificationPreference, error) {
+	query := `SELECT event, push, email, sms FROM notification_preference
+		WHERE customer_id=$1 AND event=$2`
+
+	var p models.NotificationPreference
+	err := n.DB.QueryRow(query, cusId, event).Scan(&p.Event, &p.Push, &p.Email, &p.SMS)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (n *NotificationDB) UpsertNotificationPreference(cusId int, pref models.NotificationPreference) (error) {
+	query := `INSERT into notification_preference (customer_id, event, push, email, sms)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (customer_id, event) DO UPDATE SET push=$3, email=$4, sms=$5`
+
+	_, err := n.DB.Exec(query, cusId, pref.Event, pref.Push, pref.Email, pref.SMS)
+	return err
+}
+
+func (n *NotificationDB) GetNotificationSettings(cusId int) (*models.NotificationSettings, error) {
+	query := `SELECT quiet_start, quiet_end, time_zone, mobile FROM notification_settings
+		WHERE customer_id=$1`
+
+	var s models.NotificationSettings
+	err := n.DB.QueryRow(query, cusId).Scan(&s.QuietStart, &s.QuietEnd, &s.TimeZone, &s.Mobile)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (n *NotificationDB) UpsertNotificationSettings(cusId int, settings models.NotificationSettings) (error) {
+	query := `INSERT into notification_settings (customer_id, quiet_start, quiet_end, time_zone, mobile)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (customer_id) DO UPDATE SET quiet_start=$2, quiet_end=$3, time_zone=$4, mobile=$5`
+
+	_, err := n.DB.Exec(
+		query, cusId, settings.QuietStart, settings.QuietEnd, settings.TimeZone, settings.Mobile,
+	)
+	return err
+}
+
+func (n *NotificationDB) InsertNotificationLog(l models.NotificationLog) (int, error) {
+	data, err := json.Marshal(l.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT into notification_log
+		(customer_id, event, channel, status, title, body, data, error, created, send_after, sent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING notification_id`
+
+	var id int
+	err = n.DB.QueryRow(
+		query, l.CustomerID, l.Event, l.Channel, l.Status, l.Title, l.Body, data,
+		l.Error, l.Created, l.SendAfter, l.Sent,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (n *NotificationDB) SetNotificationLogStatus(
+	notificationId int,
+	status my_enums.NotificationStatus,
+	errMsg sql.NullString,
+) (error) {
+	query := `UPDATE notification_log SET status=$2, error=$3,
+		sent=CASE WHEN $2=$5 THEN $4::timestamptz ELSE sent END
+		WHERE notification_id=$1`
+
+	_, err := n.DB.Exec(query, notificationId, status, errMsg, time.Now(), my_enums.NotificationSent)
+	return err
+}
+
+// ClaimDueHeldNotifications moves held notifications whose quiet hours have ended to
+// sending and returns them, so another instance running the same job can't pick them up too
+func (n *NotificationDB) ClaimDueHeldNotifications(now time.Time) ([]models.NotificationLog, error) {
+	query := `UPDATE notification_log SET status=$1
+		WHERE notification_id IN (
+			SELECT notification_id FROM notification_log WHERE status=$2 AND send_after <= $3
+			FOR UPDATE SKIP LOCKED
+		) RETURNING notification_id, customer_id, event, channel, status, title, body, data, created, send_after`
+
+	rows, err := n.DB.Query(query, my_enums.NotificationSending, my_enums.NotificationHeld, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []models.NotificationLog{}
+	for rows.Next() {
+		var l models.NotificationLog
+		var data []byte
+		if err := rows.Scan(
+			&l.ID, &l.CustomerID, &l.Event, &l.Channel, &l.Status,
+			&l.Title, &l.Body, &data, &l.Created, &l.SendAfter,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(data, &l.Data); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
+}
+
+func recipientCond(q *pagination.Query, r models.Recipient) {
+	if r.CustomerID != nil {
+		q.Where("customer_id=?", *r.CustomerID)
+	} else {
+		q.Where("business_id=?", *r.BusinessID)
+	}
+}
+
+func (n *NotificationDB) InsertNotification(r models.Recipient, notification models.Notification) (int, error) {
+	data, err := json.Marshal(notification.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `INSERT into notifications (customer_id, business_id, event, title, body, data, created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING notification_id`
+
+	var id int
+	err = n.DB.QueryRow(
+		query, r.CustomerID, r.BusinessID, notification.Event,
+		notification.Title, notification.Body, data, notification.Created,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListNotifications pages newest first on (created, id)
+func (n *NotificationDB) ListNotifications(
+	r models.Recipient,
+	unreadOnly bool,
+	page *models.PageParams,
+) ([]models.Notification, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	recipientCond(q, r)
+	if unreadOnly {
+		q.Where("read_at IS NULL")
+	}
+	q.After(page.After, "created", "notification_id")
+
+	stmt := fmt.Sprintf(`SELECT notification_id, event, title, body, data, read_at, created
+		FROM notifications
+		WHERE %s
+		ORDER BY created DESC, notification_id DESC
+		LIMIT %d`, q.String(), page.Limit + 1)
+
+	rows, err := n.DB.Query(stmt, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	var next *models.Cursor
+	for rows.Next() {
+		if len(notifications) == page.Limit {
+			last := notifications[len(notifications) - 1]
+			next = &models.Cursor{Created: last.Created, ID: last.ID}
+			break
+		}
+
+		var nt models.Notification
+		var data []byte
+		if err := rows.Scan(
+			&nt.ID, &nt.Event, &nt.Title, &nt.Body, &data, &nt.ReadAt, &nt.Created,
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if err := json.Unmarshal(data, &nt.Data); err != nil {
+			return nil, nil, err
+		}
+		notifications = append(notifications, nt)
+	}
+
+	return notifications, next, rows.Err()
+}
+
+func (n *NotificationDB) CountUnreadNotifications(r models.Recipient) (int, error) {
+	q := pagination.NewQuery()
+	recipientCond(q, r)
+	q.Where("read_at IS NULL")
+
+	var count int
+	err := n.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE ` + q.String(), q.Args...).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead returns sql.ErrNoRows if the notification isn't the recipient's
+func (n *NotificationDB) MarkNotificationRead(r models.Recipient, notificationId int) (error) {
+	q := pagination.NewQuery()
+	q.Where("notification_id=?", notificationId)
+	recipientCond(q, r)
+
+	args := append(q.Args, time.Now())
+	stmt := fmt.Sprintf(`UPDATE notifications SET read_at=COALESCE(read_at, $%d) WHERE %s`,
+		len(args), q.String())
+
+	res, err := n.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (n *NotificationDB) MarkAllNotificationsRead(r models.Recipient) (error) {
+	q := pagination.NewQuery()
+	recipientCond(q, r)
+	q.Where("read_at IS NULL")
+
+	args := append(q.Args, time.Now())
+	stmt := fmt.Sprintf(`UPDATE notifications SET read_at=$%d WHERE %s`, len(args), q.String())
+
+	_, err := n.DB.Exec(stmt, args...)
+	return err
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/lib/pq"
+)
+
+type RecommendationDB struct {
+	DB *sql.DB
+}
+
+// GetSubSignals returns every active subscription, owned or shared, with how many
+// times the customer has used it since usageSince
+func (r *RecommendationDB) GetSubSignals(usageSince time.Time) ([]models.SubSignal, error) {
+	query := `
+	WITH sub_customer AS (
+		SELECT s.sub_id, s.plan_id, s.customer_id FROM subscription as s
+		WHERE s.cancelled=FALSE OR s.expires > now()
+		UNION
+		SELECT s.sub_id, s.plan_id, sm.customer_id FROM subscription_member as sm
+		JOIN subscription as s ON s.sub_id=sm.sub_id
+		WHERE sm.status=$1 AND (s.cancelled=FALSE OR s.expires > now())
+	)
+	SELECT sc.customer_id, p.product_id, COALESCE(b.business_category, ''), COUNT(cu.usage_id)
+	FROM sub_customer as sc
+	JOIN customer as c ON c.customer_id=sc.customer_id
+	JOIN subscription_plan as sp ON sp.plan_id=sc.plan_id
+	JOIN product as p ON p.product_id=sp.product_id
+	JOIN business as b ON b.business_id=p.business_id
+	LEFT JOIN customer_usage as cu ON cu.sub_id=sc.sub_id AND cu.customer_uuid=c.uuid AND cu.created > $2
+	GROUP BY sc.customer_id, p.product_id, b.business_category
+	`
+
+	rows, err := r.DB.Query(query, my_enums.SubMemberActive, usageSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	signals := []models.SubSignal{}
+	for rows.Next() {
+		var s models.SubSignal
+		if err := rows.Scan(&s.CustomerID, &s.ProductID, &s.BusinessCategory, &s.UsageCount); err != nil {
+			return nil, err
+		}
+		signals = append(signals, s)
+	}
+	return signals, rows.Err()
+}
+
+// GetProductProfiles returns every product that can be subscribed to with its
+// number of active subscribers
+func (r *RecommendationDB) GetProductProfiles() ([]models.ProductProfile, error) {
+	query := `SELECT p.product_id, b.business_id, COALESCE(b.business_category, ''),
+	` + planSubscriberCount + `
+	FROM product as p
+	JOIN business as b ON b.business_id=p.business_id
+	JOIN subscription_plan as sp ON sp.product_id=p.product_id`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.ProductProfile{}
+	for rows.Next() {
+		var p models.ProductProfile
+		if err := rows.Scan(&p.ProductID, &p.BusinessID, &p.BusinessCategory, &p.SubCount); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
+
+// ReplaceRecommendations swaps every customer's recommendations for recs in one go,
+// so explore never sees a half written set
+func (r *RecommendationDB) ReplaceRecommendations(recs []models.Recommendation) (error) {
+	cusIds := make([]int64, len(recs))
+	productIds := make([]int64, len(recs))
+	scores := make([]float64, len(recs))
+	reasons := make([]string, len(recs))
+	for i, rec := range recs {
+		cusIds[i] = int64(rec.CustomerID)
+		productIds[i] = int64(rec.ProductID)
+		scores[i] = rec.Score
+		reasons[i] = string(rec.Reason)
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM recommendation`); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT into recommendation (customer_id, product_id, score, reason, computed)
+		SELECT unnest($1::int[]), unnest($2::int[]), unnest($3::float8[]), unnest($4::text[]), now()`,
+		pq.Array(cusIds), pq.Array(productIds), pq.Array(scores), pq.Array(reasons),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// recommendedRanked ranks each business's products recommended to the customer in
+// param cusParam, explore shows the ones ranked 1
+func recommendedRanked(cusParam int) (string) {
+	return fmt.Sprintf(`SELECT r.product_id, r.score, r.reason, p.business_id, ROW_NUMBER() OVER
+		(PARTITION BY p.business_id ORDER BY r.score DESC, r.product_id ASC) as rank
+		FROM recommendation as r
+		JOIN product as p ON p.product_id=r.product_id
+		WHERE r.customer_id=$%d`, cusParam)
+}
+
+func (r *RecommendationDB) HasRecommendations(cusId int) (bool, error) {
+	var exists bool
+	err := r.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM recommendation WHERE customer_id=$1)`,
+		cusId,
+	).Scan(&exists)
+	return exists, err
+}
+
+// CountRecommendedProducts is how many results GetRecommendedProducts has in total
+func (r *RecommendationDB) CountRecommendedProducts(cusId int) (int, error) {
+	query := `WITH ranked AS (` + recommendedRanked(1) + `)
+	SELECT COUNT(*) FROM ranked as r
+	JOIN product as p ON p.product_id=r.product_id
+	JOIN subscription_plan as sp ON sp.product_id=p.product_id
+	JOIN product_category as pc ON p.category_id=pc.category_id
+	WHERE r.rank = 1`
+
+	var count int
+	err := r.DB.QueryRow(query, cusId).Scan(&count)
+	return count, err
+}
+
+// GetRecommendedProducts is the explore feed for cusId, the best recommended product
+// of each business ranked by score. With near, closer businesses rank higher
+func (r *RecommendationDB) GetRecommendedProducts(
+	cusId int,
+	offset int,
+	limit int,
+	near *models.Location,
+) ([]models.ExploreResult, error) {
+	query := fmt.Sprintf(`
+	WITH ranked AS (%s)
+
+	SELECT
+	b.business_id, b.name, b.business_category, b.description, b.business_url, b.images,
+	p.product_id, p.name, p.description, p.images,
+	sp.plan_id, recurring_interval, recurring_interval_count, unit_amount, %s,
+	pc.title, %s, r.reason,
+	%s,
+	%s
+	FROM ranked as r
+	JOIN business as b ON b.business_id=r.business_id
+	JOIN product as p ON p.product_id=r.product_id
+	JOIN subscription_plan as sp ON sp.product_id=p.product_id
+	JOIN product_category as pc ON p.category_id=pc.category_id
+	%s
+	WHERE r.rank = 1
+	ORDER BY r.score * %s DESC, p.product_id ASC
+	OFFSET %d LIMIT %d
+	`, recommendedRanked(3), planSubscriberCount, knownDistance, businessRating, productRating,
+		distanceJoin(1, 2), distanceFactor, offset, limit)
+
+	lat, lng := nearArgs(near)
+	rows, err := r.DB.Query(query, lat, lng, cusId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.ExploreResult{}
+	for rows.Next() {
+		product := models.Product{Rating: &models.RatingSummary{}}
+		business := models.Business{Rating: &models.RatingSummary{}}
+		var plan models.SubscriptionPlan
+		var reason models.RecommendationReason
+
+		if err := rows.Scan(
+			&business.ID,
+			&business.Name,
+			&business.BusinessCategory,
+			&business.Description,
+			&business.BusinessUrl,
+			&business.Images,
+			&product.ProductID,
+			&product.Name,
+			&product.Description,
+			&product.Images,
+			&plan.PlanID,
+			&plan.RecurringDuration.Interval,
+			&plan.RecurringDuration.IntervalCount,
+			&plan.UnitAmount,
+			&product.SubCount,
+			&product.CatTitle,
+			&business.DistanceM,
+			&reason,
+			&business.Rating.Average,
+			&business.Rating.Count,
+			&product.Rating.Average,
+			&product.Rating.Count,
+		); err != nil {
+			return nil, err
+		}
+
+		results = append(results, models.ExploreResult{
+			Business: business,
+			SubProduct: models.SubscriptionProduct{
+				Product: product,
+				SubPlan: plan,
+			},
+			Reason: reason,
+		})
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,299 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/pagination"
+)
+
+// ReviewDB keeps one review per customer per product. business_id is copied from
+// the product so a business's reviews can be read without joining through it
+type ReviewDB struct {
+	DB *sql.DB
+}
+
+// ratingColumns selects the average and count of public reviews matching match,
+// named <name> and <name>_count so results can be ordered by them
+func ratingColumns(match string, name string) (string) {
+	return fmt.Sprintf(`(SELECT COALESCE(AVG(rv.rating), 0)::float8 FROM review as rv
+		WHERE %[1]s AND rv.status!='%[3]s') as %[2]s,
+	(SELECT COUNT(*) FROM review as rv WHERE %[1]s AND rv.status!='%[3]s') as %[2]s_count`,
+		match, name, my_enums.ReviewHidden)
+}
+
+// businessRating and productRating are the rating columns for queries over b and p
+var businessRating = ratingColumns("rv.business_id=b.business_id", "business_rating")
+var productRating = ratingColumns("rv.product_id=p.product_id", "product_rating")
+
+const reviewColumns = `r.review_id, r.product_id, r.business_id, r.rating, r.body, r.status,
+	r.flag_count, r.reply, r.replied, r.created, r.updated, c.first_name, p.name`
+
+const reviewJoins = `FROM review as r
+	JOIN customer as c ON c.customer_id=r.customer_id
+	JOIN product as p ON p.product_id=r.product_id`
+
+func scanReview(row interface{ Scan(...interface{}) error }) (*models.Review, error) {
+	var r models.Review
+	err := row.Scan(
+		&r.ID,
+		&r.ProductID,
+		&r.BusinessID,
+		&r.Rating,
+		&r.Body,
+		&r.Status,
+		&r.FlagCount,
+		&r.Reply,
+		&r.Replied,
+		&r.Created,
+		&r.Updated,
+		&r.CusFirstName,
+		&r.ProductName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetReviewEligibility returns the product's business and whether the customer has
+// ever subscribed to it, as owner or member. sql.ErrNoRows if there's no product
+func (r *ReviewDB) GetReviewEligibility(cusId int, productId int) (int, bool, error) {
+	query := `SELECT p.business_id, EXISTS (
+		SELECT 1 FROM subscription as s
+		JOIN subscription_plan as sp ON sp.plan_id=s.plan_id
+		WHERE sp.product_id=p.product_id AND s.customer_id=$2
+		UNION
+		SELECT 1 FROM subscription_member as sm
+		JOIN subscription as s ON s.sub_id=sm.sub_id
+		JOIN subscription_plan as sp ON sp.plan_id=s.plan_id
+		WHERE sp.product_id=p.product_id AND sm.customer_id=$2 AND sm.status IN ($3, $4)
+	)
+	FROM product as p WHERE p.product_id=$1`
+
+	var businessId int
+	var subscribed bool
+	err := r.DB.QueryRow(query, productId, cusId, my_enums.SubMemberActive, my_enums.SubMemberRemoved,
+	).Scan(&businessId, &subscribed)
+	return businessId, subscribed, err
+}
+
+// UpsertReview creates the customer's review of the product or rewrites it. Flags
+// and moderation stay with the review across edits
+func (r *ReviewDB) UpsertReview(
+	cusId int,
+	productId int,
+	businessId int,
+	rating int,
+	body string,
+) (*models.Review, error) {
+	query := `INSERT into review
+		(product_id, business_id, customer_id, rating, body, status, flag_count, created, updated)
+		VALUES ($1, $2, $3, $4, $5, $6, 0, now(), now())
+		ON CONFLICT (product_id, customer_id) DO UPDATE SET rating=$4, body=$5, updated=now()
+		RETURNING review_id`
+
+	var reviewId int
+	err := r.DB.QueryRow(query, productId, businessId, cusId, rating, body, my_enums.ReviewVisible,
+	).Scan(&reviewId)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetReview(reviewId)
+}
+
+func (r *ReviewDB) GetReview(reviewId int) (*models.Review, error) {
+	query := `SELECT ` + reviewColumns + ` ` + reviewJoins + ` WHERE r.review_id=$1`
+	return scanReview(r.DB.QueryRow(query, reviewId))
+}
+
+// GetReviewAuthor returns who wrote the review and which product and business it's for
+func (r *ReviewDB) GetReviewAuthor(reviewId int) (int, int, int, error) {
+	var cusId, productId, businessId int
+	err := r.DB.QueryRow(`SELECT customer_id, product_id, business_id FROM review WHERE review_id=$1`,
+		reviewId,
+	).Scan(&cusId, &productId, &businessId)
+	return cusId, productId, businessId, err
+}
+
+// DeleteReview only deletes reviews written by cusId
+func (r *ReviewDB) DeleteReview(reviewId int, cusId int) (error) {
+	if _, err := r.DB.Exec(`DELETE FROM review_flag WHERE review_id IN
+		(SELECT review_id FROM review WHERE review_id=$1 AND customer_id=$2)`, reviewId, cusId,
+	); err != nil {
+		return err
+	}
+
+	res, err := r.DB.Exec(`DELETE FROM review WHERE review_id=$1 AND customer_id=$2`, reviewId, cusId)
+	if err != nil {
+		return err
+	}
+	return noRowsIfUnaffected(res)
+}
+
+// GetCustomerReviews returns everything the customer has written, hidden included
+func (r *ReviewDB) GetCustomerReviews(cusId int) ([]models.Review, error) {
+	query := `SELECT ` + reviewColumns + ` ` + reviewJoins + `
+		WHERE r.customer_id=$1 ORDER BY r.created DESC, r.review_id DESC`
+
+	rows, err := r.DB.Query(query, cusId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *review)
+	}
+	return reviews, rows.Err()
+}
+
+// ListReviews pages newest first through a product's reviews, or a business's when
+// productId is nil. Hidden reviews are left out when visibleOnly
+func (r *ReviewDB) ListReviews(
+	businessId *int,
+	productId *int,
+	visibleOnly bool,
+	page *models.PageParams,
+) ([]models.Review, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	if businessId != nil {
+		q.Where("r.business_id=?", *businessId)
+	}
+	if productId != nil {
+		q.Where("r.product_id=?", *productId)
+	}
+	if visibleOnly {
+		q.Where("r.status!=?", my_enums.ReviewHidden)
+	}
+	return r.listReviews(q, page)
+}
+
+// ListFlaggedReviews pages newest first through reviews waiting on a moderator
+func (r *ReviewDB) ListFlaggedReviews(page *models.PageParams) ([]models.Review, *models.Cursor, error) {
+	q := pagination.NewQuery()
+	q.Where("r.status=?", my_enums.ReviewFlagged)
+	return r.listReviews(q, page)
+}
+
+func (r *ReviewDB) listReviews(
+	q *pagination.Query,
+	page *models.PageParams,
+) ([]models.Review, *models.Cursor, error) {
+	q.After(page.After, "r.created", "r.review_id")
+
+	query := fmt.Sprintf(`SELECT %s %s
+		WHERE %s
+		ORDER BY r.created DESC, r.review_id DESC
+		LIMIT %d`, reviewColumns, reviewJoins, q.String(), page.Limit + 1)
+
+	rows, err := r.DB.Query(query, q.Args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.Review{}
+	var next *models.Cursor
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(reviews) == page.Limit {
+			last := reviews[len(reviews) - 1]
+			next = &models.Cursor{Created: last.Created, ID: last.ID}
+			break
+		}
+		reviews = append(reviews, *review)
+	}
+	return reviews, next, rows.Err()
+}
+
+// GetRatingSummary is the rating over the public reviews ListReviews would return
+func (r *ReviewDB) GetRatingSummary(businessId *int, productId *int) (*models.RatingSummary, error) {
+	q := pagination.NewQuery()
+	if businessId != nil {
+		q.Where("business_id=?", *businessId)
+	}
+	if productId != nil {
+		q.Where("product_id=?", *productId)
+	}
+	q.Where("status!=?", my_enums.ReviewHidden)
+
+	query := `SELECT COALESCE(AVG(rating), 0)::float8, COUNT(*) FROM review WHERE ` + q.String()
+
+	var summary models.RatingSummary
+	err := r.DB.QueryRow(query, q.Args...).Scan(&summary.Average, &summary.Count)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// SetReviewReply sets or, with a nil reply, removes the business's public reply.
+// sql.ErrNoRows if the review isn't one of the business's
+func (r *ReviewDB) SetReviewReply(reviewId int, businessId int, reply *string) (error) {
+	res, err := r.DB.Exec(`UPDATE review SET reply=$1,
+		replied=CASE WHEN $1::text IS NULL THEN NULL ELSE now() END
+		WHERE review_id=$2 AND business_id=$3`, reply, reviewId, businessId)
+	if err != nil {
+		return err
+	}
+	return noRowsIfUnaffected(res)
+}
+
+// FlagReview records a flag, once per flagger. Flags that count add to flag_count and
+// send a visible review to moderation when it reaches moderateAt
+func (r *ReviewDB) FlagReview(
+	reviewId int,
+	flaggerType string,
+	flaggerId int,
+	reason my_enums.ReviewFlagReason,
+	counts bool,
+	moderateAt int,
+) (error) {
+	query := `WITH flag AS (
+		INSERT into review_flag (review_id, flagger_type, flagger_id, reason, created)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (review_id, flagger_type, flagger_id) DO NOTHING
+		RETURNING review_id
+	)
+	UPDATE review SET flag_count=flag_count+1,
+		status=CASE WHEN flag_count+1 >= $6 AND status=$7 THEN $8 ELSE status END
+	WHERE review_id IN (SELECT review_id FROM flag) AND $5::boolean`
+
+	_, err := r.DB.Exec(query, reviewId, flaggerType, flaggerId, reason, counts, moderateAt,
+		my_enums.ReviewVisible, my_enums.ReviewFlagged)
+	return err
+}
+
+// HideReview takes a review down. sql.ErrNoRows if there's no such review
+func (r *ReviewDB) HideReview(reviewId int) (error) {
+	res, err := r.DB.Exec(`UPDATE review SET status=$1 WHERE review_id=$2`, 
+		my_enums.ReviewHidden, reviewId)
+	if err != nil {
+		return err
+	}
+	return noRowsIfUnaffected(res)
+}
+
+// RestoreReview makes a flagged or hidden review visible again. Its flags are kept so
+// the same people can't flag it again, only new flags count towards moderating it again
+func (r *ReviewDB) RestoreReview(reviewId int) (error) {
+	res, err := r.DB.Exec(`UPDATE review SET status=$1, flag_count=0 WHERE review_id=$2`, 
+		my_enums.ReviewVisible, reviewId)
+	if err != nil {
+		return err
+	}
+	return noRowsIfUnaffected(res)
+}
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+// SearchDB keeps the explore search index. product_search and business_search hold
+// a weighted tsvector (gin indexed) and a plain label for fuzzy matching (gin
+// gin_trgm_ops indexed, needs pg_trgm). Rows are rebuilt from the source tables
+// whenever a product or business changes
+type SearchDB struct {
+	DB *sql.DB
+}
+
+// the order fields count in a product's relevance, A highest
+const productDocument = `
+	setweight(to_tsvector('english', p.name), 'A') ||
+	setweight(to_tsvector('english', COALESCE(pc.title, '')), 'B') ||
+	setweight(to_tsvector('english', b.name), 'C') ||
+	setweight(to_tsvector('english', COALESCE(p.description, '')), 'D')`
+
+const businessDocument = `
+	setweight(to_tsvector('english', b.name), 'A') ||
+	setweight(to_tsvector('english', COALESCE(b.business_category, '')), 'B') ||
+	setweight(to_tsvector('english', COALESCE(string_agg(p.name || ' ' || COALESCE(pc.title, ''), ' '), '')), 'C') ||
+	setweight(to_tsvector('english', COALESCE(b.description, '')), 'D')`
+
+// ts_headline returns the source text as is, so matches are marked with private use
+// characters and only turned into <b> tags once the text has been escaped
+const (
+	highlightStart = "\uE000"
+	highlightStop = "\uE001"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=20, MinWords=5`,
+	highlightStart, highlightStop)
+
+var highlightReplacer = strings.NewReplacer(highlightStart, "<b>", highlightStop, "</b>")
+
+// highlightHTML escapes a ts_headline result for html and bolds its matches
+func highlightHTML(headline string) (string) {
+	return highlightReplacer.Replace(html.EscapeString(headline))
+}
+
+// word_similarity above this counts as a fuzzy match, <% uses it through
+// pg_trgm.word_similarity_threshold so the trigram index can serve fuzzy matches
+const fuzzyThreshold = 0.4
+
+// queryFuzzy runs query in a read only transaction with the word similarity threshold
+// set to fuzzyThreshold. The transaction has to outlive rows, close rows first
+func (s *SearchDB) queryFuzzy(query string, args ...interface{}) (*sql.Tx, *sql.Rows, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = tx.Exec(`SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)`, 
+		fmt.Sprint(fuzzyThreshold))
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	return tx, rows, nil
+}
+
+func (s *SearchDB) indexProducts(where string, args ...interface{}) (error) {
+	query := fmt.Sprintf(`INSERT into product_search (product_id, business_id, document, label, updated)
+		SELECT p.product_id, p.business_id, %s,
+		p.name || ' ' || COALESCE(pc.title, '') || ' ' || b.name, now()
+		FROM product as p
+		JOIN business as b ON b.business_id=p.business_id
+		LEFT JOIN product_category as pc ON pc.category_id=p.category_id
+		WHERE %s
+		ON CONFLICT (product_id) DO UPDATE SET 
+		business_id=EXCLUDED.business_id, document=EXCLUDED.document, 
+		label=EXCLUDED.label, updated=EXCLUDED.updated`, productDocument, where)
+
+	_, err := s.DB.Exec(query, args...)
+	return err
+}
+
+func (s *SearchDB) indexBusinesses(where string, args ...interface{}) (error) {
+	query := fmt.Sprintf(`INSERT into business_search (business_id, document, label, updated)
+		SELECT b.business_id, %s, b.name, now()
+		FROM business as b
+		LEFT JOIN product as p ON p.business_id=b.business_id
+		LEFT JOIN product_category as pc ON pc.category_id=p.category_id
+		WHERE %s
+		GROUP BY b.business_id
+		ON CONFLICT (business_id) DO UPDATE SET 
+		document=EXCLUDED.document, label=EXCLUDED.label, updated=EXCLUDED.updated`, businessDocument, where)
+
+	_, err := s.DB.Exec(query, args...)
+	return err
+}
+
+// IndexProduct reindexes a product and its business, whose document includes it
+func (s *SearchDB) IndexProduct(productId int) (error) {
+	if err := s.indexProducts(`p.product_id=$1`, productId); err != nil {
+		return err
+	}
+	return s.indexBusinesses(
+		`b.business_id=(SELECT business_id FROM product WHERE product_id=$1)`, productId)
+}
+
+// IndexBusiness reindexes a business and all its products, which include its name
+func (s *SearchDB) IndexBusiness(businessId int) (error) {
+	if err := s.indexProducts(`p.business_id=$1`, businessId); err != nil {
+		return err
+	}
+	return s.indexBusinesses(`b.business_id=$1`, businessId)
+}
+
+// RemoveProduct drops a deleted product and reindexes its business
+func (s *SearchDB) RemoveProduct(productId int, businessId int) (error) {
+	_, err := s.DB.Exec(`DELETE FROM product_search WHERE product_id=$1`, productId)
+	if err != nil {
+		return err
+	}
+	return s.indexBusinesses(`b.business_id=$1`, businessId)
+}
+
+// RebuildIndex reindexes everything and drops rows whose source is gone
+func (s *SearchDB) RebuildIndex() (error) {
+	if err := s.indexProducts(`TRUE`); err != nil {
+		return err
+	}
+	if err := s.indexBusinesses(`TRUE`); err != nil {
+		return err
+	}
+
+	_, err := s.DB.Exec(`DELETE FROM product_search as ps 
+		WHERE NOT EXISTS (SELECT 1 FROM product WHERE product_id=ps.product_id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`DELETE FROM business_search as bs 
+		WHERE NOT EXISTS (SELECT 1 FROM business WHERE business_id=bs.business_id)`)
+	return err
+}
+
+// searchOrder is the ORDER BY for sort. By rating, the best rated come first with
+// relevance breaking ties, unrated results last
+func searchOrder(sort models.SearchSort, rating string, tieBreak string) (string) {
+	if sort == models.SortRating {
+		return fmt.Sprintf(`%[1]s DESC, %[1]s_count DESC, rank DESC, %[2]s ASC`, rating, tieBreak)
+	}
+	return fmt.Sprintf(`rank DESC, %s ASC`, tieBreak)
+}
+
+// SearchSubProducts matches q as a web style query against the index, falling back
+// to fuzzy matching on names so typos still find something. Results are ranked by
+// relevance, and by distance with near, with the matching text highlighted
+func (s *SearchDB) SearchSubProducts(
+	q string,
+	near *models.Location,
+	sort models.SearchSort,
+	offset int,
+	limit int,
+) ([]models.ExploreResult, error) {
+	query := fmt.Sprintf(`
+	WITH search AS (SELECT websearch_to_tsquery('english', $1) as tsq)
+	SELECT 
+	b.business_id, b.name, b.business_category, b.description, b.business_url, b.images,
+	p.product_id, p.name, p.description, p.images, pc.title,
+	sp.plan_id, recurring_interval, recurring_interval_count, unit_amount,
+	ts_headline('english', p.name || '. ' || COALESCE(p.description, ''), search.tsq, '%s'),
+	(ts_rank_cd(ps.document, search.tsq) + word_similarity($1, ps.label)) * %s as rank,
+	%s,
+	%s,
+	%s
+
+	FROM product_search as ps
+	CROSS JOIN search
+	JOIN product as p ON p.product_id=ps.product_id
+	JOIN subscription_plan as sp ON sp.product_id=p.product_id
+	JOIN product_category as pc ON p.category_id=pc.category_id
+	JOIN business as b ON b.business_id=p.business_id
+	%s
+
+	WHERE ps.document @@ search.tsq OR $1 <%% ps.label
+	ORDER BY %s
+	OFFSET %d LIMIT %d`, headlineOptions, distanceFactor, knownDistance, businessRating, productRating,
+		distanceJoin(2, 3), searchOrder(sort, "product_rating", "p.product_id"), offset, limit)
+
+	lat, lng := nearArgs(near)
+	tx, rows, err := s.queryFuzzy(query, q, lat, lng)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	defer rows.Close()
+
+	results := []models.ExploreResult{}
+	for rows.Next() {
+		product := models.Product{Rating: &models.RatingSummary{}}
+		business := models.Business{Rating: &models.RatingSummary{}}
+		var plan models.SubscriptionPlan
+		var result models.ExploreResult
+
+		if err := rows.Scan(
+			&business.ID,
+			&business.Name,
+			&business.BusinessCategory,
+			&business.Description,
+			&business.BusinessUrl,
+			&business.Images,
+			&product.ProductID,
+			&product.Name,
+			&product.Description,
+			&product.Images,
+			&product.CatTitle,
+			&plan.PlanID,
+			&plan.RecurringDuration.Interval,
+			&plan.RecurringDuration.IntervalCount,
+			&plan.UnitAmount,
+			&result.Highlight,
+			&result.Rank,
+			&business.DistanceM,
+			&business.Rating.Average,
+			&business.Rating.Count,
+			&product.Rating.Average,
+			&product.Rating.Count,
+		); err != nil {
+			return nil, err
+		}
+
+		result.Highlight = highlightHTML(result.Highlight)
+		result.Business = business
+		result.SubProduct = models.SubscriptionProduct{
+			Product: product,
+			SubPlan: plan,
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func (s *SearchDB) SearchAccounts(
+	q string,
+	sort models.SearchSort,
+	offset int,
+	limit int,
+) ([]models.AccountResult, error) {
+	query := fmt.Sprintf(`
+	WITH search AS (SELECT websearch_to_tsquery('english', $1) as tsq)
+	SELECT b.business_id, b.name, b.business_category, b.description, b.business_url, b.images,
+	ts_headline('english', b.name || '. ' || COALESCE(b.description, ''), search.tsq, '%s'),
+	ts_rank_cd(bs.document, search.tsq) + word_similarity($1, bs.label) as rank,
+	%s
+
+	FROM business_search as bs
+	CROSS JOIN search
+	JOIN business as b ON b.business_id=bs.business_id
+
+	WHERE bs.document @@ search.tsq OR $1 <%% bs.label
+	ORDER BY %s
+	OFFSET %d LIMIT %d`, headlineOptions, businessRating,
+		searchOrder(sort, "business_rating", "b.business_id"), offset, limit)
+
+	tx, rows, err := s.queryFuzzy(query, q)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	defer rows.Close()
+
+	accounts := []models.AccountResult{}
+	for rows.Next() {
+		account := models.AccountResult{Business: models.Business{Rating: &models.RatingSummary{}}}
+
+		if err := rows.Scan(
+			&account.ID,
+			&account.Name,
+			&account.BusinessCategory,
+			&account.Description,
+			&account.BusinessUrl,
+			&account.Images,
+			&account.Highlight,
+			&account.Rank,
+			&account.Rating.Average,
+			&account.Rating.Count,
+		); err != nil {
+			return nil, err
+		}
+
+		account.Highlight = highlightHTML(account.Highlight)
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
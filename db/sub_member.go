@@ -0,0 +1,203 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+var ErrAlreadyMember = errors.New("already a member of this subscription")
+
+// InsertSubMember invites email to the subscription, or re-invites them if they left or
+// were removed. The subscription is locked while seats are counted so concurrent invites
+// can't both take the last one. The owner takes up one seat so sql.ErrNoRows is returned
+// when the plan is full, ErrAlreadyMember if email has already joined
+func (s *SubscriptionDB) InsertSubMember(subId int, email string, cusId models.JsonNullInt64) (*int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var seats int
+	err = tx.QueryRow(`SELECT COALESCE(sp.seats, 1) FROM subscription as s 
+		JOIN subscription_plan as sp on sp.plan_id=s.plan_id
+		WHERE s.sub_id=$1 FOR UPDATE OF s`, subId,
+	).Scan(&seats)
+	if err != nil {
+		return nil, err
+	}
+
+	var status my_enums.SubMemberStatus
+	err = tx.QueryRow(`SELECT status FROM subscription_member WHERE sub_id=$1 AND email=$2`,
+		subId, email,
+	).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	} else if status == my_enums.SubMemberActive {
+		return nil, ErrAlreadyMember
+	}
+
+	// an invite being resent already holds its seat
+	var taken int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM subscription_member 
+		WHERE sub_id=$1 AND email!=$2 AND (status=$3 OR status=$4)`,
+		subId, email, my_enums.SubMemberInvited, my_enums.SubMemberActive,
+	).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+
+	if taken >= seats - 1 {
+		return nil, sql.ErrNoRows
+	}
+
+	var memberId int
+	err = tx.QueryRow(`INSERT into subscription_member (sub_id, email, customer_id, status, invited)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (sub_id, email) DO UPDATE SET status=$4, invited=$5, joined=NULL
+		WHERE subscription_member.status!=$6
+		RETURNING member_id`, 
+		subId, email, cusId, my_enums.SubMemberInvited, time.Now(), my_enums.SubMemberActive,
+	).Scan(&memberId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &memberId, tx.Commit()
+}
+
+func (s *SubscriptionDB) GetSubMembers(subId int) ([]models.SubMember, error) {
+	stmt := `SELECT 
+	sm.member_id, sm.sub_id, sm.customer_id, sm.email, sm.status, sm.invited, sm.joined,
+	c.first_name, c.last_name
+	FROM subscription_member as sm
+	LEFT JOIN customer as c on c.customer_id=sm.customer_id
+	WHERE sm.sub_id=$1 AND sm.status!=$2
+	ORDER BY sm.invited ASC`
+
+	rows, err := s.DB.Query(stmt, subId, my_enums.SubMemberRemoved)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []models.SubMember{}
+	for rows.Next() {
+		var m models.SubMember
+		if err := rows.Scan(
+			&m.ID, &m.SubID, &m.CustomerID, &m.Email, &m.Status, &m.Invited, &m.Joined,
+			&m.FirstName, &m.LastName,
+		); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, nil
+}
+
+func (s *SubscriptionDB) GetSubMember(memberId int) (*models.SubMember, error) {
+	stmt := `SELECT 
+	sm.member_id, sm.sub_id, sm.customer_id, sm.email, sm.status, sm.invited, sm.joined,
+	s.customer_id
+	FROM subscription_member as sm
+	JOIN subscription as s on s.sub_id=sm.sub_id
+	WHERE sm.member_id=$1`
+
+	var m models.SubMember
+	err := s.DB.QueryRow(stmt, memberId).Scan(
+		&m.ID, &m.SubID, &m.CustomerID, &m.Email, &m.Status, &m.Invited, &m.Joined,
+		&m.OwnerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (s *SubscriptionDB) GetCusSubInvitations(email string) ([]models.SubMember, error) {
+	stmt := `SELECT 
+	sm.member_id, sm.sub_id, sm.email, sm.status, sm.invited,
+	s.customer_id, c.first_name, p.name, b.name
+	FROM subscription_member as sm
+	JOIN subscription as s on s.sub_id=sm.sub_id
+	JOIN customer as c on c.customer_id=s.customer_id
+	JOIN subscription_plan as sp on sp.plan_id=s.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	JOIN business as b on b.business_id=p.business_id
+	WHERE sm.email=$1 AND sm.status=$2
+	ORDER BY sm.invited DESC`
+
+	rows, err := s.DB.Query(stmt, email, my_enums.SubMemberInvited)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	invitations := []models.SubMember{}
+	for rows.Next() {
+		var m models.SubMember
+		if err := rows.Scan(
+			&m.ID, &m.SubID, &m.Email, &m.Status, &m.Invited,
+			&m.OwnerID, &m.OwnerName, &m.ProductName, &m.BusinessName,
+		); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, m)
+	}
+
+	return invitations, nil
+}
+
+func (s *SubscriptionDB) AcceptSubMember(memberId int, cusId int) (error) {
+	stmt := `UPDATE subscription_member SET customer_id=$1, status=$2, joined=$3 WHERE member_id=$4`
+	_, err := s.DB.Exec(stmt, cusId, my_enums.SubMemberActive, time.Now(), memberId)
+	return err
+}
+
+func (s *SubscriptionDB) RemoveSubMember(memberId int) (error) {
+	stmt := `UPDATE subscription_member SET status=$1 WHERE member_id=$2`
+	_, err := s.DB.Exec(stmt, my_enums.SubMemberRemoved, memberId)
+	return err
+}
+
+// GetSubMemberUsages returns usages drawn on a subscription by the owner and every seat member
+func (s *SubscriptionDB) GetSubMemberUsages(subId int, limit int) ([]models.UsageInfo, error) {
+	stmt := `SELECT 
+	c.uuid, c.first_name, c.last_name, cu.created, 
+	su.title, su.sub_usage_id, su.unlimited, su.interval, su.amount
+	FROM customer_usage as cu
+	JOIN customer as c on c.uuid=cu.customer_uuid
+	JOIN subscription_usage as su on su.sub_usage_id=cu.sub_usage_id
+	WHERE cu.sub_id=$1
+	ORDER BY cu.created DESC
+	LIMIT $2`
+
+	rows, err := s.DB.Query(stmt, subId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	usageInfos := []models.UsageInfo{}
+	for rows.Next() {
+		u := models.UsageInfo{SubID: subId}
+		if err := rows.Scan(
+			&u.CusUUID, &u.CusFirstName, &u.CusLastName, &u.Created,
+			&u.SubUsage.Title, &u.SubUsage.ID, &u.SubUsage.Unlimited, &u.SubUsage.Interval, &u.SubUsage.Amount,
+		); err != nil {
+			return nil, err
+		}
+		usageInfos = append(usageInfos, u)
+	}
+
+	return usageInfos, nil
+}
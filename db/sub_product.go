@@ -172,7 +172,8 @@ func (s *BusinessDB) GetSubProductDeleteData(
 		"plan_id": planId,
 	}
 	
-	stmt2 := `SELECT stripe_sub_id FROM subscription WHERE plan_id=$1`
+	// gift subscriptions have no stripe sub to cancel
+	stmt2 := `SELECT stripe_sub_id FROM subscription WHERE plan_id=$1 AND stripe_sub_id IS NOT NULL`
 	rows, err := s.DB.Query(stmt2, planId)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
@@ -696,6 +697,26 @@ func (s *BusinessDB) SetSubProductPricing(
 	return err
 }
 
+func (s *BusinessDB) SetPlanSeats(
+	planId int,
+	seats int,
+	sharedUsage bool,
+) (error) {
+	stmt := `UPDATE subscription_plan SET seats=$1, shared_usage=$2 WHERE plan_id=$3`
+	_, err := s.DB.Exec(stmt, seats, sharedUsage, planId)
+	return err
+}
+
+// SetPlanMaxSubscribers caps the number of live subscriptions on a plan, nil removes the cap
+func (s *BusinessDB) SetPlanMaxSubscribers(
+	planId int,
+	maxSubscribers *int,
+) (error) {
+	stmt := `UPDATE subscription_plan SET max_subscribers=$1 WHERE plan_id=$2`
+	_, err := s.DB.Exec(stmt, maxSubscribers, planId)
+	return err
+}
+
 func (s *BusinessDB) UpdateSubProductUsage(
 	businessId int,
 	subUsageId int,
@@ -745,7 +766,17 @@ func (s *BusinessDB) DeleteSubProduct(
 	planId int,
 ) (error) {
 
-	_, err := s.DB.Exec(`DELETE from subscription_plan WHERE product_id=$1`, productId)
+	_, err := s.DB.Exec(`UPDATE gift SET sub_id=NULL WHERE plan_id=$1`, planId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`DELETE from subscription WHERE plan_id=$1 AND stripe_sub_id IS NULL`, planId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`DELETE from subscription_plan WHERE product_id=$1`, productId)
 	if err != nil {
 		return err
 	}
@@ -755,6 +786,27 @@ func (s *BusinessDB) DeleteSubProduct(
 		return err
 	}
 	
+	_, err = s.DB.Exec(`DELETE from product_image WHERE product_id=$1`, productId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`DELETE from review_flag WHERE review_id IN 
+		(SELECT review_id FROM review WHERE product_id=$1)`, productId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`DELETE from review WHERE product_id=$1`, productId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(`DELETE from recommendation WHERE product_id=$1`, productId)
+	if err != nil {
+		return err
+	}
+
 	_, err = s.DB.Exec(`DELETE from product WHERE product_id=$1`, productId)
 	return err
 }
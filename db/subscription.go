@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/db/models"
 )
 
@@ -67,7 +68,7 @@ func (s *SubscriptionDB) GetCusResumeSubData(cusId int, subId int) (
 	query := `SELECT 
 	c.stripe_id, b.stripe_id, cc.stripe_id, 
 
-	s.stripe_sub_id, s.start_date, s.cancelled, s.card_id, s.expires,
+	s.sub_id, s.customer_id, s.plan_id, s.stripe_sub_id, s.start_date, s.cancelled, s.card_id, s.expires,
 	sp.recurring_interval, sp.recurring_interval_count, sp.stripe_price_id, i.created
 
 	from customer as c
@@ -93,6 +94,9 @@ func (s *SubscriptionDB) GetCusResumeSubData(cusId int, subId int) (
 		&cusStripeId,
 		&busStripeId,
 		&cardStripeId,
+		&sub.ID,
+		&sub.CustomerID,
+		&sub.PlanID,
 		&sub.StripeSubID,
 		&sub.StartDate,
 		&sub.Cancelled,
@@ -124,7 +128,7 @@ func (s *SubscriptionDB) CusOwnsSub(cusId int, subId int) (
 	error,
 ) {
 
-	query := `SELECT s.stripe_sub_id, s.start_date, s.cancelled, s.card_id,
+	query := `SELECT COALESCE(s.stripe_sub_id, ''), s.start_date, s.cancelled, COALESCE(s.card_id, 0), s.expires,
 	sp.recurring_interval, sp.recurring_interval_count, i.created, i.stripe_pmi_id
 	from customer as c
 	JOIN subscription as s on c.customer_id=s.customer_id
@@ -146,6 +150,7 @@ func (s *SubscriptionDB) CusOwnsSub(cusId int, subId int) (
 		&sub.StartDate,
 		&sub.Cancelled,
 		&sub.CardID,
+		&sub.Expires,
 		&subPlan.RecurringDuration.Interval,
 		&subPlan.RecurringDuration.IntervalCount,
 		&invoiceCreated,
@@ -211,6 +216,77 @@ func (s *SubscriptionDB) InsertSubscriptions(subs *[]models.Subscription) (
 	return returnedSubs, nil
 }
 
+// lockPlanCapacity returns ErrPlanFull if the plan has no spot left for cusId. Capped plans
+// stay locked until tx ends so concurrent subscribes can't overshoot max_subscribers,
+// uncapped plans aren't locked at all
+func lockPlanCapacity(tx *sql.Tx, planId int, cusId int) (error) {
+	var capped bool
+	err := tx.QueryRow(`SELECT max_subscribers IS NOT NULL FROM subscription_plan WHERE plan_id=$1`, 
+		planId,
+	).Scan(&capped)
+	if err != nil || !capped {
+		return err
+	}
+
+	var maxSubscribers sql.NullInt64
+	err = tx.QueryRow(`SELECT max_subscribers FROM subscription_plan WHERE plan_id=$1 FOR UPDATE`, 
+		planId,
+	).Scan(&maxSubscribers)
+	if err != nil || !maxSubscribers.Valid {
+		return err
+	}
+
+	// a spot held for this customer by a waitlist invitation is theirs to take
+	query := `SELECT ` + planSubscriberCount + ` + 
+	(SELECT COUNT(*) FROM plan_waitlist as hw WHERE hw.plan_id=sp.plan_id 
+		AND hw.status=$3 AND hw.claim_by > now() AND hw.customer_id!=$2)
+	FROM subscription_plan as sp WHERE sp.plan_id=$1`
+
+	var taken int64
+	err = tx.QueryRow(query, planId, cusId, my_enums.WaitlistInvited).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken >= maxSubscribers.Int64 {
+		return ErrPlanFull
+	}
+	return nil
+}
+
+// InsertSubscriptionWithinCapacity creates the subscription if the plan has a spot for it.
+// create is run while the spot is held to set up the stripe side and fill in sub, nothing
+// is inserted if it fails or the plan is full. If create succeeded but the insert didn't,
+// sub.StripeSubID is left set so the caller can cancel it
+func (s *SubscriptionDB) InsertSubscriptionWithinCapacity(
+	sub *models.Subscription,
+	create func() error,
+) (error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanCapacity(tx, sub.PlanID, sub.CustomerID); err != nil {
+		return err
+	}
+
+	if err := create(); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(`INSERT into subscription (stripe_sub_id, customer_id, plan_id, start_date, card_id) 
+		VALUES ($1, $2, $3, $4, $5) RETURNING sub_id`,
+		sub.StripeSubID, sub.CustomerID, sub.PlanID, sub.StartDate, sub.CardID,
+	).Scan(&sub.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (s *SubscriptionDB) CancelSubscription(subId int, expires time.Time) (error) {
 
 	stmt := `
@@ -220,15 +296,65 @@ func (s *SubscriptionDB) CancelSubscription(subId int, expires time.Time) (error
 	return err
 }
 
-func (s *SubscriptionDB) ResumeSubscription(subId int, cardId int, stripeSubId string) (error) {
+func (s *SubscriptionDB) UndoCancelSubscription(subId int) (error) {
 
 	stmt := `
-		UPDATE subscription SET cancelled='FALSE', expires=NULL, cancelled_date=NULL, card_id=$1, stripe_sub_id=$2 WHERE sub_id=$3
+		UPDATE subscription SET cancelled='FALSE', expires=NULL, cancelled_date=NULL WHERE sub_id=$1
+	`
+	_, err := s.DB.Exec(stmt, subId)
+	return err
+}
+
+func (s *SubscriptionDB) InsertCancellationSurvey(survey models.CancellationSurvey) (error) {
+	stmt := `
+		INSERT into subscription_cancellation (sub_id, reason, comment, created) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (sub_id) DO UPDATE SET reason=$2, comment=$3, created=$4
 	`
-	_, err := s.DB.Exec(stmt, cardId, stripeSubId, subId)
+	_, err := s.DB.Exec(stmt, survey.SubID, survey.Reason, survey.Comment, survey.Created)
 	return err
 }
 
+func (s *SubscriptionDB) DeleteCancellationSurvey(subId int) (error) {
+	stmt := `DELETE FROM subscription_cancellation WHERE sub_id=$1`
+	_, err := s.DB.Exec(stmt, subId)
+	return err
+}
+
+// ResumeSubscription restarts an expired subscription if its plan has a spot for it, the
+// same way InsertSubscriptionWithinCapacity does. create makes the new stripe sub and
+// returns its id, which is kept in sub.StripeSubID for the caller to cancel on failure
+func (s *SubscriptionDB) ResumeSubscription(
+	sub *models.Subscription,
+	cardId int,
+	create func() (string, error),
+) (error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockPlanCapacity(tx, sub.PlanID, sub.CustomerID); err != nil {
+		return err
+	}
+
+	stripeSubId, err := create()
+	if err != nil {
+		return err
+	}
+	sub.StripeSubID = stripeSubId
+
+	stmt := `
+		UPDATE subscription SET cancelled='FALSE', expires=NULL, cancelled_date=NULL, card_id=$1, stripe_sub_id=$2 WHERE sub_id=$3
+	`
+	_, err = tx.Exec(stmt, cardId, stripeSubId, sub.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (s *SubscriptionDB) DeleteSubscriptionAndInvoices(subId int) (error) {
 	stmt := `DELETE FROM subscription WHERE sub_id=$1`
 	_, err := s.DB.Exec(stmt, subId)
@@ -281,4 +407,68 @@ func (s *SubscriptionDB) GetSubInvoicesFromSubID(
 	}
 
 	return invoices, nil
-}
\ No newline at end of file
+}
+func (s *SubscriptionDB) GetSubContact(subId int) (*models.SubContact, error) {
+	query := `
+	SELECT s.sub_id, c.customer_id, c.email, c.first_name, c.last_name, s.card_id, p.name, b.business_id, b.name
+	FROM subscription as s
+	JOIN customer as c on c.customer_id=s.customer_id
+	JOIN subscription_plan as sp on sp.plan_id=s.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	JOIN business as b on b.business_id=p.business_id
+	WHERE s.sub_id=$1
+	`
+
+	var contact models.SubContact
+	err := s.DB.QueryRow(query, subId).Scan(
+		&contact.SubID,
+		&contact.CustomerID,
+		&contact.CusEmail,
+		&contact.CusFirstName,
+		&contact.CusLastName,
+		&contact.CardID,
+		&contact.ProductName,
+		&contact.BusinessID,
+		&contact.BusinessName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contact, nil
+}
+
+// GetSubPassInfo gets what a subscription pass shows besides entitlements
+func (s *SubscriptionDB) GetSubPassInfo(subId int) (*models.Subscription, error) {
+	query := `
+	SELECT s.sub_id, COALESCE(s.stripe_sub_id, ''), s.customer_id, s.cancelled, s.expires,
+	p.product_id, p.name, b.business_id, b.name
+	FROM subscription as s
+	JOIN subscription_plan as sp on sp.plan_id=s.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	JOIN business as b on b.business_id=p.business_id
+	WHERE s.sub_id=$1
+	`
+
+	sub := models.Subscription{
+		BusinessName: new(string),
+		BusinessID: new(int),
+		SubProduct: &models.SubscriptionProduct{},
+	}
+	err := s.DB.QueryRow(query, subId).Scan(
+		&sub.ID,
+		&sub.StripeSubID,
+		&sub.CustomerID,
+		&sub.Cancelled,
+		&sub.Expires,
+		&sub.SubProduct.Product.ProductID,
+		&sub.SubProduct.Product.Name,
+		sub.BusinessID,
+		sub.BusinessName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"time"
 
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"github.com/johnyeocx/usual/server/db/models"
 )
 
@@ -11,11 +12,43 @@ type UsageDB struct {
 	DB *sql.DB
 }
 
-func (u *UsageDB) InsertCusUsageValid(
-	cusUuid string,
-	subUsageId int,
-	businessId int,
-) (*models.SubUsage, *int, error) {
+// subscriptions a customer can draw entitlements from, either as the owner or as an active seat member.
+// subscriptions that have lapsed, or are suspended while a failed payment is chased, are left out.
+// takes the customer uuid in $5, my_enums.SubMemberActive in $6 and my_enums.DunningOpen in $7
+const accessibleSubs = `
+	accessible_sub AS (
+		SELECT s.sub_id, s.plan_id, c.uuid, c.first_name, c.last_name 
+		FROM customer as c 
+		JOIN subscription as s on c.customer_id=s.customer_id
+		WHERE c.uuid=$5 AND (s.cancelled=FALSE OR s.expires > now()) AND ` + notSuspended + `
+		UNION
+		SELECT s.sub_id, s.plan_id, c.uuid, c.first_name, c.last_name 
+		FROM customer as c
+		JOIN subscription_member as sm on sm.customer_id=c.customer_id AND sm.status=$6
+		JOIN subscription as s on s.sub_id=sm.sub_id
+		WHERE c.uuid=$5 AND (s.cancelled=FALSE OR s.expires > now()) AND ` + notSuspended + `
+	)
+`
+
+const notSuspended = `NOT EXISTS (
+	SELECT 1 FROM dunning as d WHERE d.sub_id=s.sub_id AND d.suspended=TRUE AND d.status=$7
+)`
+
+// usages count against the whole subscription when the plan shares usage between seats,
+// otherwise only against the scanning customer. Older usages without a sub_id fall back to the customer
+const usageCountJoin = `
+	LEFT JOIN customer_usage as cu ON cu.sub_usage_id=su.sub_usage_id AND (
+		(cu.sub_id=a.sub_id AND (COALESCE(sp.shared_usage, FALSE) OR cu.customer_uuid=a.uuid)) OR
+		(cu.sub_id IS NULL AND cu.customer_uuid=a.uuid)
+	) AND (
+		su."interval" = 'day' AND cu.created > $1 OR
+		su."interval" = 'week' AND cu.created > $2 OR
+		su."interval" = 'month' AND cu.created > $3 OR
+		su."interval" = 'year' AND cu.created > $4
+	)
+`
+
+func usagePeriodStarts() (time.Time, time.Time, time.Time, time.Time) {
 	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
@@ -25,32 +58,37 @@ func (u *UsageDB) InsertCusUsageValid(
 	startOfMonth := time.Date(now.Year(), now.Month(), 0, 0, 0, 0, 0, time.UTC)
 	startOfYear := time.Date(now.Year(), 0, 0, 0, 0, 0, 0, time.UTC)
 
+	return startOfDay, startOfWeek, startOfMonth, startOfYear
+}
+
+func (u *UsageDB) InsertCusUsageValid(
+	cusUuid string,
+	subUsageId int,
+	businessId int,
+) (*models.SubUsage, *int, *int, error) {
+	startOfDay, startOfWeek, startOfMonth, startOfYear := usagePeriodStarts()
+
 	stmt := `
-		SELECT su.unlimited, su.interval, su.amount, COUNT(cu.sub_usage_id) as usage_count 
-		from customer as c 
-		JOIN subscription as s on c.customer_id=s.customer_id
-		JOIN subscription_plan as sp ON s.plan_id=sp.plan_id
+		WITH ` + accessibleSubs + `
+		SELECT a.sub_id, su.unlimited, su.interval, su.amount, COUNT(cu.usage_id) as usage_count 
+		from accessible_sub as a
+		JOIN subscription_plan as sp ON a.plan_id=sp.plan_id
 		JOIN subscription_usage as su ON su.plan_id=sp.plan_id
 		JOIN product as p on p.product_id=sp.product_id
 		JOIN business as b ON b.business_id=p.business_id
-
-		LEFT JOIN customer_usage as cu ON cu.sub_usage_id=su.sub_usage_id AND (
-			su."interval" = 'day' AND cu.created > $1 OR
-			su."interval" = 'week' AND cu.created > $2 OR
-			su."interval" = 'month' AND cu.created > $3 OR
-			su."interval" = 'year' AND cu.created > $4
-		)
-
-		WHERE c.uuid=$5 AND b.business_id=$6 AND su.sub_usage_id=$7
-		GROUP BY 
-		cu.sub_usage_id, c.customer_id, s.plan_id, p.product_id, su.sub_usage_id
+		` + usageCountJoin + `
+		WHERE b.business_id=$8 AND su.sub_usage_id=$9
+		GROUP BY a.sub_id, su.sub_usage_id
+		LIMIT 1
 	`
 
 	subUsage := models.SubUsage{}
+	var subId int
 	var usageCount int
 	err := u.DB.QueryRow(
 		stmt, startOfDay, startOfWeek, startOfMonth, startOfYear, 
-		cusUuid, businessId, subUsageId).Scan(
+		cusUuid, my_enums.SubMemberActive, my_enums.DunningOpen, businessId, subUsageId).Scan(
+		&subId,
 		&subUsage.Unlimited,
 		&subUsage.Interval, 
 		&subUsage.Amount,
@@ -58,58 +96,40 @@ func (u *UsageDB) InsertCusUsageValid(
 	)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	return &subUsage, &usageCount, nil
+	return &subUsage, &usageCount, &subId, nil
 }
 
 func (u *UsageDB) GetCusUsagesOnBusiness(
 	cusUuid string, 
 	busId int,
 ) ([]models.UsageInfo, error){
-
-	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
-
-	weekday := now.Weekday()
-	startOfWeek := time.Date(now.Year(), now.Month(), now.Day() - int(weekday - 1), 0, 0, 0, 0, time.UTC)
-
-	startOfMonth := time.Date(now.Year(), now.Month(), 0, 0, 0, 0, 0, time.UTC)
-	startOfYear := time.Date(now.Year(), 0, 0, 0, 0, 0, 0, time.UTC)
-
-
+	startOfDay, startOfWeek, startOfMonth, startOfYear := usagePeriodStarts()
 
 	query := `
+		WITH ` + accessibleSubs + `
 		SELECT 
-		c.uuid, c.first_name, c.last_name,
-		s.plan_id, 
+		a.uuid, a.first_name, a.last_name,
+		a.sub_id, a.plan_id, 
 		su.title, su.sub_usage_id, su.unlimited, su.interval, su.amount,
 		p.product_id, p.name, 
-		COUNT(cu.sub_usage_id) as usage_count 
-		from 
-		customer as c z
-		JOIN subscription as s on c.customer_id=s.customer_id
-		JOIN subscription_plan as sp ON s.plan_id=sp.plan_id
+		COUNT(cu.usage_id) as usage_count 
+		from accessible_sub as a
+		JOIN subscription_plan as sp ON a.plan_id=sp.plan_id
 		JOIN subscription_usage as su ON su.plan_id=sp.plan_id
 		JOIN product as p on p.product_id=sp.product_id
 		JOIN business as b ON b.business_id=p.business_id
-
-		LEFT JOIN customer_usage as cu ON cu.sub_usage_id=su.sub_usage_id AND (
-			su."interval" = 'day' AND cu.created > $1 OR
-			su."interval" = 'week' AND cu.created > $2 OR
-			su."interval" = 'month' AND cu.created > $3 OR
-			su."interval" = 'year' AND cu.created > $4
-		)
-
-		WHERE c.uuid=$5 AND b.business_id=$6
+		` + usageCountJoin + `
+		WHERE b.business_id=$8
 		GROUP BY 
-		cu.sub_usage_id, c.customer_id, s.plan_id, p.product_id, su.sub_usage_id
+		a.uuid, a.first_name, a.last_name, a.sub_id, a.plan_id, p.product_id, su.sub_usage_id
 		ORDER BY su.sub_usage_id
 	`
 
 	rows, err := u.DB.Query(
 		query, startOfDay, startOfWeek, startOfMonth, startOfYear, 
-		cusUuid, busId,
+		cusUuid, my_enums.SubMemberActive, my_enums.DunningOpen, busId,
 	)
 	if err != nil {
 		return nil, err
@@ -124,6 +144,7 @@ func (u *UsageDB) GetCusUsagesOnBusiness(
 			&info.CusUUID,
 			&info.CusFirstName,
 			&info.CusLastName,
+			&info.SubID,
 			&info.PlanID,
 			&info.SubUsage.Title,
 			&info.SubUsage.ID,
@@ -147,8 +168,8 @@ func (u *UsageDB) InsertNewCusUsage(
 	usage models.CusUsage,
 ) (*models.CusUsage, error){
 
-	query := `INSERT into customer_usage (customer_uuid, created, sub_usage_id) 
-		VALUES ($1, $2, $3) RETURNING usage_id, customer_uuid, created, sub_usage_id
+	query := `INSERT into customer_usage (customer_uuid, created, sub_usage_id, sub_id) 
+		VALUES ($1, $2, $3, $4) RETURNING usage_id, customer_uuid, created, sub_usage_id, sub_id
 	`
 
 	returnedUsage := models.CusUsage{}
@@ -157,16 +178,94 @@ func (u *UsageDB) InsertNewCusUsage(
 		usage.CusUUID, 
 		usage.Created, 
 		usage.SubUsageID,
+		usage.SubID,
 	).Scan(
 		&returnedUsage.ID, 
 		&returnedUsage.CusUUID,
 		&returnedUsage.Created,
 		&returnedUsage.SubUsageID,
+		&returnedUsage.SubID,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &usage, nil
-}
\ No newline at end of file
+	return &returnedUsage, nil
+}
+
+// GetRedemptionDetails gets what a customer is told when one of their usages is redeemed
+func (u *UsageDB) GetRedemptionDetails(
+	cusUuid string,
+	subUsageId int,
+) (int, string, string, error) {
+	query := `SELECT c.customer_id, su.title, b.name
+		FROM customer as c, subscription_usage as su
+		JOIN subscription_plan as sp ON sp.plan_id=su.plan_id
+		JOIN product as p on p.product_id=sp.product_id
+		JOIN business as b ON b.business_id=p.business_id
+		WHERE c.uuid=$1 AND su.sub_usage_id=$2
+	`
+
+	var cusId int
+	var title, businessName string
+	err := u.DB.QueryRow(query, cusUuid, subUsageId).Scan(&cusId, &title, &businessName)
+	return cusId, title, businessName, err
+}
+
+// GetCusEntitlements lists every usage the customer can draw on across their live
+// subscriptions, with how much of each they've used this period. Subscriptions whose
+// plan has no usages come back with a zero SubUsage.ID
+func (u *UsageDB) GetCusEntitlements(cusUuid string) ([]models.UsageInfo, error) {
+	startOfDay, startOfWeek, startOfMonth, startOfYear := usagePeriodStarts()
+
+	query := `
+		WITH ` + accessibleSubs + `
+		SELECT 
+		a.sub_id, a.plan_id,
+		COALESCE(su.title, ''), COALESCE(su.sub_usage_id, 0), COALESCE(su.unlimited, FALSE),
+		su.interval, su.amount,
+		p.product_id, p.name, b.name,
+		COUNT(cu.usage_id) as usage_count 
+		from accessible_sub as a
+		JOIN subscription_plan as sp ON a.plan_id=sp.plan_id
+		LEFT JOIN subscription_usage as su ON su.plan_id=sp.plan_id
+		JOIN product as p on p.product_id=sp.product_id
+		JOIN business as b ON b.business_id=p.business_id
+		` + usageCountJoin + `
+		GROUP BY a.sub_id, a.plan_id, p.product_id, b.business_id, su.sub_usage_id
+		ORDER BY a.sub_id, su.sub_usage_id
+	`
+
+	rows, err := u.DB.Query(
+		query, startOfDay, startOfWeek, startOfMonth, startOfYear,
+		cusUuid, my_enums.SubMemberActive, my_enums.DunningOpen,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usageInfos := []models.UsageInfo{}
+	for rows.Next() {
+		var info models.UsageInfo
+		if err := rows.Scan(
+			&info.SubID,
+			&info.PlanID,
+			&info.SubUsage.Title,
+			&info.SubUsage.ID,
+			&info.SubUsage.Unlimited,
+			&info.SubUsage.Interval,
+			&info.SubUsage.Amount,
+			&info.ProductID,
+			&info.ProductName,
+			&info.BusinessName,
+			&info.UsageCount,
+		); err != nil {
+			return nil, err
+		}
+		usageInfos = append(usageInfos, info)
+	}
+
+	return usageInfos, rows.Err()
+}
@@ -0,0 +1,247 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	my_enums "github.com/johnyeocx/usual/server/constants/enums"
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+var ErrPlanFull = errors.New("plan has reached its subscriber limit")
+
+type WaitlistDB struct {
+	DB *sql.DB
+}
+
+// a plan's spots are taken by live subscriptions and by waitlist invitations
+// that are still inside their claim window
+const planSubscriberCount = `(SELECT COUNT(*) FROM subscription as s 
+	WHERE s.plan_id=sp.plan_id AND (s.cancelled=FALSE OR s.expires > now()))`
+
+// planHeldSpotCount and planWaitingCount take the query param holding
+// my_enums.WaitlistInvited and my_enums.WaitlistWaiting respectively
+func planHeldSpotCount(invitedParam int) (string) {
+	return fmt.Sprintf(`(SELECT COUNT(*) FROM plan_waitlist as hw 
+	WHERE hw.plan_id=sp.plan_id AND hw.status=$%d AND hw.claim_by > now())`, invitedParam)
+}
+
+func planWaitingCount(waitingParam int) (string) {
+	return fmt.Sprintf(`(SELECT COUNT(*) FROM plan_waitlist as ww 
+	WHERE ww.plan_id=sp.plan_id AND ww.status=$%d)`, waitingParam)
+}
+
+// position only means something while w is waiting, so it counts who's ahead with the same status
+const waitlistSelect = `SELECT 
+	w.waitlist_id, w.plan_id, w.customer_id, w.status, w.created, w.invited, w.claim_by,
+	(SELECT COUNT(*) + 1 FROM plan_waitlist as pw 
+		WHERE pw.plan_id=w.plan_id AND pw.status=w.status AND pw.created < w.created),
+	c.email, c.first_name, p.product_id, p.name, b.name
+	FROM plan_waitlist as w
+	JOIN customer as c on c.customer_id=w.customer_id
+	JOIN subscription_plan as sp on sp.plan_id=w.plan_id
+	JOIN product as p on p.product_id=sp.product_id
+	JOIN business as b on b.business_id=p.business_id
+`
+
+func scanWaitlistEntry(row interface{ Scan(dest ...interface{}) error }) (*models.WaitlistEntry, error) {
+	var w models.WaitlistEntry
+	err := row.Scan(
+		&w.ID, &w.PlanID, &w.CustomerID, &w.Status, &w.Created, &w.Invited, &w.ClaimBy,
+		&w.Position,
+		&w.CusEmail, &w.CusFirstName, &w.ProductID, &w.ProductName, &w.BusinessName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Status != my_enums.WaitlistWaiting {
+		w.Position = nil
+	}
+	return &w, nil
+}
+
+func (w *WaitlistDB) getWaitlistEntries(where string, args ...interface{}) ([]models.WaitlistEntry, error) {
+	rows, err := w.DB.Query(waitlistSelect + where, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []models.WaitlistEntry{}
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	return entries, nil
+}
+
+func (w *WaitlistDB) GetWaitlistEntry(waitlistId int) (*models.WaitlistEntry, error) {
+	return scanWaitlistEntry(w.DB.QueryRow(waitlistSelect + ` WHERE w.waitlist_id=$1`, waitlistId))
+}
+
+func (w *WaitlistDB) GetCusWaitlistEntries(cusId int) ([]models.WaitlistEntry, error) {
+	return w.getWaitlistEntries(
+		` WHERE w.customer_id=$1 AND (w.status=$2 OR w.status=$3) ORDER BY w.created`, 
+		cusId, my_enums.WaitlistWaiting, my_enums.WaitlistInvited,
+	)
+}
+
+// InsertWaitlistEntry puts the customer at the back of the plan's waitlist, customers
+// who previously left or let an invitation lapse can rejoin. ErrNoRows means they're already on it
+func (w *WaitlistDB) InsertWaitlistEntry(planId int, cusId int) (*int, error) {
+	query := `INSERT into plan_waitlist (plan_id, customer_id, status, created) 
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (plan_id, customer_id) DO UPDATE 
+	SET status=EXCLUDED.status, created=EXCLUDED.created, invited=NULL, claim_by=NULL
+	WHERE plan_waitlist.status=$5 OR plan_waitlist.status=$6 OR plan_waitlist.status=$7
+	RETURNING waitlist_id`
+
+	var waitlistId int
+	err := w.DB.QueryRow(query, 
+		planId, cusId, my_enums.WaitlistWaiting, time.Now(),
+		my_enums.WaitlistExpired, my_enums.WaitlistLeft, my_enums.WaitlistClaimed,
+	).Scan(&waitlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &waitlistId, nil
+}
+
+func (w *WaitlistDB) LeaveWaitlist(planId int, cusId int) (error) {
+	_, err := w.DB.Exec(`UPDATE plan_waitlist SET status=$1 
+		WHERE plan_id=$2 AND customer_id=$3 AND (status=$4 OR status=$5)`,
+		my_enums.WaitlistLeft, planId, cusId, my_enums.WaitlistWaiting, my_enums.WaitlistInvited,
+	)
+	return err
+}
+
+func (w *WaitlistDB) SetWaitlistClaimed(planId int, cusId int) (error) {
+	_, err := w.DB.Exec(`UPDATE plan_waitlist SET status=$1 
+		WHERE plan_id=$2 AND customer_id=$3 AND (status=$4 OR status=$5)`,
+		my_enums.WaitlistClaimed, planId, cusId, my_enums.WaitlistWaiting, my_enums.WaitlistInvited,
+	)
+	return err
+}
+
+// ExpireWaitlistInvitations lapses invitations past their claim window, freeing
+// the held spots for the next in line
+func (w *WaitlistDB) ExpireWaitlistInvitations(now time.Time) (error) {
+	_, err := w.DB.Exec(`UPDATE plan_waitlist SET status=$1 WHERE status=$2 AND claim_by < $3`,
+		my_enums.WaitlistExpired, my_enums.WaitlistInvited, now,
+	)
+	return err
+}
+
+func (w *WaitlistDB) GetPlanCapacity(planId int) (*models.PlanCapacity, error) {
+	query := `SELECT sp.plan_id, p.product_id, p.name, sp.max_subscribers, ` + 
+	planSubscriberCount + `, ` + planHeldSpotCount(2) + `, ` + planWaitingCount(3) + `
+	FROM subscription_plan as sp
+	JOIN product as p on p.product_id=sp.product_id
+	WHERE sp.plan_id=$1 AND sp.max_subscribers IS NOT NULL`
+
+	var c models.PlanCapacity
+	err := w.DB.QueryRow(query, planId, my_enums.WaitlistInvited, my_enums.WaitlistWaiting).Scan(
+		&c.PlanID, &c.ProductID, &c.ProductName, &c.MaxSubscribers, 
+		&c.Subscribers, &c.HeldSpots, &c.Waiting,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// GetPlansWithFreeSpots returns capped plans that have both free spots and people waiting
+func (w *WaitlistDB) GetPlansWithFreeSpots() ([]int, error) {
+	query := `SELECT sp.plan_id FROM subscription_plan as sp
+	WHERE sp.max_subscribers IS NOT NULL AND ` + planWaitingCount(1) + ` > 0 
+	AND sp.max_subscribers > ` + planSubscriberCount + ` + ` + planHeldSpotCount(2)
+
+	rows, err := w.DB.Query(query, my_enums.WaitlistWaiting, my_enums.WaitlistInvited)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	planIds := []int{}
+	for rows.Next() {
+		var planId int
+		if err := rows.Scan(&planId); err != nil {
+			return nil, err
+		}
+		planIds = append(planIds, planId)
+	}
+
+	return planIds, nil
+}
+
+// InviteNextFromWaitlist moves as many waiting customers as the plan has free spots to
+// invited, holding a spot for each of them until claimBy. The plan is locked the same
+// way lockPlanCapacity locks it, so spots are counted and handed out in one go
+func (w *WaitlistDB) InviteNextFromWaitlist(planId int, claimBy time.Time) ([]int, error) {
+	tx, err := w.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var maxSubscribers sql.NullInt64
+	err = tx.QueryRow(`SELECT max_subscribers FROM subscription_plan WHERE plan_id=$1 FOR UPDATE`, 
+		planId,
+	).Scan(&maxSubscribers)
+	if err != nil || !maxSubscribers.Valid {
+		return nil, err
+	}
+
+	var taken int64
+	err = tx.QueryRow(`SELECT ` + planSubscriberCount + ` + ` + planHeldSpotCount(2) + `
+		FROM subscription_plan as sp WHERE sp.plan_id=$1`, planId, my_enums.WaitlistInvited,
+	).Scan(&taken)
+	if err != nil {
+		return nil, err
+	}
+
+	free := maxSubscribers.Int64 - taken
+	if free <= 0 {
+		return []int{}, nil
+	}
+
+	query := `UPDATE plan_waitlist SET status=$1, invited=$2, claim_by=$3
+	WHERE waitlist_id IN (
+		SELECT waitlist_id FROM plan_waitlist WHERE plan_id=$4 AND status=$5 
+		ORDER BY created LIMIT $6 FOR UPDATE
+	) RETURNING waitlist_id`
+
+	rows, err := tx.Query(query, 
+		my_enums.WaitlistInvited, time.Now(), claimBy, planId, my_enums.WaitlistWaiting, free,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, tx.Commit()
+}
@@ -11,6 +11,10 @@ const (
 	GetBusFailed BusError = "get_business_failed"
 	GetInvoicesFailed BusError = "get_business_invoices_failed"
 	GetPayoutsFailed BusError = "get_business_payouts_failed"
+	GetChurnReasonsFailed BusError = "get_business_churn_reasons_failed"
+	GetDunningStatsFailed BusError = "get_business_dunning_stats_failed"
+	GetSubsFailed BusError = "get_business_subs_failed"
+	GetUsagesFailed BusError = "get_business_usages_failed"
 )
 
 func GetBusFailedReqErr(err error) *models.RequestError {
@@ -35,4 +39,36 @@ func GetPayoutsFailedErr(err error) *models.RequestError {
 		StatusCode: http.StatusBadGateway,
 		Code: string(GetPayoutsFailed),
 	}
-}
\ No newline at end of file
+}
+
+func GetChurnReasonsFailedErr(err error) *models.RequestError {
+	return &models.RequestError{
+		Err: err,
+		StatusCode: http.StatusBadGateway,
+		Code: string(GetChurnReasonsFailed),
+	}
+}
+
+func GetDunningStatsFailedErr(err error) *models.RequestError {
+	return &models.RequestError{
+		Err: err,
+		StatusCode: http.StatusBadGateway,
+		Code: string(GetDunningStatsFailed),
+	}
+}
+
+func GetSubsFailedErr(err error) *models.RequestError {
+	return &models.RequestError{
+		Err: err,
+		StatusCode: http.StatusBadGateway,
+		Code: string(GetSubsFailed),
+	}
+}
+
+func GetUsagesFailedErr(err error) *models.RequestError {
+	return &models.RequestError{
+		Err: err,
+		StatusCode: http.StatusBadGateway,
+		Code: string(GetUsagesFailed),
+	}
+}
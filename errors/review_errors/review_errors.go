@@ -0,0 +1,49 @@
+package review_errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+type ReviewError string
+const (
+	NotSubscribed ReviewError = "not_subscribed"
+	InvalidRating ReviewError = "invalid_rating"
+	ReviewTooLong ReviewError = "review_too_long"
+	OwnReview ReviewError = "own_review"
+)
+
+func NotSubscribedReqErr() *models.RequestError {
+	return &models.RequestError{
+		Err: errors.New("only customers who have subscribed to the product can review it"),
+		StatusCode: http.StatusForbidden,
+		Code: string(NotSubscribed),
+	}
+}
+
+func InvalidRatingReqErr(min int, max int) *models.RequestError {
+	return &models.RequestError{
+		Err: fmt.Errorf("rating must be between %d and %d", min, max),
+		StatusCode: http.StatusBadRequest,
+		Code: string(InvalidRating),
+	}
+}
+
+func ReviewTooLongReqErr(max int) *models.RequestError {
+	return &models.RequestError{
+		Err: fmt.Errorf("review can be at most %d characters", max),
+		StatusCode: http.StatusBadRequest,
+		Code: string(ReviewTooLong),
+	}
+}
+
+func OwnReviewReqErr() *models.RequestError {
+	return &models.RequestError{
+		Err: errors.New("customers can't flag their own review"),
+		StatusCode: http.StatusForbidden,
+		Code: string(OwnReview),
+	}
+}
@@ -0,0 +1,39 @@
+package sub_errors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+type SubError string
+const (
+	PlanFull SubError = "plan_full"
+	PlanNotFull SubError = "plan_not_full"
+	AlreadyOnWaitlist SubError = "already_on_waitlist"
+)
+
+func PlanFullReqErr() *models.RequestError {
+	return &models.RequestError{
+		Err: errors.New("plan has reached its subscriber limit"),
+		StatusCode: http.StatusConflict,
+		Code: string(PlanFull),
+	}
+}
+
+func PlanNotFullReqErr() *models.RequestError {
+	return &models.RequestError{
+		Err: errors.New("plan has free spots"),
+		StatusCode: http.StatusConflict,
+		Code: string(PlanNotFull),
+	}
+}
+
+func AlreadyOnWaitlistReqErr() *models.RequestError {
+	return &models.RequestError{
+		Err: errors.New("customer is already on the waitlist"),
+		StatusCode: http.StatusConflict,
+		Code: string(AlreadyOnWaitlist),
+	}
+}
@@ -3,11 +3,13 @@ package cloud
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -46,17 +48,33 @@ func ConnectAWS() (*session.Session) {
 
 }
 
+// S3Store keeps objects in an s3 bucket
+type S3Store struct {
+	Sess 	*session.Session
+	Bucket 	string
+}
+
+func NewS3Store(sess *session.Session, bucket string) (*S3Store) {
+	return &S3Store{Sess: sess, Bucket: bucket}
+}
 
-func GetImageUploadUrl(sess *session.Session, key string) (string, error) {
-	svc := s3.New(sess)
-	var bucket = os.Getenv("BUCKET_NAME")
+func (s *S3Store) PresignPut(key string, expiry time.Duration, contentType string, size int64) (string, error) {
+	svc := s3.New(s.Sess)
 
-	req, _ := svc.PutObjectRequest(&s3.PutObjectInput{
-		Bucket: aws.String(bucket),
+	// both end up in the signed headers so s3 rejects anything else
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(key),
-	})
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if size > 0 {
+		input.ContentLength = aws.Int64(size)
+	}
+	req, _ := svc.PutObjectRequest(input)
 
-	str, err := req.Presign(60 * time.Minute)
+	str, err := req.Presign(expiry)
 	if err != nil {
 		return "can't sign", err
 	}
@@ -64,16 +82,15 @@ func GetImageUploadUrl(sess *session.Session, key string) (string, error) {
 	return str, nil
 }
 
-func GetObjectPresignedURL(sess *session.Session, key string, time time.Duration) (string, error) {
-	svc := s3.New(sess)
-	var bucket = os.Getenv("BUCKET_NAME")
+func (s *S3Store) PresignGet(key string, expiry time.Duration) (string, error) {
+	svc := s3.New(s.Sess)
 
 	req, _ := svc.GetObjectRequest(&s3.GetObjectInput{
-		Bucket: aws.String(bucket),
+		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(key),
 	})
 
-	str, err := req.Presign(time)
+	str, err := req.Presign(expiry)
 	if err != nil {
 		return "can't sign", err
 	}
@@ -81,31 +98,41 @@ func GetObjectPresignedURL(sess *session.Session, key string, time time.Duration
 	return str, nil
 }
 
-func PutObject(
-	sess *session.Session, 
-	image []byte, 
-	contentType string,
-	key string,
-) (error) {
-	svc := s3.New(sess)
-	var bucket = os.Getenv("BUCKET_NAME")	
+func (s *S3Store) Put(key string, data []byte, contentType string) (error) {
+	svc := s3.New(s.Sess)
 
 	_, err := svc.PutObject(&s3.PutObjectInput{
-		Body: bytes.NewReader(image),
+		Body: bytes.NewReader(data),
 		ContentType: &contentType,
-		Bucket: aws.String(bucket),
+		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(key),
 	})
 
 	return err
 }
 
-func DeleteImage(sess *session.Session, key string) (error) {
-	svc := s3.New(sess)
-	var bucket = os.Getenv("BUCKET_NAME")
+func (s *S3Store) Get(key string) ([]byte, error) {
+	svc := s3.New(s.Sess)
+
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrObjectNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+func (s *S3Store) Delete(key string) (error) {
+	svc := s3.New(s.Sess)
 
 	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(bucket),
+		Bucket: aws.String(s.Bucket),
 		Key:    aws.String(key),
 	})
 
@@ -116,4 +143,4 @@ func DeleteImage(sess *session.Session, key string) (error) {
 	}
 	
 	return err
-}
\ No newline at end of file
+}
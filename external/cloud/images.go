@@ -0,0 +1,30 @@
+package cloud
+
+import (
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+// imageURLExpiry is long enough for clients to cache explore images between loads
+var imageURLExpiry = 24 * time.Hour
+
+// PresignImages swaps the stored keys of each variant for urls clients can load.
+// A variant that can't be signed is left empty rather than failing the response
+func PresignImages(store ObjectStore, images *models.ImageVariants) {
+	if images == nil {
+		return
+	}
+
+	for _, key := range []*string{&images.Thumbnail, &images.Medium, &images.Large} {
+		if *key == "" {
+			continue
+		}
+
+		url, err := store.PresignGet(*key, imageURLExpiry)
+		if err != nil {
+			url = ""
+		}
+		*key = url
+	}
+}
@@ -0,0 +1,118 @@
+package cloud
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LocalStore keeps objects on disk so the server runs without aws. Keys are cleaned
+// before use so they can't escape Dir
+type LocalStore struct {
+	urlSigner
+	Dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore) {
+	return &LocalStore{urlSigner: newURLSigner(), Dir: dir}
+}
+
+func (l *LocalStore) path(key string) (string) {
+	return filepath.Join(l.Dir, filepath.Clean("/" + key))
+}
+
+// content types are kept next to each object
+func (l *LocalStore) typePath(key string) (string) {
+	return l.path(key) + ".content-type"
+}
+
+func (l *LocalStore) Put(key string, data []byte, contentType string) (error) {
+	p := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(l.typePath(key), []byte(contentType), 0644)
+}
+
+func (l *LocalStore) Get(key string) ([]byte, error) {
+	b, err := os.ReadFile(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return b, err
+}
+
+func (l *LocalStore) Delete(key string) (error) {
+	os.Remove(l.typePath(key))
+
+	err := os.Remove(l.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *LocalStore) ContentType(key string) (string) {
+	b, err := os.ReadFile(l.typePath(key))
+	if err != nil || len(b) == 0 {
+		return "application/octet-stream"
+	}
+	return string(b)
+}
+
+type memoryObject struct {
+	data 		[]byte
+	contentType string
+}
+
+// MemoryStore keeps objects for the life of the process, for tests and local runs
+type MemoryStore struct {
+	urlSigner
+	mu 		sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func NewMemoryStore() (*MemoryStore) {
+	return &MemoryStore{urlSigner: newURLSigner(), objects: map[string]memoryObject{}}
+}
+
+func (m *MemoryStore) Put(key string, data []byte, contentType string) (error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.objects[key] = memoryObject{data: append([]byte{}, data...), contentType: contentType}
+	return nil
+}
+
+func (m *MemoryStore) Get(key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return obj.data, nil
+}
+
+func (m *MemoryStore) Delete(key string) (error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *MemoryStore) ContentType(key string) (string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if obj, ok := m.objects[key]; ok && obj.contentType != "" {
+		return obj.contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,135 @@
+package cloud
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// ObjectStore is where uploaded and generated files (qr codes, passes, statements,
+// images) are kept. Clients never get the store itself, only presigned urls
+type ObjectStore interface {
+	Put(key string, data []byte, contentType string) (error)
+	// Get returns ErrObjectNotFound if nothing is stored at key
+	Get(key string) ([]byte, error)
+	Delete(key string) (error)
+	// PresignGet returns a url anyone can download key from until expiry
+	PresignGet(key string, expiry time.Duration) (string, error)
+	// PresignPut returns a url a client can upload key to until expiry. A contentType
+	// or size makes the upload fail unless it has exactly that type and length, leave
+	// them empty to accept anything
+	PresignPut(key string, expiry time.Duration, contentType string, size int64) (string, error)
+}
+
+// SignedStore is a store whose presigned urls point back at this server rather than
+// at the provider, so they have to be checked and served by the storage route
+type SignedStore interface {
+	ObjectStore
+	// VerifyURL checks a presigned url's query. For uploads the body still has to be
+	// checked against the content_type and size it was signed with
+	VerifyURL(method string, query url.Values) (error)
+	// ContentType returns what key was stored as
+	ContentType(key string) (string)
+}
+
+// NewStoreFromEnv picks the store with STORAGE_PROVIDER, s3 (the default), local or
+// memory. Local files go under STORAGE_DIR and presigned urls are served from
+// STORAGE_BASE_URL
+func NewStoreFromEnv() (ObjectStore) {
+	switch os.Getenv("STORAGE_PROVIDER") {
+	case "local":
+		return NewLocalStore(envOr("STORAGE_DIR", "./tmp/storage"))
+	case "memory":
+		return NewMemoryStore()
+	default:
+		return NewS3Store(ConnectAWS(), os.Getenv("BUCKET_NAME"))
+	}
+}
+
+func envOr(key string, fallback string) (string) {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// urlSigner signs storage urls with STORAGE_SIGNING_KEY. Without one a random key
+// is used, so urls stop working when the server restarts
+type urlSigner struct {
+	baseURL string
+	secret 	[]byte
+}
+
+func newURLSigner() (urlSigner) {
+	secret := []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+
+	return urlSigner{
+		baseURL: envOr("STORAGE_BASE_URL", "http://localhost:8080/api/storage"),
+		secret: secret,
+	}
+}
+
+func (u urlSigner) signature(method string, q url.Values) (string) {
+	mac := hmac.New(sha256.New, u.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s",
+		method, q.Get("key"), q.Get("expires"), q.Get("content_type"), q.Get("size"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (u urlSigner) sign(
+	method string,
+	key string,
+	expiry time.Duration,
+	contentType string,
+	size int64,
+) (string, error) {
+	q := url.Values{}
+	q.Set("key", key)
+	q.Set("expires", strconv.FormatInt(time.Now().Add(expiry).Unix(), 10))
+	if contentType != "" {
+		q.Set("content_type", contentType)
+	}
+	if size > 0 {
+		q.Set("size", strconv.FormatInt(size, 10))
+	}
+	q.Set("signature", u.signature(method, q))
+
+	return u.baseURL + "?" + q.Encode(), nil
+}
+
+func (u urlSigner) VerifyURL(method string, query url.Values) (error) {
+	unix, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(u.signature(method, query))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (u urlSigner) PresignGet(key string, expiry time.Duration) (string, error) {
+	return u.sign(http.MethodGet, key, expiry, "", 0)
+}
+
+func (u urlSigner) PresignPut(key string, expiry time.Duration, contentType string, size int64) (string, error) {
+	return u.sign(http.MethodPut, key, expiry, contentType, size)
+}
@@ -0,0 +1,108 @@
+package geocode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+var ErrNotFound = errors.New("address could not be found")
+
+// Geocoder turns a business address into a point on the map
+type Geocoder interface {
+	Name() string
+	// Geocode returns ErrNotFound if the address doesn't match anywhere
+	Geocode(address models.Address) (*models.Location, error)
+}
+
+// NewGeocoderFromEnv picks the provider from GEOCODER, nominatim (the default) or stub.
+// Nominatim is OpenStreetMap's geocoder, GEOCODER_URL points it at a self hosted one
+func NewGeocoderFromEnv() (Geocoder) {
+	if os.Getenv("GEOCODER") == "stub" {
+		return StubGeocoder{}
+	}
+
+	return &NominatimGeocoder{
+		URL: envOr("GEOCODER_URL", "https://nominatim.openstreetmap.org/search"),
+		UserAgent: envOr("GEOCODER_USER_AGENT", "usual-server"),
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func envOr(key string, fallback string) (string) {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// NominatimGeocoder uses the structured search so a missing line doesn't throw the
+// match off. The public instance needs a user agent and allows a request a second
+type NominatimGeocoder struct {
+	URL 		string
+	UserAgent 	string
+	Client 		*http.Client
+}
+
+func (n *NominatimGeocoder) Name() (string) {
+	return "nominatim"
+}
+
+func (n *NominatimGeocoder) Geocode(address models.Address) (*models.Location, error) {
+	q := url.Values{}
+	q.Set("format", "jsonv2")
+	q.Set("limit", "1")
+	q.Set("street", strings.TrimSpace(address.Line1 + " " + address.Line2))
+	q.Set("city", address.City)
+	q.Set("postalcode", address.PostalCode)
+	if address.Country != nil {
+		q.Set("countrycodes", strings.ToLower(*address.Country))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, n.URL + "?" + q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", n.UserAgent)
+
+	res, err := n.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim returned %d", res.StatusCode)
+	}
+
+	// coordinates come back as strings
+	var places []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, ErrNotFound
+	}
+
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return nil, err
+	}
+	lng, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Location{Latitude: lat, Longitude: lng}, nil
+}
@@ -0,0 +1,75 @@
+package geocode
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+// StubGeocoder works offline for development and tests. It places an address at the
+// centre of its town, found from the postcode area or else the city name, so only
+// the towns below can be found
+type StubGeocoder struct{}
+
+type town struct {
+	name 		string
+	areas 		[]string
+	location 	models.Location
+}
+
+var towns = []town{
+	{"london", []string{"E", "EC", "N", "NW", "SE", "SW", "W", "WC"}, models.Location{Latitude: 51.5072, Longitude: -0.1276}},
+	{"birmingham", []string{"B"}, models.Location{Latitude: 52.4862, Longitude: -1.8904}},
+	{"manchester", []string{"M"}, models.Location{Latitude: 53.4808, Longitude: -2.2426}},
+	{"leeds", []string{"LS"}, models.Location{Latitude: 53.8008, Longitude: -1.5491}},
+	{"liverpool", []string{"L"}, models.Location{Latitude: 53.4084, Longitude: -2.9916}},
+	{"sheffield", []string{"S"}, models.Location{Latitude: 53.3811, Longitude: -1.4701}},
+	{"bristol", []string{"BS"}, models.Location{Latitude: 51.4545, Longitude: -2.5879}},
+	{"newcastle", []string{"NE"}, models.Location{Latitude: 54.9783, Longitude: -1.6178}},
+	{"nottingham", []string{"NG"}, models.Location{Latitude: 52.9548, Longitude: -1.1581}},
+	{"edinburgh", []string{"EH"}, models.Location{Latitude: 55.9533, Longitude: -3.1883}},
+	{"glasgow", []string{"G"}, models.Location{Latitude: 55.8642, Longitude: -4.2518}},
+	{"cardiff", []string{"CF"}, models.Location{Latitude: 51.4816, Longitude: -3.1791}},
+	{"cambridge", []string{"CB"}, models.Location{Latitude: 52.2053, Longitude: 0.1218}},
+	{"oxford", []string{"OX"}, models.Location{Latitude: 51.7520, Longitude: -1.2577}},
+	{"brighton", []string{"BN"}, models.Location{Latitude: 50.8225, Longitude: -0.1372}},
+}
+
+func (StubGeocoder) Name() (string) {
+	return "stub"
+}
+
+func (StubGeocoder) Geocode(address models.Address) (*models.Location, error) {
+	area := postcodeArea(address.PostalCode)
+	city := strings.ToLower(strings.TrimSpace(address.City))
+
+	for _, t := range towns {
+		for _, a := range t.areas {
+			if a == area {
+				loc := t.location
+				return &loc, nil
+			}
+		}
+	}
+
+	for _, t := range towns {
+		if t.name == city {
+			loc := t.location
+			return &loc, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// postcodeArea is the letters a UK postcode starts with, "LS1 4AP" is "LS"
+func postcodeArea(postcode string) (string) {
+	postcode = strings.ToUpper(strings.TrimSpace(postcode))
+	end := strings.IndexFunc(postcode, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end == -1 {
+		return postcode
+	}
+	return postcode[:end]
+}
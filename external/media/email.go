@@ -1,13 +1,13 @@
 package media
 
 import (
-	"bytes"
 	"fmt"
-	"net/smtp"
 	"os"
-	"text/template"
+	"strings"
+	"time"
 
-	"github.com/jordan-wright/email"
+	"github.com/johnyeocx/usual/server/db/models"
+	"github.com/johnyeocx/usual/server/utils/money"
 )
 
 func SendEmailVerification(
@@ -16,48 +16,306 @@ func SendEmailVerification(
 	otp string,
 ) (error) {
 
-	fromEmail := os.Getenv("GMAIL_USERNAME")
-	password := os.Getenv("GMAIL_PASSWORD")
+	return sendHTMLEmail(toEmail, "Email Verification", VerifyEmailTemplate, struct {
+		Name    string
+		OTP string
+	}{ Name:  businessName, OTP: otp })
+}
 
-	e := email.NewEmail()
-	e.From = fmt.Sprintf("Usual <%s>", fromEmail)
-	
-	e.To = []string{toEmail}
-	e.Subject = "Email Verification"
+func SendGiftEmail(
+	toEmail string,
+	purchaserName string,
+	productName string,
+	businessName string,
+	periods int,
+	message string,
+	code string,
+	claimBy time.Time,
+	reminder bool,
+) (error) {
 
-	var body bytes.Buffer
-	t, err := template.ParseFiles("./assets/html/verify_email.html")
-	if err != nil {
-		return err
+	subject := fmt.Sprintf("%s sent you a gift from %s", purchaserName, businessName)
+	if reminder {
+		subject = fmt.Sprintf("Reminder: your gift from %s is waiting", purchaserName)
 	}
 
-	err = t.Execute(&body, struct {
-		Name    string
-		OTP string
-	}{ Name:  businessName, OTP: otp })
-	
-	if err != nil {
-		return err
+	return sendHTMLEmail(toEmail, subject, GiftTemplate, struct {
+		PurchaserName 	string
+		ProductName 	string
+		BusinessName 	string
+		Periods 		int
+		Message 		string
+		Code 			string
+		ClaimBy 		string
+		Reminder 		bool
+	}{
+		PurchaserName: purchaserName,
+		ProductName: productName,
+		BusinessName: businessName,
+		Periods: periods,
+		Message: message,
+		Code: code,
+		ClaimBy: claimBy.Format("2 January 2006"),
+		Reminder: reminder,
+	})
+}
+
+func SendSeatInviteEmail(
+	toEmail string,
+	ownerName string,
+	productName string,
+	businessName string,
+) (error) {
+	subject := fmt.Sprintf("%s invited you to share %s", ownerName, productName)
+
+	return sendHTMLEmail(toEmail, subject, SeatInviteTemplate, struct {
+		OwnerName 		string
+		ProductName 	string
+		BusinessName 	string
+	}{
+		OwnerName: ownerName,
+		ProductName: productName,
+		BusinessName: businessName,
+	})
+}
+
+func SendWaitlistInviteEmail(
+	toEmail string,
+	firstName string,
+	productName string,
+	businessName string,
+	claimBy time.Time,
+) (error) {
+	subject := fmt.Sprintf("A spot opened up for %s", productName)
+
+	return sendHTMLEmail(toEmail, subject, WaitlistInviteTemplate, struct {
+		FirstName 		string
+		ProductName 	string
+		BusinessName 	string
+		ClaimBy 		string
+	}{
+		FirstName: firstName,
+		ProductName: productName,
+		BusinessName: businessName,
+		ClaimBy: claimBy.Format("2 Jan 2006, 15:04"),
+	})
+}
+
+func SendReceiptEmail(
+	toEmail string,
+	firstName string,
+	productName string,
+	businessName string,
+	amount int,
+	paid time.Time,
+	card *models.CardInfo,
+	invoiceURL string,
+) (error) {
+	subject := fmt.Sprintf("Your receipt from %s", businessName)
+
+	data := struct {
+		FirstName 		string
+		ProductName 	string
+		BusinessName 	string
+		Amount 			string
+		Date 			string
+		CardBrand 		string
+		CardLast4 		string
+		InvoiceURL 		string
+	}{
+		FirstName: firstName,
+		ProductName: productName,
+		BusinessName: businessName,
+		Amount: money.Format(amount),
+		Date: paid.Format("2 Jan 2006"),
+		InvoiceURL: invoiceURL,
 	}
 
-	e.HTML = body.Bytes()
+	if card != nil {
+		data.CardBrand = strings.Title(card.Brand)
+		data.CardLast4 = card.Last4
+	}
 
-	b, err := os.ReadFile("./assets/images/logo2.png") // just pass the file name
-    if err != nil {
-        return err
-    }
+	return sendHTMLEmail(toEmail, subject, ReceiptTemplate, data)
+}
+
+type paymentFailedData struct {
+	FirstName 		string
+	ProductName 	string
+	BusinessName 	string
+	Amount 			string
+	UpdateCardLink 	string
+	Reminder 		bool
+	SuspendDate 	string
+	CancelDate 		string
+}
+
+// SendPaymentFailedEmail is sent as soon as a payment fails, when the business's
+// dunning schedule doesn't remind straight away
+func SendPaymentFailedEmail(
+	toEmail string,
+	firstName string,
+	productName string,
+	businessName string,
+	amount int,
+	updateCardLink string,
+) (error) {
+	subject := fmt.Sprintf("Your payment for %s didn't go through", productName)
 
-	_, err = e.Attach(bytes.NewReader(b), "image1", "image/png")
+	return sendHTMLEmail(toEmail, subject, PaymentFailedTemplate, paymentFailedData{
+		FirstName: firstName,
+		ProductName: productName,
+		BusinessName: businessName,
+		Amount: money.Format(amount),
+		UpdateCardLink: updateCardLink,
+	})
+}
+
+func SendPaymentReminderEmail(
+	toEmail string,
+	firstName string,
+	productName string,
+	businessName string,
+	amount int,
+	updateCardLink string,
+	suspendDate time.Time,
+	cancelDate time.Time,
+) (error) {
+	subject := fmt.Sprintf("Your payment for %s didn't go through", productName)
+
+	return sendHTMLEmail(toEmail, subject, PaymentFailedTemplate, paymentFailedData{
+		FirstName: firstName,
+		ProductName: productName,
+		BusinessName: businessName,
+		Amount: money.Format(amount),
+		UpdateCardLink: updateCardLink,
+		Reminder: true,
+		SuspendDate: suspendDate.Format("2 Jan 2006"),
+		CancelDate: cancelDate.Format("2 Jan 2006"),
+	})
+}
+
+func SendSubCancelledEmail(
+	toEmail string,
+	firstName string,
+	productName string,
+	businessName string,
+	expires *time.Time,
+) (error) {
+	subject := fmt.Sprintf("Your %s subscription has been cancelled", productName)
+
+	expiresStr := ""
+	if expires != nil {
+		expiresStr = expires.Format("2 Jan 2006")
+	}
+
+	return sendHTMLEmail(toEmail, subject, SubCancelledTemplate, struct {
+		FirstName 		string
+		ProductName 	string
+		BusinessName 	string
+		Expires 		string
+	}{
+		FirstName: firstName,
+		ProductName: productName,
+		BusinessName: businessName,
+		Expires: expiresStr,
+	})
+}
+
+// SendNotificationEmail is the plain email for notification events that don't
+// have a template of their own
+func SendNotificationEmail(
+	toEmail string,
+	firstName string,
+	title string,
+	body string,
+) (error) {
+	return sendHTMLEmail(toEmail, title, NotificationTemplate, struct {
+		FirstName 	string
+		Title 		string
+		Body 		string
+	}{
+		FirstName: firstName,
+		Title: title,
+		Body: body,
+	})
+}
+
+type Attachment struct {
+	Filename 		string
+	ContentType 	string
+	Data 			[]byte
+	Inline 			bool
+}
+
+func SendStatementEmail(
+	toEmail string,
+	businessName string,
+	month time.Time,
+	net int,
+	closingBalance int,
+	statement Attachment,
+) (error) {
+	subject := fmt.Sprintf("Your Usual statement for %s", month.Format("January 2006"))
+
+	return sendHTMLEmailWithAttachments(toEmail, subject, StatementTemplate, struct {
+		BusinessName 	string
+		Month 			string
+		Net 			string
+		ClosingBalance 	string
+	}{
+		BusinessName: businessName,
+		Month: month.Format("January 2006"),
+		Net: money.Format(net),
+		ClosingBalance: money.Format(closingBalance),
+	}, []Attachment{statement})
+}
+
+func sendHTMLEmail(
+	toEmail string,
+	subject string,
+	tmpl *EmailTemplate,
+	data interface{},
+) (error) {
+	return sendHTMLEmailWithAttachments(toEmail, subject, tmpl, data, nil)
+}
+
+func sendHTMLEmailWithAttachments(
+	toEmail string,
+	subject string,
+	tmpl *EmailTemplate,
+	data interface{},
+	attachments []Attachment,
+) (error) {
+	body, err := tmpl.Render(data)
 	if err != nil {
 		return err
 	}
 
-
-	err = e.Send("smtp.gmail.com:587", 
-		smtp.PlainAuth("", fromEmail, password, "smtp.gmail.com"))
+	attachments, err = withLogo(attachments)
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
\ No newline at end of file
+	return getQueue().Enqueue(&Email{
+		From: fromAddress(),
+		To: toEmail,
+		Subject: subject,
+		HTML: body,
+		Template: tmpl.Name,
+		Attachments: attachments,
+		Secret: tmpl.secret,
+	})
+}
+
+// withLogo puts the inline logo every template shows in front of the attachments,
+// it isn't logged so emails picked up from the log get it added again
+func withLogo(attachments []Attachment) ([]Attachment, error) {
+	b, err := os.ReadFile("./assets/images/logo2.png") // just pass the file name
+	if err != nil {
+		return nil, err
+	}
+
+	logo := Attachment{Filename: "image1", ContentType: "image/png", Data: b, Inline: true}
+	return append([]Attachment{logo}, attachments...), nil
+}
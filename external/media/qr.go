@@ -7,7 +7,6 @@ import (
 	"log"
 	"strconv"
 
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/boombuler/barcode"
 	"github.com/boombuler/barcode/qr"
 	"github.com/johnyeocx/usual/server/external/cloud"
@@ -17,7 +16,7 @@ var (
 )
 
 func GenerateCusQR(
-	s3Sess *session.Session, 
+	store cloud.ObjectStore, 
 	cusUuid string,
 	cusId int,
 ) (error) {
@@ -37,11 +36,11 @@ func GenerateCusQR(
 		return err
 	}
 
-	err = cloud.PutObject(s3Sess, buf.Bytes(), "image/png", "/customer/profile_qr/" + strconv.Itoa(cusId))
+	err = store.Put("/customer/profile_qr/" + strconv.Itoa(cusId), buf.Bytes(), "image/png")
 	return err
 }
 
-func GenerateSubscribeQRCode(s3Sess *session.Session, businessId int) {
+func GenerateSubscribeQRCode(store cloud.ObjectStore, businessId int) {
 	link := fmt.Sprintf(`https://usual.page.link/?link=https://usual.ltd/subscribe?business_id=%d
 	&apn=com.usual.customer&afl=https://www.usual.ltd/subscribe?business_id=%d
 	&isi=123456789&ibi=com.usual.customer&ifl=https://usual.ltd/subscribe?business_id=%d`, businessId, businessId, businessId)
@@ -64,5 +63,5 @@ func GenerateSubscribeQRCode(s3Sess *session.Session, businessId int) {
 		return
 	}
 
-	cloud.PutObject(s3Sess, buf.Bytes(), "image/png", "/business/profile_qr/" + strconv.Itoa(businessId))
+	store.Put("/business/profile_qr/" + strconv.Itoa(businessId), buf.Bytes(), "image/png")
 }
\ No newline at end of file
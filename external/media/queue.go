@@ -0,0 +1,177 @@
+package media
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	maxEmailAttempts 	= 4
+	emailRetryBase 		= 5 * time.Second
+
+	// an email still queued this long after it was logged has been lost by whichever
+	// server was sending it, retries are over well within this
+	emailStaleAfter 	= 15 * time.Minute
+	emailResumeEvery 	= 5 * time.Minute
+)
+
+var errQueueFull = errors.New("email queue is full")
+
+// EmailLog records every email handed to the queue and how its delivery went. Emails
+// are stored until they're sent so ones lost when a server stops can be picked up again,
+// without the inline logo and without the body of secret emails
+type EmailLog interface {
+	InsertEmailLog(e *Email, provider string) (int, error)
+	SetEmailSent(logId int, attempts int) error
+	SetEmailFailed(logId int, attempts int, sendErr error) error
+	ClaimQueuedEmails(before time.Time) ([]LoggedEmail, error)
+}
+
+type LoggedEmail struct {
+	ID 			int
+	Email 		*Email
+	Attempts 	int
+}
+
+type queuedEmail struct {
+	email 		*Email
+	logId 		*int
+	attempts 	int
+}
+
+// EmailQueue delivers emails in the background, retrying failed sends with backoff
+type EmailQueue struct {
+	sender 	EmailSender
+	log 	EmailLog
+	jobs 	chan *queuedEmail
+}
+
+var (
+	queue 		*EmailQueue
+	queueOnce 	sync.Once
+)
+
+// StartEmailQueue starts the shared queue and keeps resending whatever the log has left queued.
+// Only the first call takes effect, emails sent before it fall back to a queue built
+// from env without a log
+func StartEmailQueue(sender EmailSender, emailLog EmailLog) (*EmailQueue) {
+	queueOnce.Do(func() {
+		queue = &EmailQueue{
+			sender: sender,
+			log: emailLog,
+			jobs: make(chan *queuedEmail, 100),
+		}
+		go queue.run()
+		go queue.resume()
+	})
+	return queue
+}
+
+func getQueue() (*EmailQueue) {
+	return StartEmailQueue(NewSenderFromEnv(), nil)
+}
+
+// Enqueue returns errQueueFull when the email couldn't be queued, so callers don't
+// record it as sent
+func (q *EmailQueue) Enqueue(e *Email) (error) {
+	job := &queuedEmail{email: e}
+
+	if q.log != nil {
+		logId, err := q.log.InsertEmailLog(e, q.sender.Name())
+		if err != nil {
+			log.Printf("Failed to log email to %s: %v\n", e.To, err)
+		} else {
+			job.logId = &logId
+		}
+	}
+
+	return q.push(job)
+}
+
+// push never blocks the caller, when the queue is full the email is dropped and
+// marked failed
+func (q *EmailQueue) push(job *queuedEmail) (error) {
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+	}
+
+	log.Printf("Dropped email to %s: %v\n", job.email.To, errQueueFull)
+	if q.log != nil && job.logId != nil {
+		if err := q.log.SetEmailFailed(*job.logId, job.attempts, errQueueFull); err != nil {
+			log.Printf("Failed to mark email %d failed: %v\n", *job.logId, err)
+		}
+	}
+	return errQueueFull
+}
+
+// resume regularly picks up emails that have sat queued for too long, because the
+// server sending them stopped, and sends them waiting for room in the queue rather
+// than dropping them. Claiming them in the log means only one server resends each
+func (q *EmailQueue) resume() {
+	if q.log == nil {
+		return
+	}
+
+	for {
+		emails, err := q.log.ClaimQueuedEmails(time.Now().Add(-emailStaleAfter))
+		if err != nil {
+			log.Println("Failed to claim queued emails: ", err)
+		}
+
+		for _, e := range emails {
+			logId := e.ID
+			job := &queuedEmail{email: e.Email, logId: &logId, attempts: e.Attempts}
+
+			e.Email.Attachments, err = withLogo(e.Email.Attachments)
+			if err != nil {
+				log.Printf("Failed to resume email %d: %v\n", logId, err)
+				if err := q.log.SetEmailFailed(logId, job.attempts, err); err != nil {
+					log.Printf("Failed to mark email %d failed: %v\n", logId, err)
+				}
+				continue
+			}
+
+			q.jobs <- job
+		}
+
+		time.Sleep(emailResumeEvery)
+	}
+}
+
+func (q *EmailQueue) run() {
+	for job := range q.jobs {
+		q.deliver(job)
+	}
+}
+
+func (q *EmailQueue) deliver(job *queuedEmail) {
+	job.attempts += 1
+	err := q.sender.Send(job.email)
+
+	if err == nil {
+		if q.log != nil && job.logId != nil {
+			if err := q.log.SetEmailSent(*job.logId, job.attempts); err != nil {
+				log.Printf("Failed to mark email %d sent: %v\n", *job.logId, err)
+			}
+		}
+		return
+	}
+
+	if job.attempts < maxEmailAttempts {
+		// 5s, 20s, 80s
+		delay := emailRetryBase << (2 * (job.attempts - 1))
+		time.AfterFunc(delay, func() { q.push(job) })
+		return
+	}
+
+	log.Printf("Failed to send email to %s after %d attempts: %v\n", job.email.To, job.attempts, err)
+	if q.log != nil && job.logId != nil {
+		if err := q.log.SetEmailFailed(*job.logId, job.attempts, err); err != nil {
+			log.Printf("Failed to mark email %d failed: %v\n", *job.logId, err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package media
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jordan-wright/email"
+)
+
+// Email is a fully rendered message, ready to hand to an EmailSender
+type Email struct {
+	From 			string
+	To 				string
+	Subject 		string
+	HTML 			[]byte
+	Template 		string
+	Attachments 	[]Attachment
+	// Secret emails are logged without their body
+	Secret 			bool
+}
+
+type EmailSender interface {
+	Name() string
+	Send(e *Email) error
+}
+
+// NewSenderFromEnv picks the provider from EMAIL_PROVIDER (smtp, api, file or memory),
+// defaulting to smtp so existing gmail credentials keep working
+func NewSenderFromEnv() (EmailSender) {
+	switch os.Getenv("EMAIL_PROVIDER") {
+	case "api":
+		return &APISender{
+			URL: envOr("EMAIL_API_URL", "https://api.sendgrid.com/v3/mail/send"),
+			APIKey: os.Getenv("EMAIL_API_KEY"),
+			Client: &http.Client{Timeout: 20 * time.Second},
+		}
+	case "file":
+		return &FileSender{Dir: envOr("EMAIL_FILE_DIR", "./tmp/emails")}
+	case "memory":
+		return &MemorySender{}
+	}
+
+	username := envOr("SMTP_USERNAME", os.Getenv("GMAIL_USERNAME"))
+	return &SMTPSender{
+		Host: envOr("SMTP_HOST", "smtp.gmail.com"),
+		Port: envOr("SMTP_PORT", "587"),
+		Username: username,
+		Password: envOr("SMTP_PASSWORD", os.Getenv("GMAIL_PASSWORD")),
+	}
+}
+
+// fromAddress is the sender shown on every email
+func fromAddress() (string) {
+	from := envOr("EMAIL_FROM", envOr("SMTP_USERNAME", os.Getenv("GMAIL_USERNAME")))
+	return fmt.Sprintf("Usual <%s>", from)
+}
+
+func envOr(key string, fallback string) (string) {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func toMIME(e *Email) (*email.Email, error) {
+	m := email.NewEmail()
+	m.From = e.From
+	m.To = []string{e.To}
+	m.Subject = e.Subject
+	m.HTML = e.HTML
+
+	for _, a := range e.Attachments {
+		if _, err := m.Attach(bytes.NewReader(a.Data), a.Filename, a.ContentType); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SMTPSender sends over plain SMTP with STARTTLS. Leaving the username empty
+// skips auth, which suits local catchers like MailHog
+type SMTPSender struct {
+	Host 		string
+	Port 		string
+	Username 	string
+	Password 	string
+}
+
+func (s *SMTPSender) Name() (string) {
+	return "smtp"
+}
+
+func (s *SMTPSender) Send(e *Email) (error) {
+	m, err := toMIME(e)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	return m.Send(s.Host + ":" + s.Port, auth)
+}
+
+// APISender posts to a SendGrid compatible transactional mail API
+type APISender struct {
+	URL 	string
+	APIKey 	string
+	Client 	*http.Client
+}
+
+func (s *APISender) Name() (string) {
+	return "api"
+}
+
+type apiAddress struct {
+	Email 	string `json:"email"`
+	Name 	string `json:"name,omitempty"`
+}
+
+type apiAttachment struct {
+	Content 		string `json:"content"`
+	Type 			string `json:"type"`
+	Filename 		string `json:"filename"`
+	Disposition 	string `json:"disposition,omitempty"`
+	ContentID 		string `json:"content_id,omitempty"`
+}
+
+func (s *APISender) Send(e *Email) (error) {
+	from := apiAddress{Email: e.From}
+	if i := strings.Index(e.From, "<"); i >= 0 {
+		from = apiAddress{
+			Name: strings.TrimSpace(e.From[:i]),
+			Email: strings.Trim(e.From[i:], "<>"),
+		}
+	}
+
+	attachments := []apiAttachment{}
+	for _, a := range e.Attachments {
+		att := apiAttachment{
+			Content: base64.StdEncoding.EncodeToString(a.Data),
+			Type: a.ContentType,
+			Filename: a.Filename,
+		}
+		if a.Inline {
+			att.Disposition = "inline"
+			att.ContentID = a.Filename
+		}
+		attachments = append(attachments, att)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"personalizations": []map[string]interface{}{
+			{"to": []apiAddress{{Email: e.To}}},
+		},
+		"from": from,
+		"subject": e.Subject,
+		"content": []map[string]string{
+			{"type": "text/html", "value": string(e.HTML)},
+		},
+		"attachments": attachments,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer " + s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("email api returned %d: %s", res.StatusCode, msg)
+	}
+	return nil
+}
+
+// FileSender writes each email as an .eml file for local development
+type FileSender struct {
+	Dir string
+}
+
+func (s *FileSender) Name() (string) {
+	return "file"
+}
+
+func (s *FileSender) Send(e *Email) (error) {
+	m, err := toMIME(e)
+	if err != nil {
+		return err
+	}
+
+	b, err := m.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(e.To, "@", "_at_"))
+	return os.WriteFile(filepath.Join(s.Dir, filepath.Base(name)), b, 0644)
+}
+
+// MemorySender keeps sent emails in memory
+type MemorySender struct {
+	mu 		sync.Mutex
+	sent 	[]Email
+}
+
+func (s *MemorySender) Name() (string) {
+	return "memory"
+}
+
+func (s *MemorySender) Send(e *Email) (error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, *e)
+	return nil
+}
+
+func (s *MemorySender) Sent() ([]Email) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Email{}, s.sent...)
+}
@@ -0,0 +1,73 @@
+package media
+
+import (
+	"bytes"
+	"html/template"
+	"sync"
+)
+
+const (
+	templateDir = "./assets/html/"
+	brandedLayout = "layout.html"
+)
+
+// EmailTemplate is parsed the first time it's used and kept, branded templates
+// fill in the blocks of layout.html
+type EmailTemplate struct {
+	Name	string
+	files	[]string
+	secret	bool
+	once	sync.Once
+	tmpl	*template.Template
+	err		error
+}
+
+var templates = map[string]*EmailTemplate{}
+
+var (
+	VerifyEmailTemplate 	= registerSecret("verify_email")
+	GiftTemplate 			= register("gift_email", false)
+	SeatInviteTemplate 		= register("seat_invite_email", false)
+	WaitlistInviteTemplate 	= register("waitlist_invite_email", false)
+
+	ReceiptTemplate 		= register("receipt_email", true)
+	PaymentFailedTemplate 	= register("payment_failed_email", true)
+	SubCancelledTemplate 	= register("sub_cancelled_email", true)
+	StatementTemplate 		= register("statement_email", true)
+	NotificationTemplate 	= register("notification_email", true)
+)
+
+func register(name string, branded bool) (*EmailTemplate) {
+	files := []string{templateDir + name + ".html"}
+	if branded {
+		files = append([]string{templateDir + brandedLayout}, files...)
+	}
+
+	t := &EmailTemplate{Name: name, files: files}
+	templates[name] = t
+	return t
+}
+
+// secret templates carry one time codes, their body is kept out of the email log
+func registerSecret(name string) (*EmailTemplate) {
+	t := register(name, false)
+	t.secret = true
+	return t
+}
+
+func (t *EmailTemplate) Render(data interface{}) ([]byte, error) {
+	t.once.Do(func() {
+		t.tmpl, t.err = template.ParseFiles(t.files...)
+	})
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	var body bytes.Buffer
+	err := t.tmpl.Execute(&body, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return body.Bytes(), nil
+}
@@ -0,0 +1,46 @@
+package my_stripe
+
+import (
+	"strconv"
+
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/paymentintent"
+	"github.com/stripe/stripe-go/v74/refund"
+)
+
+func CreateGiftPayment(
+	cusId string,
+	busId string,
+	cardId string,
+	amount int,
+	currency string,
+	giftId int,
+) (*stripe.PaymentIntent, error) {
+	stripe.Key = stripeSecretKey()
+
+	params := &stripe.PaymentIntentParams{
+		Amount: stripe.Int64(int64(amount)),
+		Currency: stripe.String(currency),
+		Customer: stripe.String(cusId),
+		PaymentMethod: stripe.String(cardId),
+		Confirm: stripe.Bool(true),
+		TransferData: &stripe.PaymentIntentTransferDataParams{
+			Destination: stripe.String(busId),
+		},
+	}
+	params.AddMetadata("gift_id", strconv.Itoa(giftId))
+
+	return paymentintent.New(params)
+}
+
+func RefundPayment(pmiId string) (error) {
+	stripe.Key = stripeSecretKey()
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(pmiId),
+		ReverseTransfer: stripe.Bool(true),
+	}
+
+	_, err := refund.New(params)
+	return err
+}
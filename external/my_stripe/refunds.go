@@ -0,0 +1,24 @@
+package my_stripe
+
+import (
+	"github.com/stripe/stripe-go/v74"
+	"github.com/stripe/stripe-go/v74/refund"
+)
+
+// GetChargeRefunds lists every refund on a charge, webhook payloads no longer
+// include them
+func GetChargeRefunds(chargeId string) ([]*stripe.Refund, error) {
+	stripe.Key = stripeSecretKey()
+
+	params := &stripe.RefundListParams{
+		Charge: stripe.String(chargeId),
+	}
+
+	refunds := []*stripe.Refund{}
+	i := refund.List(params)
+	for i.Next() {
+		refunds = append(refunds, i.Refund())
+	}
+
+	return refunds, i.Err()
+}
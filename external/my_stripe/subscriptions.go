@@ -135,6 +135,12 @@ func ResumeSubscription(
 	return s, nil
 }
 
+func GetSubscription(subId string) (*stripe.Subscription, error) {
+	stripe.Key = stripeSecretKey()
+
+	return subscription.Get(subId, nil)
+}
+
 func CancelSubscription(subId string) (error) {
 	stripe.Key = stripeSecretKey()
 
@@ -143,6 +149,24 @@ func CancelSubscription(subId string) (error) {
 	return err
 }
 
+func CancelSubscriptionAtPeriodEnd(subId string) (*stripe.Subscription, error) {
+	stripe.Key = stripeSecretKey()
+	params := &stripe.SubscriptionParams{
+		CancelAtPeriodEnd: stripe.Bool(true),
+	}
+
+	return subscription.Update(subId, params)
+}
+
+func UndoCancelSubscription(subId string) (*stripe.Subscription, error) {
+	stripe.Key = stripeSecretKey()
+	params := &stripe.SubscriptionParams{
+		CancelAtPeriodEnd: stripe.Bool(false),
+	}
+
+	return subscription.Update(subId, params)
+}
+
 func ChangeSubDefaultCard(subId string, cardId string) (error) {
 	stripe.Key = stripeSecretKey()
 	params := stripe.SubscriptionParams{
@@ -2,37 +2,94 @@ package passes
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/alvinbaena/passkit"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/johnyeocx/usual/server/external/cloud"
 )
 
-func GenerateCustomerPass(
-	s3sess *session.Session,
-	cusName string, 
-	cusUuid string,
-	cusId int,
-) (error) {
+const (
+	TeamIdentifier 		= "QC27AT556H"
+	PassTypeIdentifier 	= "pass.com.usual.customer"
+
+	signingCertFile 	= "./passes/private.p12"
+	signingCertPassword = "never_know_your_next_move"
+	wwdrCertFile 		= "./passes/pass.cer"
+	templateDir 		= "./passes/membership"
+)
+
+// PassEntitlement is one line of what a customer can still redeem
+type PassEntitlement struct {
+	ProductName 	string
+	BusinessName 	string
+	Title 			string
+	Unlimited 		bool
+	Remaining 		int
+	Interval 		string
+}
+
+// CustomerPass is everything shown on a customer's membership card. Leaving
+// AuthToken empty builds a static pass that Wallet won't ask to update
+type CustomerPass struct {
+	CusName 		string
+	CusUuid 		string
+	AuthToken 		string
+	ActiveSubs 		int
+	Entitlements 	[]PassEntitlement
+	Updated 		time.Time
+}
+
+func (e *PassEntitlement) remainingText() (string) {
+	if e.Unlimited {
+		return "Unlimited"
+	}
+	return fmt.Sprintf("%d left this %s", e.Remaining, e.Interval)
+}
+
+// BuildCustomerPass signs and zips the customer's .pkpass
+func BuildCustomerPass(cp CustomerPass) ([]byte, error) {
 	c := passkit.NewStoreCard()
 	c.AddAuxiliaryFields(passkit.Field{
 		Key: "Header",
 		Label: "Customer",
-		Value: cusName,
+		Value: cp.CusName,
+	})
+	c.AddAuxiliaryFields(passkit.Field{
+		Key: "active_subs",
+		Label: "Subscriptions",
+		Value: cp.ActiveSubs,
+		ChangeMessage: "You now have %@ subscriptions",
+	})
+
+	for i, e := range cp.Entitlements {
+		c.AddBackFields(passkit.Field{
+			Key: fmt.Sprintf("entitlement_%d", i),
+			Label: fmt.Sprintf("%s · %s (%s)", e.Title, e.ProductName, e.BusinessName),
+			Value: e.remainingText(),
+			ChangeMessage: e.Title + ": %@",
+		})
+	}
+
+	c.AddBackFields(passkit.Field{
+		Key: "updated",
+		Label: "Last updated",
+		Value: cp.Updated.UTC().Format(time.RFC3339),
+		DateStyle: passkit.DateStyleMedium,
+		TimeStyle: passkit.DateStyleShort,
 	})
 
 	pass := passkit.Pass{
 		FormatVersion:       1,
-		TeamIdentifier:      "QC27AT556H",
-		PassTypeIdentifier:  "pass.com.usual.customer",
+		TeamIdentifier:      TeamIdentifier,
+		PassTypeIdentifier:  PassTypeIdentifier,
 		OrganizationName:    "Usual",
-		SerialNumber:        cusUuid,
+		SerialNumber:        cp.CusUuid,
 		Description:         "Usual membership card",
 		StoreCard:         	 c,
 		Barcodes: []passkit.Barcode{
 			{
 				Format:          passkit.BarcodeFormatQR,
-				Message:         cusUuid,
+				Message:         cp.CusUuid,
 				MessageEncoding: "utf-8",
 			},
 		},
@@ -40,25 +97,30 @@ func GenerateCustomerPass(
 		ForegroundColor: "#000000",
 	}
 
+	setWebService(&pass, cp.AuthToken)
 
 	template := passkit.NewInMemoryPassTemplate()
-	template.AddAllFiles("./passes/membership")
+	template.AddAllFiles(templateDir)
 
-	signer := passkit.NewMemoryBasedSigner()
-	signInfo, err := passkit.LoadSigningInformationFromFiles("./passes/private.p12", "never_know_your_next_move", "./passes/pass.cer")
+	return signPass(&pass, template)
+}
 
-	if err != nil {
-		return err
+// only passes with an auth token can be registered for updates
+func setWebService(pass *passkit.Pass, authToken string) {
+	if webServiceURL := os.Getenv("PASS_WEB_SERVICE_URL"); webServiceURL != "" && authToken != "" {
+		pass.WebServiceURL = webServiceURL
+		pass.AuthenticationToken = authToken
 	}
+}
 
-	z, err := signer.CreateSignedAndZippedPassArchive(&pass, template, signInfo)
+func signPass(pass *passkit.Pass, template passkit.PassTemplate) ([]byte, error) {
+	signer := passkit.NewMemoryBasedSigner()
+	signInfo, err := passkit.LoadSigningInformationFromFiles(signingCertFile, signingCertPassword, wwdrCertFile)
 	if err != nil {
-    	return err
+		return nil, err
 	}
-	
-	key := fmt.Sprintf("customer/pkpass/%d.pkpass", cusId)
-	err = cloud.PutObject(s3sess, z, "binary/octet-stream", key)
-	return err
+
+	return signer.CreateSignedAndZippedPassArchive(pass, template, signInfo)
 }
 
 // func openImage(filename string) ([]byte) {
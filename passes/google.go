@@ -0,0 +1,213 @@
+package passes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const googleSaveURL = "https://pay.google.com/gp/v/save/"
+
+var ErrGoogleWalletNotConfigured = errors.New("google wallet is not configured")
+
+// GoogleWallet signs "save to wallet" links. Membership cards are loyalty objects
+// and subscription passes are generic objects so they can carry the business's logo.
+// Objects are created when the customer first saves the link
+type GoogleWallet struct {
+	IssuerID 		string
+	ClientEmail 	string
+	PrivateKey 		[]byte
+	// LogoURL is a public image google shows on membership cards
+	LogoURL 		string
+	Origins 		[]string
+}
+
+// NewGoogleWalletFromEnv reads the issuer from GOOGLE_WALLET_ISSUER_ID and the
+// service account key from GOOGLE_WALLET_CREDENTIALS
+func NewGoogleWalletFromEnv() (*GoogleWallet, error) {
+	issuerId := os.Getenv("GOOGLE_WALLET_ISSUER_ID")
+	if issuerId == "" {
+		return nil, ErrGoogleWalletNotConfigured
+	}
+
+	credentialsFile := os.Getenv("GOOGLE_WALLET_CREDENTIALS")
+	if credentialsFile == "" {
+		credentialsFile = "./credentials/google_wallet_credentials.json"
+	}
+
+	b, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceAccount := struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey 	string `json:"private_key"`
+	}{}
+	if err := json.Unmarshal(b, &serviceAccount); err != nil {
+		return nil, err
+	}
+
+	origins := []string{}
+	if origin := os.Getenv("GOOGLE_WALLET_ORIGIN"); origin != "" {
+		origins = append(origins, origin)
+	}
+
+	return &GoogleWallet{
+		IssuerID: issuerId,
+		ClientEmail: serviceAccount.ClientEmail,
+		PrivateKey: []byte(serviceAccount.PrivateKey),
+		LogoURL: os.Getenv("GOOGLE_WALLET_LOGO_URL"),
+		Origins: origins,
+	}, nil
+}
+
+var invalidIdChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// google ids are the issuer id followed by a suffix of word characters, dots and dashes
+func (g *GoogleWallet) resourceId(suffix string) (string) {
+	return g.IssuerID + "." + invalidIdChars.ReplaceAllString(suffix, "_")
+}
+
+func googleBarcode(cusUuid string) (map[string]interface{}) {
+	return map[string]interface{}{
+		"type": "QR_CODE",
+		"value": cusUuid,
+	}
+}
+
+func googleImage(uri string) (map[string]interface{}) {
+	return map[string]interface{}{
+		"sourceUri": map[string]interface{}{"uri": uri},
+	}
+}
+
+func googleText(header string, body string) (map[string]interface{}) {
+	return map[string]interface{}{
+		"header": header,
+		"body": body,
+	}
+}
+
+func entitlementModules(entitlements []PassEntitlement, withProduct bool) ([]map[string]interface{}) {
+	modules := []map[string]interface{}{}
+	for i, e := range entitlements {
+		header := e.Title
+		if withProduct {
+			header = fmt.Sprintf("%s · %s (%s)", e.Title, e.ProductName, e.BusinessName)
+		}
+
+		module := googleText(header, e.remainingText())
+		module["id"] = fmt.Sprintf("entitlement_%d", i)
+		modules = append(modules, module)
+	}
+	return modules
+}
+
+func (g *GoogleWallet) saveLink(payload map[string]interface{}) (*WalletPass, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(g.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": g.ClientEmail,
+		"aud": "google",
+		"typ": "savetowallet",
+		"iat": time.Now().Unix(),
+		"origins": g.Origins,
+		"payload": payload,
+	})
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+	return &WalletPass{SaveURL: googleSaveURL + signed}, nil
+}
+
+func (g *GoogleWallet) CustomerPass(cp CustomerPass) (*WalletPass, error) {
+	class := map[string]interface{}{
+		"id": g.resourceId("usual_membership"),
+		"issuerName": "Usual",
+		"programName": "Usual membership card",
+		"reviewStatus": "UNDER_REVIEW",
+		"hexBackgroundColor": "#ffffff",
+	}
+	if g.LogoURL != "" {
+		class["programLogo"] = googleImage(g.LogoURL)
+	}
+
+	object := map[string]interface{}{
+		"id": g.resourceId(cp.CusUuid),
+		"classId": class["id"],
+		"state": "ACTIVE",
+		"accountId": cp.CusUuid,
+		"accountName": cp.CusName,
+		"barcode": googleBarcode(cp.CusUuid),
+		"loyaltyPoints": map[string]interface{}{
+			"label": "Subscriptions",
+			"balance": map[string]interface{}{"int": cp.ActiveSubs},
+		},
+		"textModulesData": entitlementModules(cp.Entitlements, true),
+	}
+
+	return g.saveLink(map[string]interface{}{
+		"loyaltyClasses": []interface{}{class},
+		"loyaltyObjects": []interface{}{object},
+	})
+}
+
+func (g *GoogleWallet) SubscriptionPass(sp SubscriptionPass) (*WalletPass, error) {
+	class := map[string]interface{}{
+		"id": g.resourceId("usual_subscription"),
+	}
+
+	modules := []map[string]interface{}{}
+	if sp.Renews != nil {
+		label := "Renews"
+		if sp.Ends {
+			label = "Ends"
+		}
+		renews := googleText(label, sp.Renews.Format("2 Jan 2006"))
+		renews["id"] = "renews"
+		modules = append(modules, renews)
+	}
+	modules = append(modules, entitlementModules(sp.Entitlements, false)...)
+
+	object := map[string]interface{}{
+		"id": g.resourceId(sp.SerialNumber),
+		"classId": class["id"],
+		"state": "ACTIVE",
+		"cardTitle": localized(sp.BusinessName),
+		"header": localized(sp.ProductName),
+		"subheader": localized(sp.CusName),
+		"hexBackgroundColor": "#ffffff",
+		"barcode": googleBarcode(sp.CusUuid),
+		"textModulesData": modules,
+	}
+	if sp.BusinessLogoURL != "" {
+		object["logo"] = googleImage(sp.BusinessLogoURL)
+	} else if g.LogoURL != "" {
+		object["logo"] = googleImage(g.LogoURL)
+	}
+
+	return g.saveLink(map[string]interface{}{
+		"genericClasses": []interface{}{class},
+		"genericObjects": []interface{}{object},
+	})
+}
+
+func localized(value string) (map[string]interface{}) {
+	return map[string]interface{}{
+		"defaultValue": map[string]interface{}{
+			"language": "en-GB",
+			"value": value,
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package passes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+const apnsHost = "https://api.push.apple.com"
+
+var (
+	apnsOnce 	sync.Once
+	apnsClient 	*http.Client
+	apnsErr 	error
+)
+
+// the pass type certificate doubles as the apns client certificate
+func getAPNSClient() (*http.Client, error) {
+	apnsOnce.Do(func() {
+		p12, err := os.ReadFile(signingCertFile)
+		if err != nil {
+			apnsErr = err
+			return
+		}
+
+		key, cert, err := pkcs12.Decode(p12, signingCertPassword)
+		if err != nil {
+			apnsErr = err
+			return
+		}
+
+		apnsClient = &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				ForceAttemptHTTP2: true,
+				TLSClientConfig: &tls.Config{
+					Certificates: []tls.Certificate{{
+						Certificate: [][]byte{cert.Raw},
+						PrivateKey: key,
+						Leaf: cert,
+					}},
+				},
+			},
+		}
+	})
+	return apnsClient, apnsErr
+}
+
+// PushPassUpdates tells Wallet on each device to fetch the latest passes. Apple only
+// wants an empty payload, the device then calls back into the web service. Returns
+// the tokens apns says are no longer registered
+func PushPassUpdates(pushTokens []string) ([]string, error) {
+	if len(pushTokens) == 0 {
+		return nil, nil
+	}
+
+	client, err := getAPNSClient()
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []string{}
+	var lastErr error
+	for _, token := range pushTokens {
+		req, err := http.NewRequest(
+			http.MethodPost,
+			fmt.Sprintf("%s/3/device/%s", apnsHost, token),
+			bytes.NewReader([]byte("{}")),
+		)
+		if err != nil {
+			return stale, err
+		}
+		req.Header.Set("apns-topic", PassTypeIdentifier)
+
+		res, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		res.Body.Close()
+
+		switch {
+		case res.StatusCode == http.StatusGone:
+			stale = append(stale, token)
+		case res.StatusCode != http.StatusOK:
+			lastErr = fmt.Errorf("apns push failed with status %d", res.StatusCode)
+		}
+	}
+
+	return stale, lastErr
+}
@@ -0,0 +1,138 @@
+package passes
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"time"
+
+	_ "image/jpeg"
+
+	"github.com/alvinbaena/passkit"
+)
+
+// SubscriptionPass is a pass for one subscription, branded with the business and
+// showing what's left to redeem this period. It scans the same as the membership card
+type SubscriptionPass struct {
+	SerialNumber 	string
+	CusName 		string
+	CusUuid 		string
+	AuthToken 		string
+	ProductName 	string
+	BusinessName 	string
+	// BusinessLogo is the business's profile image, any format image.Decode reads.
+	// Wallets that fetch images themselves get BusinessLogoURL instead
+	BusinessLogo 	[]byte
+	BusinessLogoURL string
+	// Renews is when the subscription next renews, or when it ends if Ends is set
+	Renews 			*time.Time
+	Ends 			bool
+	Entitlements 	[]PassEntitlement
+	Updated 		time.Time
+}
+
+func SubPassSerial(cusUuid string, subId int) (string) {
+	return fmt.Sprintf("%s.%d", cusUuid, subId)
+}
+
+// wallet only takes png images
+func toPNG(b []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func BuildSubscriptionPass(sp SubscriptionPass) ([]byte, error) {
+	c := passkit.NewStoreCard()
+	c.AddPrimaryFields(passkit.Field{
+		Key: "product",
+		Label: sp.BusinessName,
+		Value: sp.ProductName,
+	})
+
+	if sp.Renews != nil {
+		label := "Renews"
+		if sp.Ends {
+			label = "Ends"
+		}
+		c.AddSecondaryFields(passkit.Field{
+			Key: "renews",
+			Label: label,
+			Value: sp.Renews.UTC().Format(time.RFC3339),
+			DateStyle: passkit.DateStyleMedium,
+			ChangeMessage: label + " %@",
+		})
+	}
+	c.AddSecondaryFields(passkit.Field{
+		Key: "customer",
+		Label: "Customer",
+		Value: sp.CusName,
+	})
+
+	for i, e := range sp.Entitlements {
+		field := passkit.Field{
+			Key: fmt.Sprintf("entitlement_%d", i),
+			Label: e.Title,
+			Value: e.remainingText(),
+			ChangeMessage: e.Title + ": %@",
+		}
+
+		// the front only fits a few, every entitlement is on the back
+		if i < 4 {
+			c.AddAuxiliaryFields(field)
+		}
+		field.Key = fmt.Sprintf("entitlement_back_%d", i)
+		c.AddBackFields(field)
+	}
+
+	c.AddBackFields(passkit.Field{
+		Key: "updated",
+		Label: "Last updated",
+		Value: sp.Updated.UTC().Format(time.RFC3339),
+		DateStyle: passkit.DateStyleMedium,
+		TimeStyle: passkit.DateStyleShort,
+	})
+
+	pass := passkit.Pass{
+		FormatVersion:       1,
+		TeamIdentifier:      TeamIdentifier,
+		PassTypeIdentifier:  PassTypeIdentifier,
+		OrganizationName:    sp.BusinessName,
+		SerialNumber:        sp.SerialNumber,
+		Description:         fmt.Sprintf("%s subscription", sp.ProductName),
+		LogoText:            sp.BusinessName,
+		StoreCard:         	 c,
+		Barcodes: []passkit.Barcode{
+			{
+				Format:          passkit.BarcodeFormatQR,
+				Message:         sp.CusUuid,
+				MessageEncoding: "utf-8",
+			},
+		},
+		BackgroundColor: "#ffffff",
+		ForegroundColor: "#000000",
+	}
+	setWebService(&pass, sp.AuthToken)
+
+	template := passkit.NewInMemoryPassTemplate()
+	template.AddAllFiles(templateDir)
+
+	if sp.BusinessLogo != nil {
+		logo, err := toPNG(sp.BusinessLogo)
+		if err != nil {
+			return nil, err
+		}
+		template.AddFileBytes("logo.png", logo)
+		template.AddFileBytes("logo@2x.png", logo)
+	}
+
+	return signPass(&pass, template)
+}
@@ -0,0 +1,36 @@
+package passes
+
+// Wallet builds passes for one wallet app. Every wallet is built from the same
+// CustomerPass and SubscriptionPass so they show the same fields and scan the same
+type Wallet interface {
+	CustomerPass(cp CustomerPass) (*WalletPass, error)
+	SubscriptionPass(sp SubscriptionPass) (*WalletPass, error)
+}
+
+// WalletPass is either a signed file the customer downloads (apple) or a link that
+// saves the pass straight into their wallet (google)
+type WalletPass struct {
+	Data 		[]byte 	`json:"-"`
+	ContentType string 	`json:"-"`
+	SaveURL 	string 	`json:"save_url,omitempty"`
+}
+
+type AppleWallet struct{}
+
+const pkpassContentType = "application/vnd.apple.pkpass"
+
+func (AppleWallet) CustomerPass(cp CustomerPass) (*WalletPass, error) {
+	z, err := BuildCustomerPass(cp)
+	if err != nil {
+		return nil, err
+	}
+	return &WalletPass{Data: z, ContentType: pkpassContentType}, nil
+}
+
+func (AppleWallet) SubscriptionPass(sp SubscriptionPass) (*WalletPass, error) {
+	z, err := BuildSubscriptionPass(sp)
+	if err != nil {
+		return nil, err
+	}
+	return &WalletPass{Data: z, ContentType: pkpassContentType}, nil
+}
@@ -4,11 +4,18 @@ import (
 	"database/sql"
 
 	firebase "firebase.google.com/go"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/analytics"
+	"github.com/johnyeocx/usual/server/api/apple_pass"
 	"github.com/johnyeocx/usual/server/api/auth"
 	"github.com/johnyeocx/usual/server/api/business"
+	"github.com/johnyeocx/usual/server/api/export"
+	"github.com/johnyeocx/usual/server/api/moderation"
+	"github.com/johnyeocx/usual/server/api/statement"
+	"github.com/johnyeocx/usual/server/api/storage"
 	"github.com/johnyeocx/usual/server/api/usage"
+	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/external/geocode"
 
 	"github.com/johnyeocx/usual/server/api/c/customer"
 	"github.com/johnyeocx/usual/server/api/c/subscription"
@@ -17,6 +24,11 @@ import (
 
 	c_auth "github.com/johnyeocx/usual/server/api/c/auth"
 	c_business "github.com/johnyeocx/usual/server/api/c/business"
+	"github.com/johnyeocx/usual/server/api/c/gift"
+	"github.com/johnyeocx/usual/server/api/c/review"
+	"github.com/johnyeocx/usual/server/api/c/waitlist"
+	"github.com/johnyeocx/usual/server/api/dunning"
+	"github.com/johnyeocx/usual/server/api/notification"
 )
 
 
@@ -24,22 +36,34 @@ import (
 func CreateRoutes(
 	router *gin.Engine,
 	db *sql.DB, 
-	s3Sess *session.Session,
+	store cloud.ObjectStore,
 	fbApp *firebase.App,
+	geocoder geocode.Geocoder,
 ) {
 
 
 	apiRoute := router.Group("/api")
 	{
-		auth.Routes(apiRoute.Group("/auth"), db, s3Sess)
-		business.Routes(apiRoute.Group("/business"), db, s3Sess)
-		usage.Routes(apiRoute.Group("/usage"), db, s3Sess)
-		stripe_webhook.Routes(apiRoute.Group("/stripe_webhook"), db, s3Sess, fbApp)
-		sub_product.Routes(apiRoute.Group("/business/subscription_product"), db, s3Sess)
-
-		c_business.Routes(apiRoute.Group("/c/business"), db, s3Sess)
-		customer.Routes(apiRoute.Group("/c/customer"), db, s3Sess)
-		c_auth.Routes(apiRoute.Group("/c/auth"), db, s3Sess, fbApp)
-		subscription.Routes(apiRoute.Group("/c/subscription"), db, s3Sess)
+		auth.Routes(apiRoute.Group("/auth"), db, store)
+		business.Routes(apiRoute.Group("/business"), db, store, geocoder)
+		usage.Routes(apiRoute.Group("/usage"), db, store, fbApp)
+		stripe_webhook.Routes(apiRoute.Group("/stripe_webhook"), db, store, fbApp)
+		sub_product.Routes(apiRoute.Group("/business/subscription_product"), db, store)
+		analytics.Routes(apiRoute.Group("/business/analytics"), db, store)
+		export.Routes(apiRoute.Group("/business/export"), db, store)
+		statement.Routes(apiRoute.Group("/business/statement"), db, store)
+
+		c_business.Routes(apiRoute.Group("/c/business"), db, store)
+		customer.Routes(apiRoute.Group("/c/customer"), db, store)
+		c_auth.Routes(apiRoute.Group("/c/auth"), db, store, fbApp)
+		subscription.Routes(apiRoute.Group("/c/subscription"), db, store, fbApp)
+		gift.Routes(apiRoute.Group("/c/gift"), db, store)
+		waitlist.Routes(apiRoute.Group("/c/waitlist"), db, store)
+		review.Routes(apiRoute.Group("/c/review"), db, store)
+		dunning.Routes(apiRoute.Group("/c/dunning"), db, store)
+		notification.Routes(apiRoute.Group("/c/notification"), db, store)
+		apple_pass.Routes(apiRoute.Group("/passkit"), db, store)
+		storage.Routes(apiRoute.Group("/storage"), store)
+		moderation.Routes(apiRoute.Group("/moderation"), db)
 	}
 }
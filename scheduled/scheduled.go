@@ -2,24 +2,122 @@ package scheduled
 
 import (
 	"database/sql"
+	"log"
 	"time"
 
+	firebase "firebase.google.com/go"
 	"github.com/go-co-op/gocron"
+	"github.com/johnyeocx/usual/server/api/c/gift"
+	"github.com/johnyeocx/usual/server/api/c/waitlist"
+	"github.com/johnyeocx/usual/server/api/dunning"
+	"github.com/johnyeocx/usual/server/api/notification"
+	"github.com/johnyeocx/usual/server/api/recommendation"
+	"github.com/johnyeocx/usual/server/api/search"
+	"github.com/johnyeocx/usual/server/api/statement"
+	"github.com/johnyeocx/usual/server/db"
+	"github.com/johnyeocx/usual/server/external/cloud"
 )
 
-func RunCronJobs(db *sql.DB) {
+func RunCronJobs(db *sql.DB, store cloud.ObjectStore, fbApp *firebase.App) {
 	go DeleteExpiredOTPs(db)
+	go DeleteOldEmailLogs(db)
+	go HandleUnclaimedGifts(db)
+	go HandleWaitlists(db)
+	go HandleDunning(db, fbApp)
+	go SendStatements(db, store)
+	go SendHeldNotifications(db, fbApp)
+	go RebuildSearchIndex(db)
+	go ComputeRecommendations(db)
 }
 
 func DeleteExpiredOTPs(db *sql.DB) {
 	s := gocron.NewScheduler(time.UTC)
 	s.Every(5).Minutes().Do(func() {
 		deleteStatement := `
-			DELETE FROM message_otps WHERE expiry > $1
+			DELETE FROM email_otp WHERE expiry < $1
 		`
 
-		db.Exec(deleteStatement, time.Now())
+		db.Exec(deleteStatement, time.Now().UTC())
 	})
 
 	s.StartBlocking()
-}
\ No newline at end of file
+}
+
+// the email log is only for looking into recent deliveries
+func DeleteOldEmailLogs(sqlDB *sql.DB) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(1).Day().At("02:00").Do(func() {
+		e := db.EmailLogDB{DB: sqlDB}
+		err := e.DeleteOldEmailLogs(time.Now().AddDate(0, 0, -30))
+		if err != nil {
+			log.Println("Failed to delete old email logs:", err)
+		}
+	})
+
+	s.StartBlocking()
+}
+
+func HandleUnclaimedGifts(db *sql.DB) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(1).Day().At("09:00").Do(func() {
+		gift.HandleUnclaimedGifts(db)
+	})
+
+	s.StartBlocking()
+}
+
+func HandleWaitlists(db *sql.DB) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(15).Minutes().Do(func() {
+		waitlist.HandleWaitlists(db)
+	})
+
+	s.StartBlocking()
+}
+
+func HandleDunning(db *sql.DB, fbApp *firebase.App) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(1).Hour().Do(func() {
+		dunning.HandleDunning(db, fbApp)
+	})
+
+	s.StartBlocking()
+}
+
+// by midday UTC on the 1st last month is over everywhere
+func SendStatements(db *sql.DB, store cloud.ObjectStore) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(1).Day().At("12:00").Do(func() {
+		statement.SendStatements(db, store)
+	})
+
+	s.StartBlocking()
+}
+
+func SendHeldNotifications(db *sql.DB, fbApp *firebase.App) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(5).Minutes().Do(func() {
+		notification.SendHeldNotifications(db, fbApp)
+	})
+
+	s.StartBlocking()
+}
+
+func RebuildSearchIndex(db *sql.DB) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(1).Day().At("03:00").Do(func() {
+		search.RebuildIndex(db)
+	})
+
+	s.StartBlocking()
+}
+
+// after the search rebuild so the two heavy jobs don't overlap
+func ComputeRecommendations(db *sql.DB) {
+	s := gocron.NewScheduler(time.UTC)
+	s.Every(1).Day().At("04:00").Do(func() {
+		recommendation.ComputeRecommendations(db)
+	})
+
+	s.StartBlocking()
+}
@@ -5,9 +5,13 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/api/search"
 	"github.com/johnyeocx/usual/server/db"
 	"github.com/johnyeocx/usual/server/external/cloud"
+	"github.com/johnyeocx/usual/server/external/geocode"
+	"github.com/johnyeocx/usual/server/external/media"
 	"github.com/johnyeocx/usual/server/routes"
+	"github.com/johnyeocx/usual/server/scheduled"
 	"github.com/johnyeocx/usual/server/utils/fcm"
 	"github.com/johnyeocx/usual/server/utils/middleware"
 	"github.com/joho/godotenv"
@@ -28,14 +32,20 @@ func main() {
 	
 	// 2. Connect to services
 	psqlDB := db.Connect()
-	sess := cloud.ConnectAWS()
+	store := cloud.NewStoreFromEnv()
+	geocoder := geocode.NewGeocoderFromEnv()
 	fbApp, err := fcm.CreateFirebaseApp()
 	if err != nil {
 		panic(err);
 	}
 
+	media.StartEmailQueue(media.NewSenderFromEnv(), &db.EmailLogDB{DB: psqlDB})
+
+	// edits only reindex what changed, so fill in anything missing before the nightly rebuild
+	go search.RebuildIndex(psqlDB)
+
 	// 3. Run cron jobs
-	// go scheduled.RunCronJobs(psqlDB)
+	go scheduled.RunCronJobs(psqlDB, store, fbApp)
 	
 	router := gin.Default()
 
@@ -55,7 +65,7 @@ func main() {
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, "Welcome to the usual api")
 	})
-	routes.CreateRoutes(router, psqlDB, sess, fbApp)
+	routes.CreateRoutes(router, psqlDB, store, fbApp, geocoder)
 	
 	router.Run(":8080")
 }
\ No newline at end of file
@@ -2,12 +2,10 @@ package fcm
 
 import (
 	"context"
-	"fmt"
 	"log"
 
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/messaging"
-	my_enums "github.com/johnyeocx/usual/server/constants/enums"
 	"google.golang.org/api/option"
 )
 
@@ -22,32 +20,30 @@ func CreateFirebaseApp() (*firebase.App, error){
 	return app, nil
 }
 
-func SendPaymentFailedNotification(
-	app *firebase.App, 
-	fcmToken string,
-	subId int,
-	productName string,
-	businessName string,
-) (error){
+// SendToDevices pushes to all of a customer's devices in one multicast, data carries
+// the "type" the app routes on along with any ids it needs. It returns how many devices
+// got it and the tokens FCM no longer recognises so they can be removed
+func SendToDevices(
+	app *firebase.App,
+	fcmTokens []string,
+	title string,
+	body string,
+	data map[string]string,
+) (int, []string, error) {
 
 	fcmClient, err := app.Messaging(context.Background())
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	msgBody := fmt.Sprintf("You have a pending payment for your subscription to %s by %s", productName, businessName)
-	_, err = fcmClient.Send(context.Background(), &messaging.Message{
+	res, err := fcmClient.SendMulticast(context.Background(), &messaging.MulticastMessage{
 		Notification: &messaging.Notification{
-		  Title: "Payment Pending",
-		  Body: msgBody,
+		  Title: title,
+		  Body: body,
 		},
 
-		Token: fcmToken, 
-		Data: map[string]string{
-			"type": string(my_enums.PNPaymentFailed),
-			"sub_id": fmt.Sprint(subId),
-			
-		},
+		Tokens: fcmTokens,
+		Data: data,
 		APNS: &messaging.APNSConfig{
 			Payload: &messaging.APNSPayload{
 				Aps: &messaging.Aps{
@@ -56,84 +52,26 @@ func SendPaymentFailedNotification(
 			},
 		},
 	})
-	
-	return err
-}
-
-func SendPaymentSucceededNotification(
-	app *firebase.App, 
-	fcmToken string,
-	subId int,
-	cost int,
-	productName string,
-	businessName string,
-) (error){
-
-	fcmClient, err := app.Messaging(context.Background())
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	costAsFloat := float64(cost/100)
-
-	msgBody := fmt.Sprintf("You have successfully paid £%.2f for your subscription to %s by %s", costAsFloat, productName, businessName)
-	_, err = fcmClient.Send(context.Background(), &messaging.Message{
-		Notification: &messaging.Notification{
-		  Title: "Payment Succeeded",
-		  Body: msgBody,
-		},
-
-		Token: fcmToken, 
-		Data: map[string]string{
-			"type": string(my_enums.PNPaymentRequiresAction),
-			"sub_id": fmt.Sprint(subId),
-		},
-		APNS: &messaging.APNSConfig{
-			Payload: &messaging.APNSPayload{
-				Aps: &messaging.Aps{
-					ContentAvailable: true,
-				},
-			},
-		},
-	})
-	
-	return err
-}
-
-func SendPaymentVoidedNotification(
-	app *firebase.App, 
-	fcmToken string,
-	subId int,
-	productName string,
-	businessName string,
-) (error){
-
-	fcmClient, err := app.Messaging(context.Background())
-	if err != nil {
-		return err
+	stale := []string{}
+	var sendErr error
+	for i, r := range res.Responses {
+		if r.Success {
+			continue
+		}
+
+		if messaging.IsRegistrationTokenNotRegistered(r.Error) {
+			stale = append(stale, fcmTokens[i])
+		} else if sendErr == nil {
+			sendErr = r.Error
+		}
 	}
 
-	msgBody := fmt.Sprintf("Your subscription to %s by %s has been cancelled due to an expired payment", productName, businessName)
-	_, err = fcmClient.Send(context.Background(), &messaging.Message{
-		Notification: &messaging.Notification{
-		  Title: "Payment Failed",
-		  Body: msgBody,
-		},
-
-		Token: fcmToken, 
-		Data: map[string]string{
-			"type": string(my_enums.PNSubCancelled),
-			"sub_id": fmt.Sprint(subId),
-			
-		},
-		APNS: &messaging.APNSConfig{
-			Payload: &messaging.APNSPayload{
-				Aps: &messaging.Aps{
-					ContentAvailable: true,
-				},
-			},
-		},
-	})
-	
-	return err
+	if res.SuccessCount == 0 && sendErr != nil {
+		return 0, stale, sendErr
+	}
+	return res.SuccessCount, stale, nil
 }
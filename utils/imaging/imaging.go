@@ -0,0 +1,207 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+var ErrUnsupportedFormat = errors.New("image must be a jpeg or png")
+var ErrTooManyPixels = errors.New("image dimensions are too large")
+
+// images over this are refused before decoding so a small file can't expand
+// into gigabytes of pixels
+const MaxPixels = 40_000_000
+
+// Decode reads a jpeg or png and turns it upright using its exif orientation.
+// Nothing but the pixels survives, so re-encoding the result strips all metadata
+func Decode(b []byte) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, "", ErrUnsupportedFormat
+	}
+	if cfg.Width * cfg.Height > MaxPixels {
+		return nil, "", ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(b))
+	}
+	return img, format, nil
+}
+
+// Fit scales img down so neither side is over maxSide, averaging every source pixel
+// that lands in each output pixel. Smaller images are returned as they are
+func Fit(img image.Image, maxSide int) (image.Image) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	nw, nh := maxSide, h * maxSide / w
+	if h > w {
+		nw, nh = w * maxSide / h, maxSide
+	}
+	if nw < 1 {
+		nw = 1
+	}
+	if nh < 1 {
+		nh = 1
+	}
+
+	src := toNRGBA(img)
+	dst := image.NewNRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		y0, y1 := y * h / nh, (y + 1) * h / nh
+		for x := 0; x < nw; x++ {
+			x0, x1 := x * w / nw, (x + 1) * w / nw
+
+			var sum [4]int
+			n := 0
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy * src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx * 4 + c])
+					}
+					n++
+				}
+			}
+
+			i := y * dst.Stride + x * 4
+			for c := 0; c < 4; c++ {
+				dst.Pix[i + c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// toNRGBA copies img into a zero based NRGBA so pixels can be read straight from Pix
+func toNRGBA(img image.Image) (*image.NRGBA) {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// Encode writes img back in its original format, jpegs at quality 85
+func Encode(img image.Image, format string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if format == "png" {
+		err := png.Encode(&buf, img)
+		return buf.Bytes(), "image/png", err
+	}
+
+	// jpeg has no alpha, flatten onto white rather than black
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 85})
+	return buf.Bytes(), "image/jpeg", err
+}
+
+// jpegOrientation finds the exif orientation tag (1 to 8), returning 1 if there isn't one
+func jpegOrientation(b []byte) (int) {
+	i := 2
+	for i + 4 <= len(b) && b[i] == 0xFF {
+		marker := b[i + 1]
+		size := int(binary.BigEndian.Uint16(b[i + 2:]))
+		if marker == 0xDA || size < 2 || i + 2 + size > len(b) {
+			break
+		}
+
+		seg := b[i + 4 : i + 2 + size]
+		if marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+func tiffOrientation(t []byte) (int) {
+	if len(t) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if string(t[:2]) == "II" {
+		order = binary.LittleEndian
+	}
+
+	ifd := int(order.Uint32(t[4:]))
+	if ifd + 2 > len(t) {
+		return 1
+	}
+
+	entries := int(order.Uint16(t[ifd:]))
+	for e := 0; e < entries; e++ {
+		p := ifd + 2 + e * 12
+		if p + 12 > len(t) {
+			break
+		}
+		if order.Uint16(t[p:]) == 0x0112 {
+			o := int(order.Uint16(t[p + 8:]))
+			if o >= 1 && o <= 8 {
+				return o
+			}
+		}
+	}
+	return 1
+}
+
+// orient applies an exif orientation so the image displays the way it was taken
+func orient(img image.Image, orientation int) (image.Image) {
+	if orientation == 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// 5 to 8 swap width and height
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w - 1 - x, y
+			case 3:
+				dx, dy = w - 1 - x, h - 1 - y
+			case 4:
+				dx, dy = x, h - 1 - y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h - 1 - y, x
+			case 7:
+				dx, dy = h - 1 - y, w - 1 - x
+			case 8:
+				dx, dy = y, w - 1 - x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X + x, b.Min.Y + y))
+		}
+	}
+	return dst
+}
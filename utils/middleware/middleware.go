@@ -1,11 +1,13 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -144,4 +146,13 @@ func AuthenticateCId(c *gin.Context, sqlDB *sql.DB) (*int, error) {
 	return &customerIdInt, nil
 }
 
-
+// AuthenticateModerator checks the request carries the staff moderation key. With no
+// MODERATION_API_KEY set nobody can moderate
+func AuthenticateModerator(c *gin.Context) (error) {
+	key := os.Getenv("MODERATION_API_KEY")
+	given := c.GetHeader("X-Moderation-Key")
+	if key == "" || subtle.ConstantTimeCompare([]byte(given), []byte(key)) != 1 {
+		return errors.New("invalid moderation key")
+	}
+	return nil
+}
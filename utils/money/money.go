@@ -0,0 +1,22 @@
+package money
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Format renders minor units as £1,234.50
+func Format(amount int) (string) {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	pounds := strconv.Itoa(amount / 100)
+	for i := len(pounds) - 3; i > 0; i -= 3 {
+		pounds = pounds[:i] + "," + pounds[i:]
+	}
+
+	return fmt.Sprintf("%s£%s.%02d", sign, pounds, amount % 100)
+}
@@ -8,6 +8,12 @@ import (
 
 var table = [...]byte{'1', '2', '3', '4', '5', '6', '7', '8', '9', '0'}
 
+// no 0/O or 1/I so codes can be read back over the phone
+var codeTable = [...]byte{
+	'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'J', 'K', 'L', 'M', 'N', 'P', 'Q', 'R', 
+	'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', '2', '3', '4', '5', '6', '7', '8', '9',
+}
+
 func GenerateOTP(max int) (string) {
 	b := make([]byte, max)
     n, err := io.ReadAtLeast(rand.Reader, b, max)
@@ -18,4 +24,16 @@ func GenerateOTP(max int) (string) {
         b[i] = table[int(b[i]) % len(table)]
     }
     return string(b)
+}
+
+func GenerateCode(max int) (string) {
+	b := make([]byte, max)
+    n, err := io.ReadAtLeast(rand.Reader, b, max)
+    if n != max {
+        panic(err)
+    }
+    for i := 0; i < len(b); i++ {
+        b[i] = codeTable[int(b[i]) % len(codeTable)]
+    }
+    return string(b)
 }
\ No newline at end of file
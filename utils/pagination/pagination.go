@@ -0,0 +1,199 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johnyeocx/usual/server/db/models"
+)
+
+var (
+	DefaultLimit = 20
+	MaxLimit = 100
+)
+
+func EncodeCursor(c models.Cursor) (string) {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*models.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var c models.Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
+
+// NewPage wraps a page of items, next is nil on the last page
+func NewPage(items interface{}, next *models.Cursor) (models.Page) {
+	page := models.Page{Items: items}
+	if next != nil {
+		cursor := EncodeCursor(*next)
+		page.NextCursor = &cursor
+	}
+	return page
+}
+
+func ParsePage(limit string, cursor string) (*models.PageParams, *models.RequestError) {
+	p := models.PageParams{Limit: DefaultLimit}
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > MaxLimit {
+			return nil, &models.RequestError{
+				Err: fmt.Errorf("limit must be between 1 and %d", MaxLimit),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		p.Limit = l
+	}
+
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		p.After = c
+	}
+
+	return &p, nil
+}
+
+// ParseFilter reads from/to as inclusive YYYY-MM-DD days in loc
+func ParseFilter(
+	from string, 
+	to string, 
+	status string, 
+	productId string, 
+	loc *time.Location,
+) (*models.ListFilter, *models.RequestError) {
+	f := models.ListFilter{Status: status}
+
+	if from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, loc)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		f.From = &t
+	}
+
+	if to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, loc)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: err,
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		t = t.AddDate(0, 0, 1)
+		f.To = &t
+	}
+
+	if productId != "" {
+		id, err := strconv.Atoi(productId)
+		if err != nil {
+			return nil, &models.RequestError{
+				Err: errors.New("invalid product id"),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+		f.ProductID = &id
+	}
+
+	return &f, nil
+}
+
+// FromQuery reads limit, cursor, from, to, status and product_id off the request
+func FromQuery(c *gin.Context, loc *time.Location) (*models.PageParams, *models.ListFilter, *models.RequestError) {
+	page, reqErr := ParsePage(c.Query("limit"), c.Query("cursor"))
+	if reqErr != nil {
+		return nil, nil, reqErr
+	}
+
+	filter, reqErr := ParseFilter(c.Query("from"), c.Query("to"), c.Query("status"), c.Query("product_id"), loc)
+	if reqErr != nil {
+		return nil, nil, reqErr
+	}
+
+	return page, filter, nil
+}
+
+// Query collects WHERE conditions, each ? in a condition becomes the next $n
+type Query struct {
+	conds	[]string
+	Args	[]interface{}
+}
+
+func NewQuery() (*Query) {
+	return &Query{}
+}
+
+func (q *Query) Where(cond string, args ...interface{}) {
+	for _, arg := range args {
+		q.Args = append(q.Args, arg)
+		cond = strings.Replace(cond, "?", fmt.Sprintf("$%d", len(q.Args)), 1)
+	}
+	q.conds = append(q.conds, cond)
+}
+
+// Filter applies the list filter to the given columns, an empty column skips that filter
+func (q *Query) Filter(f *models.ListFilter, createdCol string, statusCol string, productCol string) {
+	if f == nil {
+		return
+	}
+
+	if f.From != nil && createdCol != "" {
+		q.Where(createdCol + " >= ?", *f.From)
+	}
+	if f.To != nil && createdCol != "" {
+		q.Where(createdCol + " < ?", *f.To)
+	}
+	if f.Status != "" && statusCol != "" {
+		q.Where(statusCol + " = ?", f.Status)
+	}
+	if f.ProductID != nil && productCol != "" {
+		q.Where(productCol + " = ?", *f.ProductID)
+	}
+}
+
+// After continues a newest first listing from the cursor
+func (q *Query) After(c *models.Cursor, createdCol string, idCol string) {
+	if c == nil {
+		return
+	}
+	q.Where(fmt.Sprintf("(%s, %s) < (?, ?)", createdCol, idCol), c.Created, c.ID)
+}
+
+func (q *Query) String() (string) {
+	if len(q.conds) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(q.conds, " AND ")
+}
+
+// Offset is where an offset paged listing resumes
+func Offset(p *models.PageParams) (int) {
+	if p.After == nil {
+		return 0
+	}
+	return p.After.Offset
+}
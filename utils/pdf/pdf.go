@@ -0,0 +1,150 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 in points
+const (
+	PageWidth	= 595.0
+	PageHeight	= 842.0
+)
+
+type Font string
+const (
+	Helvetica		Font = "F1"
+	HelveticaBold	Font = "F2"
+	// every Courier glyph is 600/1000 em wide, so it's the one to measure with
+	Courier			Font = "F3"
+)
+
+var fontNames = map[Font]string{
+	Helvetica: "Helvetica",
+	HelveticaBold: "Helvetica-Bold",
+	Courier: "Courier",
+}
+
+// Document builds a PDF using only the standard 14 fonts, so there's nothing to embed
+type Document struct {
+	pages	[]*bytes.Buffer
+	page	*bytes.Buffer
+}
+
+func New() (*Document) {
+	d := &Document{}
+	d.AddPage()
+	return d
+}
+
+func (d *Document) AddPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+}
+
+// Text draws s with its baseline starting at x, y from the bottom left
+func (d *Document) Text(x float64, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(d.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// TextRight draws courier text ending at x
+func (d *Document) TextRight(x float64, y float64, size float64, s string) {
+	d.Text(x - TextWidth(s, size), y, Courier, size, s)
+}
+
+func (d *Document) Line(x1 float64, y1 float64, x2 float64, y2 float64, width float64) {
+	fmt.Fprintf(d.page, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+func (d *Document) Rect(x float64, y float64, w float64, h float64, grey float64) {
+	fmt.Fprintf(d.page, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", grey, x, y, w, h)
+}
+
+// TextWidth is exact for courier
+func TextWidth(s string, size float64) (float64) {
+	return float64(len([]rune(s))) * 0.6 * size
+}
+
+func (d *Document) Bytes() ([]byte) {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// WriteTo lays out catalog, page tree, fonts then each page and its content,
+// followed by the cross reference table
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	offsets := []int{}
+
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	fonts := []Font{Helvetica, HelveticaBold, Courier}
+	firstPage := 3 + len(fonts)
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage + i * 2))
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	fontRefs := []string{}
+	for i, f := range fonts {
+		obj(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", fontNames[f]))
+		fontRefs = append(fontRefs, fmt.Sprintf("/%s %d 0 R", f, 3 + i))
+	}
+
+	for i, p := range d.pages {
+		obj(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, strings.Join(fontRefs, " "), firstPage + i * 2 + 1,
+		))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets) + 1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets) + 1, xref)
+
+	n, err := w.Write(out.Bytes())
+	return int64(n), err
+}
+
+// escape encodes s as WinAnsi, dropping anything the standard fonts can't show
+func escape(s string) (string) {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString("\\200")
+		case r == '…':
+			b.WriteString("\\205")
+		case r == '–':
+			b.WriteString("\\226")
+		case r == '—':
+			b.WriteString("\\227")
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Format string
+const (
+	CSV		Format = "csv"
+	XLSX	Format = "xlsx"
+)
+
+func (f Format) ContentType() (string) {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+type Column struct {
+	Name 		string
+	Numeric 	bool
+}
+
+// Writer streams rows out as they're written, nothing is held beyond the current row
+type Writer interface {
+	WriteRow(values []string) (error)
+	Close() (error)
+}
+
+// NewWriter writes the header row straight away
+func NewWriter(w io.Writer, format Format, sheetName string, columns []Column) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, columns)
+	case XLSX:
+		return newXLSXWriter(w, sheetName, columns)
+	}
+	return nil, fmt.Errorf("unsupported format %s", format)
+}
+
+// escapeFormula stops spreadsheet apps from running text that looks like a formula,
+// names and notes come straight from customers so they can't be trusted
+func escapeFormula(value string) (string) {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// isNumber is true for values of a numeric column that can be written as they are
+func isNumber(columns []Column, i int, value string) (bool) {
+	if i >= len(columns) || !columns[i].Numeric || value == "" {
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+type csvWriter struct {
+	w 		*csv.Writer
+	columns []Column
+	rows 	int
+}
+
+func newCSVWriter(w io.Writer, columns []Column) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), columns: columns}
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	return cw, cw.w.Write(header)
+}
+
+func (cw *csvWriter) WriteRow(values []string) (error) {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		if isNumber(cw.columns, i, value) {
+			escaped[i] = value
+		} else {
+			escaped[i] = escapeFormula(value)
+		}
+	}
+
+	err := cw.w.Write(escaped)
+	if err != nil {
+		return err
+	}
+
+	// flush every so often so the client sees progress on big exports
+	cw.rows++
+	if cw.rows % 500 == 0 {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() (error) {
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,138 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// a minimal single sheet workbook, cells are written inline so there's no
+// shared string table to build up before the sheet can be written
+
+const contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const rootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const workbookXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const workbookRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+const sheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+const sheetFooter = `</sheetData></worksheet>`
+
+type xlsxWriter struct {
+	zw 			*zip.Writer
+	sheet 		io.Writer
+	columns 	[]Column
+	row 		int
+}
+
+func newXLSXWriter(w io.Writer, sheetName string, columns []Column) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+
+	escapedName := xmlEscape(sheetName)
+	parts := []struct{
+		name 	string
+		body 	string
+	}{
+		{"[Content_Types].xml", contentTypesXML},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", fmt.Sprintf(workbookXML, escapedName)},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML},
+	}
+
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	// the sheet has to be the last entry as it stays open while rows stream in
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, sheetHeader); err != nil {
+		return nil, err
+	}
+
+	xw := &xlsxWriter{zw: zw, sheet: sheet, columns: columns}
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Name
+	}
+	return xw, xw.writeRow(header, true)
+}
+
+func (xw *xlsxWriter) WriteRow(values []string) (error) {
+	return xw.writeRow(values, false)
+}
+
+func (xw *xlsxWriter) writeRow(values []string, header bool) (error) {
+	xw.row++
+
+	buf := []byte(`<row r="` + strconv.Itoa(xw.row) + `">`)
+	for i, value := range values {
+		ref := columnName(i) + strconv.Itoa(xw.row)
+		if !header && isNumber(xw.columns, i, value) {
+			buf = append(buf, `<c r="` + ref + `"><v>` + value + `</v></c>`...)
+		} else {
+			if !header {
+				value = escapeFormula(value)
+			}
+			buf = append(buf, `<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + xmlEscape(value) + `</t></is></c>`...)
+		}
+	}
+	buf = append(buf, `</row>`...)
+
+	_, err := xw.sheet.Write(buf)
+	return err
+}
+
+func (xw *xlsxWriter) Close() (error) {
+	if _, err := io.WriteString(xw.sheet, sheetFooter); err != nil {
+		return err
+	}
+	return xw.zw.Close()
+}
+
+// columnName turns a 0 based index into A, B, ... Z, AA, AB ...
+func columnName(i int) (string) {
+	name := ""
+	for i >= 0 {
+		name = string(rune('A' + i % 26)) + name
+		i = i / 26 - 1
+	}
+	return name
+}
+
+func xmlEscape(s string) (string) {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}